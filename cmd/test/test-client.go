@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -93,21 +94,34 @@ type Notification struct {
 	Body  int    `json:"body"`
 }
 
+type LoginResponse struct {
+	AccessToken string `json:"access_token"`
+}
+
 func main() {
 	args := os.Args[1:]
-	uid := args[0]
-	port := args[1]
+	if len(args) != 3 {
+		log.Fatal("Usage: test-client <username> <password> <port>")
+	}
+	username := args[0]
+	password := args[1]
+	port := args[2]
+
+	token, err := Login(username, password, port)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	dialer := websocket.Dialer{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
 	}
 
-	uidHeader := http.Header{
-		"uid": []string{uid},
+	authHeader := http.Header{
+		"Authorization": []string{"Bearer " + token},
 	}
 
-	conn, _, err := dialer.Dial(fmt.Sprintf("ws://localhost:%s/realtime", port), uidHeader)
+	conn, _, err := dialer.Dial(fmt.Sprintf("ws://localhost:%s/realtime", port), authHeader)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -115,7 +129,7 @@ func main() {
 	var wg sync.WaitGroup
 
 	wg.Add(1)
-	go ReadFromStdIn(conn, &wg, uid)
+	go ReadFromStdIn(conn, &wg, username)
 	wg.Add(1)
 	go ReadFromConnection(conn, &wg)
 	//go SendPeriodicHeartbeat(conn)
@@ -123,6 +137,26 @@ func main() {
 	wg.Wait()
 }
 
+// Login exchanges credentials for an access token, the server derives the realtime uid from it
+func Login(username, password, port string) (string, error) {
+	body, _ := json.Marshal(map[string]string{"username": username, "password": password})
+	response, err := http.Post(fmt.Sprintf("http://localhost:%s/login", port), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("login failed with status %s", response.Status)
+	}
+
+	var login LoginResponse
+	if err := json.NewDecoder(response.Body).Decode(&login); err != nil {
+		return "", err
+	}
+	return login.AccessToken, nil
+}
+
 func SendPeriodicHeartbeat(conn *websocket.Conn) {
 	ticker := time.NewTicker(time.Second * 30)
 
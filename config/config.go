@@ -13,9 +13,47 @@ var (
 )
 
 type Config struct {
-	AppName string          `mapstructure:"app_name"`
-	Server  ServerConfig    `mapstructure:"server"`
-	Auth    AuthTokenConfig `mapstructure:"auth"`
+	AppName     string            `mapstructure:"app_name"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Auth        AuthTokenConfig   `mapstructure:"auth"`
+	Rbac        RbacConfig        `mapstructure:"rbac"`
+	Realtime    RealtimeConfig    `mapstructure:"realtime"`
+	Mail        MailConfig        `mapstructure:"mail"`
+	RateLimit   RateLimitConfig   `mapstructure:"rate_limit"`
+	Concurrency ConcurrencyConfig `mapstructure:"concurrency"`
+}
+
+// ConcurrencyConfig caps in-flight requests per route class, each cap adapts to observed latency
+type ConcurrencyConfig struct {
+	LatencyTarget string                            `mapstructure:"latency_target"` // Slower requests shrink the cap
+	Classes       map[string]ConcurrencyClassConfig `mapstructure:"classes"`
+}
+
+type ConcurrencyClassConfig struct {
+	Initial int `mapstructure:"initial"`
+	Min     int `mapstructure:"min"`
+	Max     int `mapstructure:"max"`
+	// Other classes are shed while a priority class has every slot taken
+	Priority bool `mapstructure:"priority"`
+}
+
+// RateLimitConfig holds the named policies routes are limited by, the redis store falls back to memory while Redis is unreachable
+type RateLimitConfig struct {
+	Store     string `mapstructure:"store"`     // memory or redis
+	Algorithm string `mapstructure:"algorithm"` // Default for policies that don't pick one
+	// How long redis is left alone after an error before one request probes it again
+	FallbackCooldown string                           `mapstructure:"fallback_cooldown"`
+	Policies         map[string]RateLimitPolicyConfig `mapstructure:"policies"`
+}
+
+// RateLimitPolicyConfig allows limit requests per window for each key
+type RateLimitPolicyConfig struct {
+	Limit  int    `mapstructure:"limit"` // The bucket capacity for token_bucket and gcra
+	Window string `mapstructure:"window"`
+	// ip, subject or api_key, the last two fall back to the client ip for anonymous requests
+	Key string `mapstructure:"key"`
+	// fixed_window, token_bucket, sliding_window_log, sliding_window_counter or gcra
+	Algorithm string `mapstructure:"algorithm"`
 }
 
 type ServerConfig struct {
@@ -24,11 +62,30 @@ type ServerConfig struct {
 }
 
 type HttpConfig struct {
-	Port           string `mapstructure:"port"`
-	ReadTimeout    int64  `mapstructure:"readtimeout"`
-	WriteTimeout   int64  `mapstructure:"writetimeout"`
-	IdleTimeout    int64  `mapstructure:"idletimeout"`
-	MaxHeaderBytes int    `mapstructure:"maxheaderbytes"`
+	Port           string    `mapstructure:"port"`
+	ReadTimeout    int64     `mapstructure:"readtimeout"`
+	WriteTimeout   int64     `mapstructure:"writetimeout"`
+	IdleTimeout    int64     `mapstructure:"idletimeout"`
+	MaxHeaderBytes int       `mapstructure:"maxheaderbytes"`
+	Tls            TlsConfig `mapstructure:"tls"`
+	// CIDRs or addresses of proxies allowed to set Forwarded and X-Forwarded-For
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// forwarded or x-forwarded-for, whichever the trusted proxies set, the other header is ignored
+	ForwardedHeader string `mapstructure:"forwarded_header"`
+}
+
+// TlsConfig serves HTTPS when cert_file is set, files are re-read when they change on disk
+type TlsConfig struct {
+	CertFile     string `mapstructure:"cert_file"`
+	KeyFile      string `mapstructure:"key_file"`
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// none, optional or require, anything but none needs client_ca_file
+	ClientAuth     string `mapstructure:"client_auth"`
+	ReloadInterval string `mapstructure:"reload_interval"`
+}
+
+func (c *TlsConfig) Enabled() bool {
+	return c.CertFile != ""
 }
 
 type GrpcConfig struct {
@@ -36,8 +93,95 @@ type GrpcConfig struct {
 }
 
 type AuthTokenConfig struct {
-	AccessToken  TokenConfig `mapstructure:"access_token"`
-	RefreshToken TokenConfig `mapstructure:"refresh_token"`
+	AccessToken  TokenConfig    `mapstructure:"access_token"`
+	RefreshToken TokenConfig    `mapstructure:"refresh_token"`
+	Signing      SigningConfig  `mapstructure:"signing"`
+	Clients      []ClientConfig `mapstructure:"clients"`
+	Mfa          MfaConfig      `mapstructure:"mfa"`
+	Lockout      LockoutConfig  `mapstructure:"lockout"`
+	Email        EmailConfig    `mapstructure:"email"`
+	Oidc         OidcConfig     `mapstructure:"oidc"`
+	// Impersonation tokens are access only, they never come with a refresh token
+	Impersonation ImpersonationConfig `mapstructure:"impersonation"`
+	ApiKeys       ApiKeyConfig        `mapstructure:"api_keys"`
+	// redis or memory, where revoked tokens and refresh token families are kept. Memory is for a single instance.
+	TokenStore string `mapstructure:"token_store"`
+}
+
+type ImpersonationConfig struct {
+	Expiration string `mapstructure:"expiration"`
+}
+
+// ApiKeyConfig holds the quotas a key gets when it is created without its own, zero is unlimited
+type ApiKeyConfig struct {
+	DailyQuota   int `mapstructure:"daily_quota"`
+	MonthlyQuota int `mapstructure:"monthly_quota"`
+	// Quotas are counted in the rate limit store and saved to the database this often
+	QuotaFlushInterval string `mapstructure:"quota_flush_interval"`
+	// How long a looked up key is reused, a revoke on another node takes up to this long to reach this one
+	CacheExpiration string `mapstructure:"cache_expiration"`
+}
+
+// OidcConfig makes the server an OpenID Connect provider for the clients registered with redirect_uris
+type OidcConfig struct {
+	Enabled           bool   `mapstructure:"enabled"` // Needs asymmetric signing keys, relying parties can't verify HS512
+	Issuer            string `mapstructure:"issuer"`  // Public base URL, every endpoint in discovery hangs off it
+	CodeExpiration    string `mapstructure:"code_expiration"`
+	IDTokenExpiration string `mapstructure:"id_token_expiration"`
+}
+
+// EmailConfig covers the emailed verification and password reset links
+type EmailConfig struct {
+	VerifyURL        string `mapstructure:"verify_url"` // ?token= is appended
+	VerifyExpiration string `mapstructure:"verify_expiration"`
+	ResetURL         string `mapstructure:"reset_url"` // Usually a frontend page that posts to /password/reset
+	ResetExpiration  string `mapstructure:"reset_expiration"`
+}
+
+// LockoutConfig throttles failed logins per account and per client IP independently
+type LockoutConfig struct {
+	Account LockoutPolicyConfig `mapstructure:"account"`
+	IP      LockoutPolicyConfig `mapstructure:"ip"`
+}
+
+type LockoutPolicyConfig struct {
+	Threshold int    `mapstructure:"threshold"` // Failures before the first lock, 0 disables
+	Base      string `mapstructure:"base"`      // First lock, doubled on every further failure
+	Max       string `mapstructure:"max"`
+	Window    string `mapstructure:"window"` // Failures older than this are forgotten
+}
+
+type MfaConfig struct {
+	Issuer            string `mapstructure:"issuer"` // Shown in authenticator apps
+	PendingExpiration string `mapstructure:"pending_expiration"`
+	Skew              int    `mapstructure:"skew"` // Steps of clock drift tolerated either way
+}
+
+// ClientConfig registers an OAuth2 client, the secret is stored as a bcrypt hash
+type ClientConfig struct {
+	Id         string   `mapstructure:"id"`
+	Name       string   `mapstructure:"name"`
+	SecretHash string   `mapstructure:"secret_hash"`
+	Scopes     []string `mapstructure:"scopes"`
+	// Needed for the authorization code flow, matched exactly. Clients without a secret are public and rely on PKCE alone.
+	RedirectURIs []string `mapstructure:"redirect_uris"`
+	// Verified client certificate identities (URI SAN, DNS SAN or subject CN) that authenticate as this client
+	CertificateSubjects []string `mapstructure:"certificate_subjects"`
+}
+
+// SigningConfig switches token signing to asymmetric keys, with no keys tokens fall back to HS512 with the secret
+type SigningConfig struct {
+	ActiveKid       string             `mapstructure:"active_kid"`
+	RotationOverlap string             `mapstructure:"rotation_overlap"`
+	Keys            []SigningKeyConfig `mapstructure:"keys"`
+}
+
+type SigningKeyConfig struct {
+	Kid            string `mapstructure:"kid"`
+	Algorithm      string `mapstructure:"algorithm"` // RS256, ES256 or EdDSA
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
+	RetiredAt      string `mapstructure:"retired_at"` // RFC3339, verifies until retired_at + rotation_overlap
 }
 
 type TokenConfig struct {
@@ -47,6 +191,45 @@ type TokenConfig struct {
 	Issuer     string `mapstructure:"issuer"`
 }
 
+type MailConfig struct {
+	Driver    string     `mapstructure:"driver"` // outbox or smtp
+	From      string     `mapstructure:"from"`
+	OutboxDir string     `mapstructure:"outbox_dir"`
+	Smtp      SmtpConfig `mapstructure:"smtp"`
+}
+
+type SmtpConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+type RealtimeConfig struct {
+	AllowedOrigins   []string                    `mapstructure:"allowed_origins"`
+	TicketExpiration string                      `mapstructure:"ticket_expiration"`
+	InboundLimits    RealtimeInboundLimitsConfig `mapstructure:"inbound_limits"`
+}
+
+// RealtimeInboundLimitsConfig caps what each connection may send, per message category with default covering the rest
+type RealtimeInboundLimitsConfig struct {
+	// Over-limit messages are dropped with a warning, one more than max_warnings inside warning_window closes the connection
+	MaxWarnings   int                                   `mapstructure:"max_warnings"`
+	WarningWindow string                                `mapstructure:"warning_window"`
+	Categories    map[string]RealtimeInboundLimitConfig `mapstructure:"categories"`
+}
+
+// RealtimeInboundLimitConfig allows a one second burst of either, zero is unlimited
+type RealtimeInboundLimitConfig struct {
+	MessagesPerSecond int `mapstructure:"messages_per_second"`
+	BytesPerSecond    int `mapstructure:"bytes_per_second"`
+}
+
+// RbacConfig maps a role name to the permissions it grants
+type RbacConfig struct {
+	Roles map[string][]string `mapstructure:"roles"`
+}
+
 func Initialize() *Config {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -81,6 +264,51 @@ func SetDefaults() {
 	viper.SetDefault("server.http.readtimeout", 15)
 	viper.SetDefault("server.http.writetimeout", 15)
 	viper.SetDefault("server.http.maxheaderbytes", 1024)
+	viper.SetDefault("server.http.tls.client_auth", "none")
+	viper.SetDefault("server.http.tls.reload_interval", "30s")
+	viper.SetDefault("server.http.forwarded_header", "x-forwarded-for")
+	viper.SetDefault("auth.signing.rotation_overlap", "168h")
+	viper.SetDefault("realtime.ticket_expiration", "30s")
+	viper.SetDefault("realtime.inbound_limits.max_warnings", 3)
+	viper.SetDefault("realtime.inbound_limits.warning_window", "1m")
+	viper.SetDefault("realtime.inbound_limits.categories.default.messages_per_second", 10)
+	viper.SetDefault("realtime.inbound_limits.categories.default.bytes_per_second", 8192)
+	viper.SetDefault("auth.mfa.issuer", "my-app")
+	viper.SetDefault("auth.mfa.pending_expiration", "5m")
+	viper.SetDefault("auth.mfa.skew", 1)
+	viper.SetDefault("auth.lockout.account.threshold", 5)
+	viper.SetDefault("auth.lockout.account.base", "1m")
+	viper.SetDefault("auth.lockout.account.max", "1h")
+	viper.SetDefault("auth.lockout.account.window", "24h")
+	viper.SetDefault("auth.lockout.ip.threshold", 20)
+	viper.SetDefault("auth.lockout.ip.base", "1m")
+	viper.SetDefault("auth.lockout.ip.max", "1h")
+	viper.SetDefault("auth.lockout.ip.window", "1h")
+	viper.SetDefault("auth.email.verify_url", "http://localhost:9000/verify-email")
+	viper.SetDefault("auth.email.verify_expiration", "24h")
+	viper.SetDefault("auth.email.reset_url", "http://localhost:3000/reset-password")
+	viper.SetDefault("auth.email.reset_expiration", "30m")
+	viper.SetDefault("auth.oidc.enabled", false)
+	viper.SetDefault("auth.oidc.issuer", "http://localhost:9000")
+	viper.SetDefault("auth.oidc.code_expiration", "1m")
+	viper.SetDefault("auth.oidc.id_token_expiration", "1h")
+	viper.SetDefault("auth.impersonation.expiration", "15m")
+	viper.SetDefault("auth.api_keys.daily_quota", 10000)
+	viper.SetDefault("auth.api_keys.monthly_quota", 250000)
+	viper.SetDefault("auth.api_keys.quota_flush_interval", "10s")
+	viper.SetDefault("auth.api_keys.cache_expiration", "30s")
+	viper.SetDefault("auth.token_store", "redis")
+	viper.SetDefault("rate_limit.store", "memory")
+	viper.SetDefault("rate_limit.algorithm", "fixed_window")
+	viper.SetDefault("rate_limit.fallback_cooldown", "5s")
+	viper.SetDefault("rate_limit.policies.default.limit", 5)
+	viper.SetDefault("rate_limit.policies.default.window", "10s")
+	viper.SetDefault("rate_limit.policies.default.key", "ip")
+	viper.SetDefault("concurrency.latency_target", "250ms")
+	viper.SetDefault("mail.driver", "outbox")
+	viper.SetDefault("mail.from", "no-reply@localhost")
+	viper.SetDefault("mail.outbox_dir", "./outbox")
+	viper.SetDefault("mail.smtp.port", 587)
 }
 
 func Get() *Config {
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS password_hash,
+    DROP COLUMN IF EXISTS username;
\ No newline at end of file
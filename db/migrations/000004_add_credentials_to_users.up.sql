@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS username TEXT UNIQUE,
+    ADD COLUMN IF NOT EXISTS password_hash TEXT;
\ No newline at end of file
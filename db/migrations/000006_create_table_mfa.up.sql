@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS user_totp(
+    user_id INT NOT NULL,
+    secret TEXT NOT NULL,
+    confirmed_at TIMESTAMPTZ,
+    CONSTRAINT pkey_user_totp PRIMARY KEY(user_id),
+    CONSTRAINT fkey_user_totp_users FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes(
+    id SERIAL UNIQUE,
+    user_id INT NOT NULL,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMPTZ,
+    CONSTRAINT pkey_recovery_codes PRIMARY KEY(id),
+    CONSTRAINT fkey_recovery_codes_users FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes(user_id);
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS audit_log(
+    id SERIAL UNIQUE,
+    action TEXT NOT NULL,
+    actor_id INT NOT NULL,
+    target_id INT NOT NULL,
+    reason TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    token_id TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT pkey_audit_log PRIMARY KEY(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_target_id ON audit_log(target_id);
\ No newline at end of file
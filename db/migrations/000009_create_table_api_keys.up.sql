@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS api_keys(
+    id SERIAL UNIQUE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL UNIQUE,
+    key_hash TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    daily_quota INT NOT NULL DEFAULT 0,
+    monthly_quota INT NOT NULL DEFAULT 0,
+    created_by INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ,
+    CONSTRAINT pkey_api_keys PRIMARY KEY(id)
+);
+
+CREATE TABLE IF NOT EXISTS quota_usage(
+    key TEXT NOT NULL,
+    period TEXT NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    used INT NOT NULL DEFAULT 0,
+    rejected INT NOT NULL DEFAULT 0,
+    CONSTRAINT pkey_quota_usage PRIMARY KEY(key, period, period_start)
+);
\ No newline at end of file
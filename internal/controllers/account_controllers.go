@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	service "github.com/abhinash-kml/go-api-server/internal/services"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type AccountController struct {
+	accountservice service.AccountService
+
+	logger *zap.Logger
+	tracer oteltracer.Tracer
+}
+
+func NewAccountController(accountService service.AccountService, logger *zap.Logger, tracer oteltracer.Tracer) *AccountController {
+	return &AccountController{
+		accountservice: accountService,
+		logger:         logger,
+		tracer:         tracer,
+	}
+}
+
+// POST /password/forgot
+func (c *AccountController) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "ForgotPassword.Controller")
+	defer span.End()
+
+	dto := model.ForgotPasswordRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "json decoding forgotpasswordrequest failed")
+		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+		return
+	}
+
+	validate := newValidator()
+	if err := validate.Struct(dto); err != nil {
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "email",
+				Message: "email must be a valid email address",
+				Code:    "INVALID_FORMAT",
+			},
+		}, r.URL.String())
+		return
+	}
+
+	if err := c.accountservice.ForgotPassword(ctx, dto.Email); err != nil {
+		HandleServiceError(w, r, span, err, "user")
+		return
+	}
+
+	// Same answer whether or not the address is registered
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// POST /password/reset
+func (c *AccountController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "ResetPassword.Controller")
+	defer span.End()
+
+	dto := model.ResetPasswordRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "json decoding resetpasswordrequest failed")
+		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+		return
+	}
+
+	validate := newValidator()
+	if err := validate.Struct(dto); err != nil {
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "password",
+				Message: "token is required and password must be at least 8 characters and at most 72 bytes",
+				Code:    "INVALID_FORMAT",
+			},
+		}, r.URL.String())
+		return
+	}
+
+	if err := c.accountservice.ResetPassword(ctx, dto); err != nil {
+		HandleServiceError(w, r, span, err, "user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /verify-email/resend
+func (c *AccountController) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "ResendVerification.Controller")
+	defer span.End()
+
+	dto := model.ResendVerificationRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "json decoding resendverificationrequest failed")
+			SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+			return
+		}
+	}
+
+	validate := newValidator()
+	if err := validate.Struct(dto); err != nil {
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "email",
+				Message: "email must be a valid email address",
+				Code:    "INVALID_FORMAT",
+			},
+		}, r.URL.String())
+		return
+	}
+
+	if err := c.accountservice.ResendVerification(ctx, dto.Email); err != nil {
+		HandleServiceError(w, r, span, err, "user")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GET /verify-email?token=
+func (c *AccountController) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "VerifyEmail.Controller")
+	defer span.End()
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "token",
+				Message: "token is required",
+				Code:    "MISSING_TOKEN",
+			},
+		}, r.URL.String())
+		return
+	}
+
+	if err := c.accountservice.VerifyEmail(ctx, token); err != nil {
+		HandleServiceError(w, r, span, err, "user")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]bool{"email_verified": true})
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	service "github.com/abhinash-kml/go-api-server/internal/services"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type ApiKeysController struct {
+	apikeyservice service.ApiKeyService
+
+	logger *zap.Logger
+	tracer oteltracer.Tracer
+}
+
+func NewApiKeysController(apiKeyService service.ApiKeyService, logger *zap.Logger, tracer oteltracer.Tracer) *ApiKeysController {
+	return &ApiKeysController{
+		apikeyservice: apiKeyService,
+		logger:        logger,
+		tracer:        tracer,
+	}
+}
+
+// POST /api-keys
+func (c *ApiKeysController) CreateApiKey(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "CreateApiKey.Controller")
+	defer span.End()
+
+	dto := model.ApiKeyCreateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "json decoding apikeycreaterequest failed")
+		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+		return
+	}
+
+	validate := newValidator()
+	if err := validate.Struct(dto); err != nil {
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Message: "A name of at most 100 characters is required, quotas can't be negative",
+				Code:    "INVALID_API_KEY_REQUEST",
+			},
+		}, r.URL.String())
+		return
+	}
+
+	response, err := c.apikeyservice.CreateApiKey(ctx, dto)
+	if err != nil {
+		HandleServiceError(w, r, span, err, "api key")
+		return
+	}
+
+	span.SetAttributes(attribute.Int("api_key.id", response.Id))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		span.RecordError(err)
+	}
+}
+
+// GET /api-keys
+func (c *ApiKeysController) GetApiKeys(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "GetApiKeys.Controller")
+	defer span.End()
+
+	keys, err := c.apikeyservice.GetApiKeys(ctx)
+	if err != nil {
+		HandleServiceError(w, r, span, err, "api key")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(keys); err != nil {
+		span.RecordError(err)
+	}
+}
+
+// DELETE /api-keys/{id}
+func (c *ApiKeysController) RevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "RevokeApiKey.Controller")
+	defer span.End()
+
+	id, ok := apiKeyId(w, r)
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(attribute.Int("api_key.id", id))
+
+	if err := c.apikeyservice.RevokeApiKey(ctx, id); err != nil {
+		HandleServiceError(w, r, span, err, "api key")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api-keys/{id}/usage
+func (c *ApiKeysController) GetApiKeyUsage(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "GetApiKeyUsage.Controller")
+	defer span.End()
+
+	id, ok := apiKeyId(w, r)
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(attribute.Int("api_key.id", id))
+
+	usage, err := c.apikeyservice.GetUsage(ctx, id)
+	if err != nil {
+		HandleServiceError(w, r, span, err, "api key")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(usage); err != nil {
+		span.RecordError(err)
+	}
+}
+
+func apiKeyId(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "id",
+				Message: "id must be an integer",
+				Code:    "INVALID_ID",
+			},
+		}, r.URL.String())
+		return 0, false
+	}
+	return id, true
+}
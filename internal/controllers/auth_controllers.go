@@ -0,0 +1,325 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	service "github.com/abhinash-kml/go-api-server/internal/services"
+	"github.com/abhinash-kml/go-api-server/pkg/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type AuthController struct {
+	authservice service.AuthService
+
+	logger *zap.Logger
+	tracer oteltracer.Tracer
+}
+
+func NewAuthController(authService service.AuthService, logger *zap.Logger, tracer oteltracer.Tracer) *AuthController {
+	return &AuthController{
+		authservice: authService,
+		logger:      logger,
+		tracer:      tracer,
+	}
+}
+
+// POST /login
+func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "Login.Controller")
+	defer span.End()
+
+	dto := model.LoginRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "json decoding loginrequest failed")
+		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+		return
+	}
+
+	validate := newValidator()
+	if err := validate.Struct(dto); err != nil {
+		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+		return
+	}
+
+	span.SetAttributes(attribute.String("user.username", dto.Username))
+
+	dto.Device = deviceInfo(r)
+	response, challenge, err := c.authservice.Login(ctx, dto)
+	if err != nil {
+		HandleServiceError(w, r, span, err, "user")
+		return
+	}
+
+	if challenge != nil {
+		span.SetAttributes(attribute.Bool("auth.mfa_required", true))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		json.NewEncoder(w).Encode(challenge)
+		return
+	}
+
+	SendTokenResponse(w, response)
+}
+
+// POST /login/mfa
+func (c *AuthController) LoginMfa(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "LoginMfa.Controller")
+	defer span.End()
+
+	dto := model.MfaLoginRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "json decoding mfaloginrequest failed")
+		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+		return
+	}
+
+	validate := newValidator()
+	if err := validate.Struct(dto); err != nil {
+		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+		return
+	}
+
+	dto.Device = deviceInfo(r)
+	response, err := c.authservice.LoginMfa(ctx, dto)
+	if err != nil {
+		HandleServiceError(w, r, span, err, "user")
+		return
+	}
+
+	SendTokenResponse(w, response)
+}
+
+// POST /access_token
+func (c *AuthController) AccessToken(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "AccessToken.Controller")
+	defer span.End()
+
+	// OAuth2 clients send a form (RFC 6749 section 4.1.3), JSON is kept for existing callers
+	dto := model.AccessTokenRequest{}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		if err := r.ParseForm(); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "form parsing accesstokenrequest failed")
+			SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+			return
+		}
+		dto = accessTokenRequestFromForm(r.PostForm)
+	} else if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "json decoding accesstokenrequest failed")
+		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+		return
+	}
+
+	validate := newValidator()
+	if err := validate.Struct(dto); err != nil {
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "grant_type",
+				Message: "grant_type is required",
+				Code:    "invalid_request",
+			},
+		}, r.URL.String())
+		return
+	}
+
+	// Clients may authenticate with HTTP Basic instead of the body (RFC 6749 section 2.3.1)
+	if id, secret, ok := r.BasicAuth(); ok && dto.ClientId == "" {
+		dto.ClientId, dto.ClientSecret = id, secret
+	}
+
+	span.SetAttributes(attribute.String("grant_type", dto.GrantType))
+
+	dto.Device = deviceInfo(r)
+	response, err := c.authservice.AccessToken(ctx, dto)
+	if err != nil {
+		HandleServiceError(w, r, span, err, "token")
+		return
+	}
+
+	SendTokenResponse(w, response)
+}
+
+// POST /logout
+func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "Logout.Controller")
+	defer span.End()
+
+	// The body is optional, without a refresh token only the access token is revoked
+	dto := model.LogoutRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil && !errors.Is(err, io.EOF) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "json decoding logoutrequest failed")
+		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+		return
+	}
+
+	if err := c.authservice.Logout(ctx, dto.RefreshToken); err != nil {
+		HandleServiceError(w, r, span, err, "token")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /logout/all
+func (c *AuthController) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "LogoutAll.Controller")
+	defer span.End()
+
+	// The body is optional, an empty one logs out the caller
+	dto := model.LogoutAllRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil && !errors.Is(err, io.EOF) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "json decoding logoutallrequest failed")
+		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+		return
+	}
+
+	span.SetAttributes(attribute.Int("user.id", dto.UserID))
+
+	if err := c.authservice.LogoutAll(ctx, dto.UserID); err != nil {
+		HandleServiceError(w, r, span, err, "user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /users/{id}/unlock
+func (c *AuthController) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "UnlockUser.Controller")
+	defer span.End()
+
+	userId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		span.RecordError(err)
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "id",
+				Message: "id must be an integer",
+				Code:    "INVALID_ID",
+			},
+		}, r.URL.String())
+		return
+	}
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	if err := c.authservice.UnlockAccount(ctx, userId); err != nil {
+		HandleServiceError(w, r, span, err, "user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /users/{id}/impersonate
+func (c *AuthController) Impersonate(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "Impersonate.Controller")
+	defer span.End()
+
+	userId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		span.RecordError(err)
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "id",
+				Message: "id must be an integer",
+				Code:    "INVALID_ID",
+			},
+		}, r.URL.String())
+		return
+	}
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	dto := model.ImpersonationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "json decoding impersonationrequest failed")
+		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+		return
+	}
+
+	validate := newValidator()
+	if err := validate.Struct(dto); err != nil {
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "reason",
+				Message: "A reason of at most 500 characters is required",
+				Code:    "INVALID_REASON",
+			},
+		}, r.URL.String())
+		return
+	}
+
+	dto.Device = deviceInfo(r)
+	response, err := c.authservice.Impersonate(ctx, userId, dto)
+	if err != nil {
+		HandleServiceError(w, r, span, err, "user")
+		return
+	}
+
+	SendTokenResponse(w, response)
+}
+
+// GET /.well-known/jwks.json
+func (c *AuthController) Jwks(w http.ResponseWriter, r *http.Request) {
+	_, span := c.tracer.Start(r.Context(), "Jwks.Controller")
+	defer span.End()
+
+	keys := util.JSONWebKeySet{Keys: []util.JSONWebKey{}}
+	if keyset := util.GetJwtKeyset(); keyset != nil {
+		keys = keyset.JWKS()
+	}
+
+	span.SetAttributes(attribute.Int("jwks.keys", len(keys.Keys)))
+
+	// Short enough that a rotated key shows up well inside the overlap window
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(keys)
+}
+
+func accessTokenRequestFromForm(form url.Values) model.AccessTokenRequest {
+	return model.AccessTokenRequest{
+		GrantType:    form.Get("grant_type"),
+		RefreshToken: form.Get("refresh_token"),
+		ClientId:     form.Get("client_id"),
+		ClientSecret: form.Get("client_secret"),
+		Scope:        form.Get("scope"),
+		Code:         form.Get("code"),
+		RedirectURI:  form.Get("redirect_uri"),
+		CodeVerifier: form.Get("code_verifier"),
+	}
+}
+
+func deviceInfo(r *http.Request) model.DeviceInfo {
+	return model.DeviceInfo{
+		UserAgent: r.UserAgent(),
+		IP:        util.ClientIP(r),
+	}
+}
+
+// Token responses must never be cached by intermediaries (RFC 6749 section 5.1)
+func SendTokenResponse(w http.ResponseWriter, response *model.AuthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	json.NewEncoder(w).Encode(response)
+}
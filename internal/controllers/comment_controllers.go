@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -139,7 +138,7 @@ func (c *CommentsController) PostComment(w http.ResponseWriter, r *http.Request)
 
 // TODO: Implement spans as per json merge patch
 func (c *CommentsController) PatchComment(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "PatchComment.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "PatchComment.Controller")
 	defer span.End()
 
 	dto := model.CommentUpdateDTO{}
@@ -150,9 +149,7 @@ func (c *CommentsController) PatchComment(w http.ResponseWriter, r *http.Request
 
 	err := c.commentservice.UpdateComment(ctx, dto)
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "error updating comment")
-		SendProblemDetails(w, ProblemError, nil, r.URL.String())
+		HandleServiceError(w, r, span, err, "comment")
 		return
 	}
 
@@ -161,7 +158,7 @@ func (c *CommentsController) PatchComment(w http.ResponseWriter, r *http.Request
 
 // TODO: Implement spans as per json merge patch
 func (c *CommentsController) PutComment(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "PutComment.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "PutComment.Controller")
 	defer span.End()
 
 	dto := model.CommentReplaceDTO{}
@@ -171,7 +168,7 @@ func (c *CommentsController) PutComment(w http.ResponseWriter, r *http.Request)
 
 	err := c.commentservice.ReplaceComment(ctx, dto)
 	if err != nil {
-		SendProblemDetails(w, ProblemError, nil, r.URL.String())
+		HandleServiceError(w, r, span, err, "comment")
 		return
 	}
 
@@ -179,7 +176,7 @@ func (c *CommentsController) PutComment(w http.ResponseWriter, r *http.Request)
 }
 
 func (c *CommentsController) DeleteComment(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "DeleteComment.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "DeleteComment.Controller")
 	defer span.End()
 
 	dto := model.CommentDeleteDTO{}
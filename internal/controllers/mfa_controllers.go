@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	service "github.com/abhinash-kml/go-api-server/internal/services"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type MfaController struct {
+	mfaservice service.MfaService
+
+	logger *zap.Logger
+	tracer oteltracer.Tracer
+}
+
+func NewMfaController(mfaService service.MfaService, logger *zap.Logger, tracer oteltracer.Tracer) *MfaController {
+	return &MfaController{
+		mfaservice: mfaService,
+		logger:     logger,
+		tracer:     tracer,
+	}
+}
+
+// POST /2fa/totp/enroll
+func (c *MfaController) EnrollTotp(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "EnrollTotp.Controller")
+	defer span.End()
+
+	response, err := c.mfaservice.EnrollTotp(ctx)
+	if err != nil {
+		HandleServiceError(w, r, span, err, "user")
+		return
+	}
+
+	// The secret is as sensitive as a password
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+// POST /2fa/totp/confirm
+func (c *MfaController) ConfirmTotp(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "ConfirmTotp.Controller")
+	defer span.End()
+
+	dto := model.TotpConfirmRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "json decoding totpconfirmrequest failed")
+		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
+		return
+	}
+
+	validate := newValidator()
+	if err := validate.Struct(dto); err != nil {
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "code",
+				Message: "code must be the 6 digits shown by the authenticator app",
+				Code:    "INVALID_FORMAT",
+			},
+		}, r.URL.String())
+		return
+	}
+
+	response, err := c.mfaservice.ConfirmTotp(ctx, dto.Code)
+	if err != nil {
+		HandleServiceError(w, r, span, err, "user")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
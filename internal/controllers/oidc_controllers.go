@@ -0,0 +1,212 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	service "github.com/abhinash-kml/go-api-server/internal/services"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Deliberately bare, apps wanting a branded page can put their own in front of POST /authorize
+var authorizeTemplate = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in</title></head>
+<body>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+{{if .Client}}
+<h1>Sign in to {{.Client.Name}}</h1>
+<p>{{.Client.Name}} is asking for:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>
+<form method="post" action="/authorize">
+	{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+	{{end}}
+	<label>Username <input name="username" autocomplete="username" required></label>
+	<label>Password <input name="password" type="password" autocomplete="current-password" required></label>
+	<label>Two-factor code <input name="mfa_code" autocomplete="one-time-code"></label>
+	<button name="decision" value="allow">Allow</button>
+	<button name="decision" value="deny" formnovalidate>Deny</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type authorizePage struct {
+	Client *model.Client
+	Scopes []string
+	Params map[string]string
+	Error  string
+}
+
+type OidcController struct {
+	oidcservice service.OidcService
+
+	logger *zap.Logger
+	tracer oteltracer.Tracer
+}
+
+func NewOidcController(oidcService service.OidcService, logger *zap.Logger, tracer oteltracer.Tracer) *OidcController {
+	return &OidcController{
+		oidcservice: oidcService,
+		logger:      logger,
+		tracer:      tracer,
+	}
+}
+
+// GET /.well-known/openid-configuration
+func (c *OidcController) Discovery(w http.ResponseWriter, r *http.Request) {
+	_, span := c.tracer.Start(r.Context(), "Discovery.Controller")
+	defer span.End()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(c.oidcservice.Discovery())
+}
+
+// GET /authorize
+func (c *OidcController) AuthorizePage(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "AuthorizePage.Controller")
+	defer span.End()
+
+	req := authorizationRequest(r.URL.Query())
+	span.SetAttributes(attribute.String("client.id", req.ClientID))
+
+	client, err := c.oidcservice.ValidateAuthorization(ctx, req)
+	if err != nil {
+		c.handleAuthorizeError(w, r, req, err)
+		return
+	}
+
+	renderAuthorizePage(w, http.StatusOK, client, req, "")
+}
+
+// POST /authorize
+func (c *OidcController) Authorize(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "Authorize.Controller")
+	defer span.End()
+
+	if err := r.ParseForm(); err != nil {
+		span.RecordError(err)
+		renderAuthorizePage(w, http.StatusBadRequest, nil, model.AuthorizationRequest{}, "The form could not be read")
+		return
+	}
+
+	req := authorizationRequest(r.PostForm)
+	span.SetAttributes(attribute.String("client.id", req.ClientID))
+
+	client, err := c.oidcservice.ValidateAuthorization(ctx, req)
+	if err != nil {
+		c.handleAuthorizeError(w, r, req, err)
+		return
+	}
+
+	redirect, err := c.oidcservice.Authorize(ctx, model.AuthorizationConsent{
+		AuthorizationRequest: req,
+		Username:             r.PostForm.Get("username"),
+		Password:             r.PostForm.Get("password"),
+		MfaCode:              strings.TrimSpace(r.PostForm.Get("mfa_code")),
+		Approved:             r.PostForm.Get("decision") == "allow",
+		Device:               deviceInfo(r),
+	})
+
+	// Failed sign ins stay on the page, the client only hears about the final outcome
+	var lockout *service.LockoutError
+	switch {
+	case err == nil:
+		http.Redirect(w, r, redirect, http.StatusFound)
+	case errors.As(err, &lockout):
+		span.SetStatus(codes.Error, "login locked out")
+		renderAuthorizePage(w, http.StatusTooManyRequests, client, req, "Too many failed attempts, try again later")
+	case errors.Is(err, service.ErrInvalidCredentials):
+		span.SetStatus(codes.Error, "invalid credentials")
+		renderAuthorizePage(w, http.StatusUnauthorized, client, req, "Username or password is incorrect")
+	case errors.Is(err, service.ErrMfaRequired):
+		renderAuthorizePage(w, http.StatusUnauthorized, client, req, "Enter the code from your authenticator app")
+	case errors.Is(err, service.ErrInvalidMfaCode):
+		span.SetStatus(codes.Error, "invalid mfa code")
+		renderAuthorizePage(w, http.StatusUnauthorized, client, req, "The two-factor code is invalid")
+	default:
+		c.handleAuthorizeError(w, r, req, err)
+	}
+}
+
+// GET /userinfo, POST /userinfo
+func (c *OidcController) UserInfo(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "UserInfo.Controller")
+	defer span.End()
+
+	response, err := c.oidcservice.UserInfo(ctx)
+	if err != nil {
+		HandleServiceError(w, r, span, err, "user")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleAuthorizeError redirects only once the client and redirect_uri checked out, anything else is shown to the user
+func (c *OidcController) handleAuthorizeError(w http.ResponseWriter, r *http.Request, req model.AuthorizationRequest, err error) {
+	var authorizeErr *service.AuthorizeError
+	switch {
+	case errors.As(err, &authorizeErr):
+		http.Redirect(w, r, authorizeErr.RedirectURL(req.RedirectURI, req.State), http.StatusFound)
+	case errors.Is(err, service.ErrInvalidClient):
+		renderAuthorizePage(w, http.StatusBadRequest, nil, req, "Unknown client")
+	case errors.Is(err, service.ErrInvalidRedirectURI):
+		renderAuthorizePage(w, http.StatusBadRequest, nil, req, "The redirect_uri is not registered for this client")
+	default:
+		c.logger.Error("Authorization request failed", zap.Error(err))
+		renderAuthorizePage(w, http.StatusInternalServerError, nil, req, "Something went wrong, try again later")
+	}
+}
+
+func authorizationRequest(values url.Values) model.AuthorizationRequest {
+	return model.AuthorizationRequest{
+		ResponseType:        values.Get("response_type"),
+		ClientID:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		Scope:               values.Get("scope"),
+		State:               values.Get("state"),
+		Nonce:               values.Get("nonce"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+	}
+}
+
+func renderAuthorizePage(w http.ResponseWriter, status int, client *model.Client, req model.AuthorizationRequest, message string) {
+	page := authorizePage{
+		Client: client,
+		Scopes: strings.Fields(req.Scope),
+		Error:  message,
+		Params: map[string]string{
+			"response_type":         req.ResponseType,
+			"client_id":             req.ClientID,
+			"redirect_uri":          req.RedirectURI,
+			"scope":                 req.Scope,
+			"state":                 req.State,
+			"nonce":                 req.Nonce,
+			"code_challenge":        req.CodeChallenge,
+			"code_challenge_method": req.CodeChallengeMethod,
+		},
+	}
+
+	// A login form must never be framed or cached
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(status)
+
+	authorizeTemplate.Execute(w, page)
+}
@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -33,7 +32,7 @@ func NewPostsController(userService service.UserService, postService service.Pos
 }
 
 func (c *PostsController) GetPosts(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "GetPosts.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "GetPosts.Controller")
 	defer span.End()
 
 	cursor := r.URL.Query().Get("cursor")
@@ -79,7 +78,7 @@ func (c *PostsController) GetPosts(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *PostsController) GetById(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "GetById.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "GetById.Controller")
 	defer span.End()
 
 	idString := r.PathValue("id")
@@ -113,7 +112,7 @@ func (c *PostsController) GetById(w http.ResponseWriter, r *http.Request) {
 // Should this belong in posts controller or comments controller file ?
 // GET posts/xxx-xxx-xxx/comments?limit=x
 func (c *PostsController) GetCommentsOfPost(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "GetCommentsOfPost.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "GetCommentsOfPost.Controller")
 	defer span.End()
 
 	postIdString := r.PathValue("id")
@@ -146,7 +145,7 @@ func (c *PostsController) GetCommentsOfPost(w http.ResponseWriter, r *http.Reque
 }
 
 func (c *PostsController) PostPost(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "PostPost.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "PostPost.Controller")
 	defer span.End()
 
 	incoming := model.PostCreateDTO{}
@@ -173,7 +172,7 @@ func (c *PostsController) PostPost(w http.ResponseWriter, r *http.Request) {
 
 // TODO: Add span attributes as per json merge patch
 func (c *PostsController) PutPost(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "PutPost.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "PutPost.Controller")
 	defer span.End()
 
 	dto := model.PostReplaceDTO{}
@@ -186,9 +185,7 @@ func (c *PostsController) PutPost(w http.ResponseWriter, r *http.Request) {
 
 	err := c.postservice.ReplacePost(ctx, dto)
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "error replacing post")
-		SendProblemDetails(w, ProblemError, nil, r.URL.String())
+		HandleServiceError(w, r, span, err, "post")
 		return
 	}
 
@@ -197,7 +194,7 @@ func (c *PostsController) PutPost(w http.ResponseWriter, r *http.Request) {
 
 // TODO: Add span attributes as per json merge patch
 func (c *PostsController) PatchPost(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "PatchPost.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "PatchPost.Controller")
 	defer span.End()
 
 	dto := model.PostUpdateDTO{}
@@ -208,9 +205,7 @@ func (c *PostsController) PatchPost(w http.ResponseWriter, r *http.Request) {
 
 	err := c.postservice.UpdatePost(ctx, dto)
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "error updating post")
-		SendProblemDetails(w, ProblemError, nil, r.URL.String())
+		HandleServiceError(w, r, span, err, "post")
 		return
 	}
 
@@ -218,7 +213,7 @@ func (c *PostsController) PatchPost(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *PostsController) DeletePost(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "DeletePost.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "DeletePost.Controller")
 	defer span.End()
 
 	incoming := model.PostDeleteDTO{}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	"github.com/abhinash-kml/go-api-server/internal/realtime"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type RealtimeController struct {
+	tickets   realtime.ITicketStore
+	ticketTTL time.Duration
+
+	logger *zap.Logger
+	tracer oteltracer.Tracer
+}
+
+func NewRealtimeController(tickets realtime.ITicketStore, config *config.RealtimeConfig, logger *zap.Logger, tracer oteltracer.Tracer) *RealtimeController {
+	ticketTTL, err := time.ParseDuration(config.TicketExpiration)
+	if err != nil {
+		logger.Warn("Invalid realtime ticket expiration, using 30s", zap.Error(err))
+		ticketTTL = time.Second * 30
+	}
+
+	return &RealtimeController{
+		tickets:   tickets,
+		ticketTTL: ticketTTL,
+		logger:    logger,
+		tracer:    tracer,
+	}
+}
+
+// POST /realtime/ticket
+func (c *RealtimeController) IssueTicket(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "IssueTicket.Controller")
+	defer span.End()
+
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok {
+		span.SetStatus(codes.Error, "no principal")
+		SendProblemDetails(w, ProblemUnauthorized, nil, r.URL.String())
+		return
+	}
+
+	span.SetAttributes(attribute.String("auth.subject", principal.Subject))
+
+	ticket, err := c.tickets.Issue(ctx, principal, c.ticketTTL)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to issue ticket")
+		SendProblemDetails(w, ProblemError, nil, r.URL.String())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(model.RealtimeTicketResponse{
+		Ticket:    ticket,
+		ExpiresIn: int(c.ticketTTL.Seconds()),
+	})
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	service "github.com/abhinash-kml/go-api-server/internal/services"
+	"go.opentelemetry.io/otel/attribute"
+	oteltracer "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type SessionsController struct {
+	sessionservice service.SessionService
+
+	logger *zap.Logger
+	tracer oteltracer.Tracer
+}
+
+func NewSessionsController(sessionService service.SessionService, logger *zap.Logger, tracer oteltracer.Tracer) *SessionsController {
+	return &SessionsController{
+		sessionservice: sessionService,
+		logger:         logger,
+		tracer:         tracer,
+	}
+}
+
+// GET /users/{id}/sessions
+func (c *SessionsController) GetSessions(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "GetSessions.Controller")
+	defer span.End()
+
+	userId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		span.RecordError(err)
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "id",
+				Message: "id must be an integer",
+				Code:    "INVALID_ID",
+			},
+		}, r.URL.String())
+		return
+	}
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	sessions, err := c.sessionservice.GetSessionsOfUser(ctx, userId)
+	if err != nil {
+		HandleServiceError(w, r, span, err, "session")
+		return
+	}
+
+	span.SetAttributes(attribute.Int("session.count", len(sessions)))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sessions); err != nil {
+		span.RecordError(err)
+	}
+}
+
+// DELETE /users/{id}/sessions/{sid}
+func (c *SessionsController) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.tracer.Start(r.Context(), "DeleteSession.Controller")
+	defer span.End()
+
+	userId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		span.RecordError(err)
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "id",
+				Message: "id must be an integer",
+				Code:    "INVALID_ID",
+			},
+		}, r.URL.String())
+		return
+	}
+
+	sid := r.PathValue("sid")
+	span.SetAttributes(attribute.Int("user.id", userId),
+		attribute.String("session.id", sid))
+
+	if err := c.sessionservice.RevokeSession(ctx, userId, sid); err != nil {
+		HandleServiceError(w, r, span, err, "session")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
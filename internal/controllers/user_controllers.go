@@ -1,19 +1,20 @@
 package controller
 
 import (
-	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 
 	model "github.com/abhinash-kml/go-api-server/internal/models"
 	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
 	service "github.com/abhinash-kml/go-api-server/internal/services"
-	"github.com/go-playground/validator/v10"
+	"github.com/abhinash-kml/go-api-server/pkg/util"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
@@ -29,6 +30,10 @@ const (
 	ProblemError
 	ProblemForbidden
 	ProblemUnauthorized
+	ProblemConflict
+	ProblemTooManyRequests
+	ProblemLocked
+	ProblemServiceUnavailable
 )
 
 type UsersController struct {
@@ -166,7 +171,7 @@ func (c *UsersController) GetPostsOfUser(w http.ResponseWriter, r *http.Request)
 }
 
 func (c *UsersController) PostUser(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "PostUser.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "PostUser.Controller")
 	defer span.End()
 
 	user := model.UserCreateDTO{}
@@ -177,7 +182,7 @@ func (c *UsersController) PostUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validator
-	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate := newValidator()
 	err := validate.Struct(user)
 	if err != nil {
 		SendProblemDetails(w, ProblemValidationError, nil, r.URL.String())
@@ -187,13 +192,12 @@ func (c *UsersController) PostUser(w http.ResponseWriter, r *http.Request) {
 	span.SetAttributes(attribute.String("user.name", user.Name),
 		attribute.String("user.city", user.City),
 		attribute.String("user.state", user.State),
-		attribute.String("user.country", user.Country))
+		attribute.String("user.country", user.Country),
+		attribute.String("user.username", user.Username))
 
 	err = c.userservice.InsertUser(ctx, user)
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "error inserting new user")
-		SendProblemDetails(w, ProblemError, nil, r.URL.String())
+		HandleServiceError(w, r, span, err, "user")
 		return
 	}
 
@@ -202,7 +206,7 @@ func (c *UsersController) PostUser(w http.ResponseWriter, r *http.Request) {
 
 // TODO: Add spans as per json merge patch
 func (c *UsersController) PatchUser(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "PatchUser.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "PatchUser.Controller")
 	defer span.End()
 
 	dto := model.UserUpdateDTO{}
@@ -222,7 +226,7 @@ func (c *UsersController) PatchUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *UsersController) PutUser(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "PutUser.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "PutUser.Controller")
 	defer span.End()
 
 	var dto model.UserReplaceDTO
@@ -237,7 +241,7 @@ func (c *UsersController) PutUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *UsersController) DeleteUser(w http.ResponseWriter, r *http.Request) {
-	ctx, span := c.tracer.Start(context.Background(), "DeleteUser.Controller")
+	ctx, span := c.tracer.Start(r.Context(), "DeleteUser.Controller")
 	defer span.End()
 
 	deleteuser := model.UserDeleteDTO{}
@@ -362,6 +366,22 @@ func SendProblemDetails(w http.ResponseWriter, ptype ProblemType, errors []model
 		{
 			SendProblemDetailsCustom(w, "https://api.example.com/docs/unauthorized", "Unauthorized", "Authorization is required", route, errors, http.StatusUnauthorized)
 		}
+	case ProblemConflict:
+		{
+			SendProblemDetailsCustom(w, "https://api.example.com/docs/conflict", "Conflict", "The request conflicts with the current state of the resource", route, errors, http.StatusConflict)
+		}
+	case ProblemTooManyRequests:
+		{
+			SendProblemDetailsCustom(w, "https://api.example.com/docs/too-many-requests", "Too many requests", "Too many requests, retry later", route, errors, http.StatusTooManyRequests)
+		}
+	case ProblemLocked:
+		{
+			SendProblemDetailsCustom(w, "https://api.example.com/docs/locked", "Locked", "The resource is temporarily locked", route, errors, http.StatusLocked)
+		}
+	case ProblemServiceUnavailable:
+		{
+			SendProblemDetailsCustom(w, "https://api.example.com/docs/service-unavailable", "Service unavailable", "The service is temporarily unavailable, retry later", route, errors, http.StatusServiceUnavailable)
+		}
 	}
 }
 
@@ -383,10 +403,192 @@ func SendProblemDetailsCustom(w http.ResponseWriter, Type, title, details, insta
 
 func HandleServiceError(w http.ResponseWriter, r *http.Request, span trace.Span, err error, resource string) {
 	span.RecordError(err)
-	if errors.Is(err, repository.ErrNoRecord) {
+
+	var lockout *service.LockoutError
+	if errors.As(err, &lockout) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrNoRecord):
 		span.SetAttributes(attribute.Bool(resource+".found", false))
 		SendProblemDetails(w, ProblemNotFound, nil, r.URL.String())
-	} else {
+	case errors.Is(err, service.ErrNotOwner):
+		span.SetStatus(codes.Error, "not the owner")
+		SendProblemDetails(w, ProblemForbidden, []model.ProblemDetailsError{
+			{
+				Message: "Only the author or an admin can modify this " + resource,
+				Code:    "NOT_OWNER",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrForbidden):
+		span.SetStatus(codes.Error, "forbidden")
+		SendProblemDetails(w, ProblemForbidden, []model.ProblemDetailsError{
+			{
+				Message: "The caller is not allowed to do this",
+				Code:    "FORBIDDEN",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrInvalidPatch):
+		span.SetStatus(codes.Error, "invalid patch")
+		var patch *service.PatchError
+		errors.As(err, &patch)
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   patch.Field,
+				Message: "Only " + strings.Join(patch.Allowed, ", ") + " can be patched, with string values",
+				Code:    "INVALID_PATCH",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrInvalidCredentials):
+		span.SetStatus(codes.Error, "invalid credentials")
+		SendProblemDetails(w, ProblemUnauthorized, []model.ProblemDetailsError{
+			{
+				Message: "Username or password is incorrect",
+				Code:    "INVALID_CREDENTIALS",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrUsernameTaken):
+		span.SetStatus(codes.Error, "username taken")
+		SendProblemDetails(w, ProblemConflict, []model.ProblemDetailsError{
+			{
+				Field:   "username",
+				Message: "Username is already taken",
+				Code:    "USERNAME_TAKEN",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrEmailRequired):
+		span.SetStatus(codes.Error, "email required")
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "email",
+				Message: "The account has no email address, provide one",
+				Code:    "EMAIL_REQUIRED",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrEmailAlreadySet):
+		span.SetStatus(codes.Error, "email already set")
+		SendProblemDetails(w, ProblemConflict, []model.ProblemDetailsError{
+			{
+				Field:   "email",
+				Message: "The account already has a different email address",
+				Code:    "EMAIL_ALREADY_SET",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrEmailVerified):
+		span.SetStatus(codes.Error, "email already verified")
+		SendProblemDetails(w, ProblemConflict, []model.ProblemDetailsError{
+			{
+				Field:   "email",
+				Message: "Email address is already verified",
+				Code:    "EMAIL_ALREADY_VERIFIED",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrEmailTaken):
+		span.SetStatus(codes.Error, "email taken")
+		SendProblemDetails(w, ProblemConflict, []model.ProblemDetailsError{
+			{
+				Field:   "email",
+				Message: "Email is already registered",
+				Code:    "EMAIL_TAKEN",
+			},
+		}, r.URL.String())
+	case errors.Is(err, util.ErrPasswordTooLong):
+		span.SetStatus(codes.Error, "password too long")
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "password",
+				Message: "Password can be at most 72 bytes",
+				Code:    "PASSWORD_TOO_LONG",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrInvalidEmailToken):
+		span.SetStatus(codes.Error, "invalid email token")
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "token",
+				Message: "The link is invalid, expired or was already used",
+				Code:    "INVALID_TOKEN",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrUnsupportedGrantType):
+		span.SetStatus(codes.Error, "unsupported grant type")
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "grant_type",
+				Message: "The requested grant type is not supported",
+				Code:    "unsupported_grant_type",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrInvalidClient):
+		span.SetStatus(codes.Error, "invalid client")
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		SendProblemDetails(w, ProblemUnauthorized, []model.ProblemDetailsError{
+			{
+				Field:   "client_id",
+				Message: "Client authentication failed",
+				Code:    "invalid_client",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrInvalidScope):
+		span.SetStatus(codes.Error, "invalid scope")
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Field:   "scope",
+				Message: "The requested scope is invalid or exceeds what was granted",
+				Code:    "invalid_scope",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrInvalidGrant):
+		span.SetStatus(codes.Error, "invalid grant")
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Message: "The provided grant is invalid, expired or revoked",
+				Code:    "invalid_grant",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrMfaAlreadyEnabled):
+		span.SetStatus(codes.Error, "mfa already enabled")
+		SendProblemDetails(w, ProblemConflict, []model.ProblemDetailsError{
+			{
+				Message: "Two-factor authentication is already enabled",
+				Code:    "MFA_ALREADY_ENABLED",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrMfaNotEnrolled):
+		span.SetStatus(codes.Error, "mfa not enrolled")
+		SendProblemDetails(w, ProblemValidationError, []model.ProblemDetailsError{
+			{
+				Message: "Two-factor authentication has not been enrolled",
+				Code:    "MFA_NOT_ENROLLED",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrInvalidMfaCode):
+		span.SetStatus(codes.Error, "invalid mfa code")
+		SendProblemDetails(w, ProblemUnauthorized, []model.ProblemDetailsError{
+			{
+				Field:   "code",
+				Message: "The two-factor code is invalid or was already used",
+				Code:    "INVALID_MFA_CODE",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrTooManyAttempts):
+		span.SetStatus(codes.Error, "too many attempts")
+		SendProblemDetails(w, ProblemTooManyRequests, []model.ProblemDetailsError{
+			{
+				Message: "Too many failed login attempts from this client",
+				Code:    "TOO_MANY_ATTEMPTS",
+			},
+		}, r.URL.String())
+	case errors.Is(err, service.ErrAccountLocked):
+		span.SetStatus(codes.Error, "account locked")
+		SendProblemDetails(w, ProblemLocked, []model.ProblemDetailsError{
+			{
+				Message: "Account is temporarily locked after repeated failed logins",
+				Code:    "ACCOUNT_LOCKED",
+			},
+		}, r.URL.String())
+	default:
 		span.SetStatus(codes.Error, "internal server error")
 		SendProblemDetails(w, ProblemError, nil, r.URL.String())
 	}
@@ -0,0 +1,15 @@
+package controller
+
+import (
+	"github.com/abhinash-kml/go-api-server/pkg/util"
+	"github.com/go-playground/validator/v10"
+)
+
+// newValidator knows password_bytes, max=72 would count characters where bcrypt counts bytes
+func newValidator() *validator.Validate {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate.RegisterValidation("password_bytes", func(fl validator.FieldLevel) bool {
+		return util.PasswordFits(fl.Field().String())
+	})
+	return validate
+}
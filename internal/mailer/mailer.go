@@ -0,0 +1,30 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abhinash-kml/go-api-server/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // Plain text
+}
+
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// New picks the implementation named by mail.driver
+func New(cfg *config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "", "outbox":
+		return NewOutboxMailer(cfg.From, cfg.OutboxDir), nil
+	case "smtp":
+		return NewSmtpMailer(cfg.From, &cfg.Smtp)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxMailer keeps every message and, given a directory, writes each one there as an .eml file.
+// It never sends anything, which makes it the driver for local runs and tests.
+type OutboxMailer struct {
+	from string
+	dir  string
+	sent []Message
+	mu   sync.Mutex
+}
+
+func NewOutboxMailer(from, dir string) *OutboxMailer {
+	return &OutboxMailer{from: from, dir: dir}
+}
+
+func (m *OutboxMailer) Send(ctx context.Context, message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent = append(m.sent, message)
+
+	if m.dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405Z"), uuid.NewString())
+	return os.WriteFile(filepath.Join(m.dir, name), compose(m.from, message), 0o600)
+}
+
+// Sent returns a copy of every message so far, oldest first
+func (m *OutboxMailer) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message(nil), m.sent...)
+}
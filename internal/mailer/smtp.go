@@ -0,0 +1,64 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+)
+
+type SmtpMailer struct {
+	from string
+	addr string
+	auth smtp.Auth
+}
+
+func NewSmtpMailer(from string, cfg *config.SmtpConfig) (*SmtpMailer, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("mail.smtp.host is required")
+	}
+
+	mailer := &SmtpMailer{
+		from: from,
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+	}
+
+	// PlainAuth refuses to send credentials over an unencrypted connection to anything but localhost
+	if cfg.Username != "" {
+		mailer.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return mailer, nil
+}
+
+// Send uses STARTTLS whenever the server offers it, net/smtp takes no context so ctx is only checked up front
+func (m *SmtpMailer) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{message.To}, compose(m.from, message))
+}
+
+// compose renders a minimal RFC 5322 plain text message
+func compose(from string, message Message) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", message.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return buf.Bytes()
+}
@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	service "github.com/abhinash-kml/go-api-server/internal/services"
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"github.com/abhinash-kml/go-api-server/pkg/ratelimiter"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// quotaApiKeys knows a single key with a quota of one request
+type quotaApiKeys struct {
+	service.ApiKeyService
+	consumed int
+}
+
+func (s *quotaApiKeys) Authenticate(ctx context.Context, plain string) (*model.ApiKey, error) {
+	if plain != "partner-key" {
+		return nil, service.ErrInvalidApiKey
+	}
+	return &model.ApiKey{Id: 1, Prefix: "partner", DailyQuota: 1}, nil
+}
+
+func (s *quotaApiKeys) ConsumeQuota(ctx context.Context, key *model.ApiKey) (ratelimiter.Decision, error) {
+	s.consumed++
+	return ratelimiter.Decision{Allowed: s.consumed <= 1, Limit: 1, ResetAt: time.Now().Add(time.Hour)}, nil
+}
+
+func TestApiKeyReadRoute(t *testing.T) {
+	keys := &quotaApiKeys{}
+	limits := map[string]*RateLimitPolicy{
+		DefaultRateLimitPolicy: {Name: DefaultRateLimitPolicy, Limiter: ratelimiter.NewFixedWindowLimiter(time.Minute, 100, clock.System{}), Key: ipKey},
+		"reads":                {Name: "reads", Limiter: ratelimiter.NewFixedWindowLimiter(time.Minute, 100, clock.System{}), Key: apiKeyKey, ByPrincipal: true},
+	}
+	m := NewMiddlewareProvider(noop.NewTracerProvider().Tracer(""), &config.AuthTokenConfig{}, nil, nil, WithLimits(limits), WithApiKeys(keys))
+
+	var limitedBy string
+	handler := m.CompileHandlers(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limitedBy = apiKeyKey(r)
+	}), m.ApiKeyAuthorization, m.RateLimitPolicy("reads"), m.ApiKeyQuota)
+
+	tests := []struct {
+		name          string
+		key           string
+		authorization string
+		wantStatus    int
+		wantKey       string
+	}{
+		{name: "key", key: "partner-key", wantStatus: http.StatusOK, wantKey: "apikey:1"},
+		{name: "junk Authorization header doesn't skip the quota", key: "partner-key", authorization: "Bearer junk", wantStatus: http.StatusTooManyRequests},
+		{name: "invalid key", key: "wrong", authorization: "Bearer junk", wantStatus: http.StatusUnauthorized},
+		{name: "anonymous callers are limited by ip", wantStatus: http.StatusOK, wantKey: "ip:192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limitedBy = ""
+			r := httptest.NewRequest(http.MethodGet, "/posts", nil)
+			if tt.key != "" {
+				r.Header.Set(ApiKeyHeader, tt.key)
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if limitedBy != tt.wantKey {
+				t.Errorf("rate limit key = %q, want %q", limitedBy, tt.wantKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"crypto/x509"
+	"net/http"
+	"slices"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ClientCertificate maps a verified client certificate to the registered client listing one of its identities.
+// Requests without one, or with one no client claims, pass through unauthenticated.
+func (m *MiddlewareProvider) ClientCertificate(next http.Handler) http.Handler {
+	return authenticating{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only chains built against client_ca_file count, PeerCertificates alone are unverified
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, span := m.tracer.Start(r.Context(), "middleware.ClientCertificate")
+		defer span.End()
+
+		leaf := r.TLS.VerifiedChains[0][0]
+		client, identity, ok := m.certificateClient(leaf)
+		span.SetAttributes(attribute.String("tls.client.subject", leaf.Subject.String()),
+			attribute.Bool("auth.mapped", ok))
+		if !ok {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		principal := &policy.Principal{
+			Kind:               policy.PrincipalClient,
+			Subject:            policy.ClientSubjectPrefix + client.Id,
+			ClientID:           client.Id,
+			Scopes:             client.Scopes,
+			CertificateSubject: identity,
+			ExpiresAt:          leaf.NotAfter,
+		}
+		span.SetAttributes(attribute.String("auth.subject", principal.Subject),
+			attribute.String("auth.kind", string(principal.Kind)))
+
+		next.ServeHTTP(w, r.WithContext(policy.WithPrincipal(ctx, principal)))
+	})}
+}
+
+// certificateClient checks URI SANs, then DNS SANs, then the subject CN against each client's certificate_subjects
+func (m *MiddlewareProvider) certificateClient(cert *x509.Certificate) (*config.ClientConfig, string, bool) {
+	identities := make([]string, 0, len(cert.URIs)+len(cert.DNSNames)+1)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	identities = append(identities, cert.DNSNames...)
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+
+	for _, identity := range identities {
+		for i := range m.authConfig.Clients {
+			client := &m.authConfig.Clients[i]
+			if slices.Contains(client.CertificateSubjects, identity) {
+				return client, identity, true
+			}
+		}
+	}
+
+	return nil, "", false
+}
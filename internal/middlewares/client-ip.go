@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/abhinash-kml/go-api-server/pkg/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ClientIP resolves the real client address once per request, util.ClientIP reads it back everywhere else
+func (m *MiddlewareProvider) ClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := m.ipResolver.Resolve(r)
+
+		// otelhttp fills client.address from X-Forwarded-For without checking who set it
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("client.address", ip))
+
+		next.ServeHTTP(w, r.WithContext(util.WithClientIP(r.Context(), ip)))
+	})
+}
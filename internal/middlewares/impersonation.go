@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"net/http"
+
+	controller "github.com/abhinash-kml/go-api-server/internal/controllers"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// DenyImpersonation must be chained after JwtAuthorization, it keeps act-as tokens away from credential, session and account changes
+func (m *MiddlewareProvider) DenyImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := m.tracer.Start(r.Context(), "middleware.DenyImpersonation")
+		defer span.End()
+
+		principal, ok := policy.PrincipalFromContext(ctx)
+		if !ok {
+			span.SetStatus(codes.Error, "no principal")
+			sendUnauthorized(w, r, "Authentication is required", "MISSING_TOKEN")
+			return
+		}
+
+		if principal.IsImpersonated() {
+			span.SetAttributes(attribute.String("auth.actor", principal.Actor))
+			span.SetStatus(codes.Error, "impersonated token")
+			controller.SendProblemDetails(w, controller.ProblemForbidden, []model.ProblemDetailsError{
+				{
+					Field:   "Authorization",
+					Message: "Not allowed while impersonating a user",
+					Code:    "IMPERSONATION_DENIED",
+				},
+			}, r.URL.String())
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -1,68 +1,134 @@
 package middlewares
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
+	controller "github.com/abhinash-kml/go-api-server/internal/controllers"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	"github.com/abhinash-kml/go-api-server/pkg/util"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
+// authFailure describes why a token was rejected, reason goes to the span and message/code to the client
+type authFailure struct {
+	reason  string
+	message string
+	code    string
+}
+
 func (m *MiddlewareProvider) JwtAuthorization(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return authenticating{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx, span := m.tracer.Start(r.Context(), "middleware.JwtAuth")
 		defer span.End()
 
 		// Auth logic
 		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Missing Authorization Header", http.StatusUnauthorized)
-		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) < 2 || len(parts) > 2 || parts[0] != "Bearer" {
-			http.Error(w, "Bad token format", http.StatusUnauthorized)
+		// A client certificate mapped by ClientCertificate or a key from ApiKeyAuthorization stands in for a token,
+		// an explicit token still wins
+		if principal, ok := policy.PrincipalFromContext(ctx); ok && authHeader == "" && (principal.CertificateSubject != "" || principal.IsApiKey()) {
+			method := "mtls"
+			if principal.IsApiKey() {
+				method = "api_key"
+			}
+			span.SetAttributes(attribute.String("auth.subject", principal.Subject),
+				attribute.String("auth.method", method))
+			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
 
-		tokenString := parts[1]
-		span.SetAttributes(attribute.String("token", tokenString))
-
-		tempClaims := &jwt.RegisteredClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, tempClaims, func(t *jwt.Token) (any, error) {
-			return []byte("my-special-secret"), nil
-		},
-			jwt.WithIssuer("my-app"),
-			jwt.WithExpirationRequired(),
-			jwt.WithNotBeforeRequired(),
-			jwt.WithAllAudiences("www.myapp.com"))
-
-		if err != nil {
-			http.Error(w, fmt.Sprintf("%+v", err), http.StatusUnauthorized)
-			fmt.Printf("JWT Error: %+v\n", err)
-			span.RecordError(err)
-			span.SetStatus(codes.Error, "token parsing failed")
+		if authHeader == "" {
+			span.SetStatus(codes.Error, "missing authorization header")
+			sendUnauthorized(w, r, "Missing Authorization header", "MISSING_TOKEN")
 			return
 		}
 
-		if !token.Valid {
-			http.Error(w, "Token is now valid meow meow", http.StatusUnauthorized)
-			span.SetAttributes(attribute.Bool("token.valid", false))
+		token, ok := bearerToken(authHeader)
+		if !ok {
+			span.SetStatus(codes.Error, "bad authorization header format")
+			sendUnauthorized(w, r, "Authorization header must be of the form 'Bearer <token>'", "MALFORMED_TOKEN")
 			return
 		}
 
-		claims, ok := token.Claims.(*jwt.RegisteredClaims)
-		if !ok {
-			http.Error(w, "Failed to parse jwt token meow meow", http.StatusUnauthorized)
-			span.SetAttributes(attribute.Bool("token.ourcustomtype", false))
+		principal, failure := m.authenticate(ctx, span, token)
+		if failure != nil {
+			span.SetStatus(codes.Error, failure.reason)
+			sendUnauthorized(w, r, failure.message, failure.code)
 			return
 		}
 
-		// Do real work here
-		fmt.Println(claims)
+		// Everything done while impersonating has to be traceable back to the admin
+		if principal.IsImpersonated() {
+			span.SetAttributes(attribute.String("auth.actor", principal.Actor))
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("auth.actor", principal.Actor))
+			zap.L().Info("Impersonated request",
+				zap.String("subject", principal.Subject),
+				zap.String("actor", principal.Actor),
+				zap.String("jti", principal.TokenID),
+				zap.String("Method", r.Method),
+				zap.String("Path", r.Pattern))
+		}
+
+		next.ServeHTTP(w, r.WithContext(policy.WithPrincipal(ctx, principal)))
+	})}
+}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+func bearerToken(authHeader string) (string, bool) {
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// authenticate verifies an access token and checks it against the denylist and the subject's token version
+func (m *MiddlewareProvider) authenticate(ctx context.Context, span trace.Span, token string) (*policy.Principal, *authFailure) {
+	claims := &model.CustomJwtClaims{}
+	if _, err := util.VerifyJwtToken(&m.authConfig.AccessToken, token, claims); err != nil {
+		span.RecordError(err)
+		return nil, &authFailure{"token verification failed", "Token is invalid or expired", "INVALID_TOKEN"}
+	}
+
+	// Refresh tokens are signed with the same config, they must never authorize a request
+	if claims.TokenUse != model.TokenUseAccess {
+		return nil, &authFailure{"not an access token", "Token is not an access token", "INVALID_TOKEN"}
+	}
+
+	// Fail closed when the denylist can't be reached
+	if denied, err := m.denylist.IsDenied(ctx, claims.ID); err != nil || denied {
+		return nil, &authFailure{"token revoked", "Token has been revoked", "REVOKED_TOKEN"}
+	}
+
+	if !policy.IsJwtValidByVersion(ctx, claims.Subject, claims.Version, m.tokens) {
+		return nil, &authFailure{"token version outdated", "Token has been revoked", "REVOKED_TOKEN"}
+	}
+
+	// A revoked session takes its outstanding access tokens with it
+	if claims.SessionID != "" && policy.IsTokenFamilyRevoked(ctx, claims.SessionID, m.tokens) {
+		return nil, &authFailure{"session revoked", "Session has been revoked", "REVOKED_TOKEN"}
+	}
+
+	principal := policy.NewPrincipalFromClaims(claims)
+	span.SetAttributes(attribute.String("auth.subject", principal.Subject),
+		attribute.String("auth.kind", string(principal.Kind)),
+		attribute.String("auth.role", principal.Role))
+
+	return principal, nil
+}
+
+func sendUnauthorized(w http.ResponseWriter, r *http.Request, message, code string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	controller.SendProblemDetails(w, controller.ProblemUnauthorized, []model.ProblemDetailsError{
+		{
+			Field:   "Authorization",
+			Message: message,
+			Code:    code,
+		},
+	}, r.URL.String())
 }
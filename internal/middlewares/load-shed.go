@@ -0,0 +1,152 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	controller "github.com/abhinash-kml/go-api-server/internal/controllers"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"github.com/abhinash-kml/go-api-server/pkg/ratelimiter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// ConcurrencyLimits holds an adaptive in-flight cap per route class
+type ConcurrencyLimits struct {
+	classes  map[string]*ratelimiter.AIMDLimiter
+	priority []*ratelimiter.AIMDLimiter
+	rejected metric.Int64Counter
+}
+
+// NewConcurrencyLimits builds every configured class and exports their caps, in-flight counts and rejections
+func NewConcurrencyLimits(cfg *config.ConcurrencyConfig) (*ConcurrencyLimits, error) {
+	target, err := time.ParseDuration(cfg.LatencyTarget)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latency_target: %w", err)
+	}
+
+	limits := &ConcurrencyLimits{classes: make(map[string]*ratelimiter.AIMDLimiter, len(cfg.Classes))}
+	for name, class := range cfg.Classes {
+		if class.Min <= 0 || class.Min > class.Initial || class.Initial > class.Max {
+			return nil, fmt.Errorf("concurrency class %q needs 0 < min <= initial <= max", name)
+		}
+		limits.classes[name] = ratelimiter.NewAIMDLimiter(class.Initial, class.Min, class.Max, target, clock.System{})
+		if class.Priority {
+			limits.priority = append(limits.priority, limits.classes[name])
+		}
+	}
+
+	meter := otel.Meter("middlewares")
+	if limits.rejected, err = meter.Int64Counter("http.server.concurrency.rejected",
+		metric.WithDescription("Requests shed because their route class was at its concurrency limit"),
+		metric.WithUnit("{request}")); err != nil {
+		return nil, err
+	}
+
+	if _, err := meter.Int64ObservableGauge("http.server.concurrency.limit",
+		metric.WithDescription("Current adaptive concurrency limit per route class"),
+		metric.WithUnit("{request}"),
+		metric.WithInt64Callback(limits.observe((*ratelimiter.AIMDLimiter).Limit))); err != nil {
+		return nil, err
+	}
+
+	if _, err := meter.Int64ObservableGauge("http.server.concurrency.inflight",
+		metric.WithDescription("Requests in flight per route class"),
+		metric.WithUnit("{request}"),
+		metric.WithInt64Callback(limits.observe((*ratelimiter.AIMDLimiter).Inflight))); err != nil {
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+// yieldsTo reports whether a request of a regular class should make way for a full priority class
+func (c *ConcurrencyLimits) yieldsTo(limiter *ratelimiter.AIMDLimiter) bool {
+	if slices.Contains(c.priority, limiter) {
+		return false
+	}
+	return slices.ContainsFunc(c.priority, (*ratelimiter.AIMDLimiter).Saturated)
+}
+
+func (c *ConcurrencyLimits) observe(value func(*ratelimiter.AIMDLimiter) int) metric.Int64Callback {
+	return func(ctx context.Context, observer metric.Int64Observer) error {
+		for name, limiter := range c.classes {
+			observer.Observe(int64(value(limiter)), metric.WithAttributes(attribute.String("route.class", name)))
+		}
+		return nil
+	}
+}
+
+// LoadShed caps in-flight requests of a route class and answers the excess with 503,
+// an unconfigured class isn't limited. Requests turned away with a 429 further down don't count as latency samples.
+func (m *MiddlewareProvider) LoadShed(class string) MiddleWareFunc {
+	if m.concurrency == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	limiter, ok := m.concurrency.classes[class]
+	if !ok {
+		zap.L().Warn("Concurrency class not configured, not shedding", zap.String("class", class))
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	classAttribute := metric.WithAttributes(attribute.String("route.class", class))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := m.tracer.Start(r.Context(), "middleware.LoadShed")
+			defer span.End()
+
+			span.SetAttributes(attribute.String("route.class", class))
+
+			if m.concurrency.yieldsTo(limiter) || !limiter.Acquire() {
+				m.concurrency.rejected.Add(ctx, 1, classAttribute)
+				span.SetStatus(codes.Error, "load shed")
+				w.Header().Set("Retry-After", "1")
+				controller.SendProblemDetails(w, controller.ProblemServiceUnavailable, []model.ProblemDetailsError{
+					{
+						Message: "The server is overloaded, try again shortly",
+						Code:    "OVERLOADED",
+					},
+				}, r.URL.String())
+				return
+			}
+
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				// An instant rejection says nothing about how loaded the backend is
+				if recorder.status == http.StatusTooManyRequests {
+					limiter.Cancel()
+					return
+				}
+				limiter.Release(time.Since(start))
+			}()
+
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+		})
+	}
+}
+
+// statusRecorder keeps the status the rest of the chain answered with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -3,6 +3,7 @@ package middlewares
 import (
 	"net/http"
 
+	"github.com/abhinash-kml/go-api-server/pkg/util"
 	"go.uber.org/zap"
 )
 
@@ -11,7 +12,7 @@ func (m *MiddlewareProvider) Logger(next http.Handler) http.Handler {
 		ctx, span := m.tracer.Start(r.Context(), "middleare.Logger")
 		defer span.End()
 
-		zap.L().Info("Connection", zap.String("IP", r.RemoteAddr), zap.String("Method", r.Method), zap.String("Path", r.Pattern))
+		zap.L().Info("Connection", zap.String("IP", util.ClientIP(r)), zap.String("Method", r.Method), zap.String("Path", r.Pattern))
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
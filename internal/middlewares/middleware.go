@@ -1,26 +1,113 @@
 package middlewares
 
 import (
+	"fmt"
 	"net/http"
 
+	"github.com/abhinash-kml/go-api-server/config"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	service "github.com/abhinash-kml/go-api-server/internal/services"
+	"github.com/abhinash-kml/go-api-server/pkg/util"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type MiddlewareProvider struct {
-	tracer trace.Tracer
+	tracer      trace.Tracer
+	authConfig  *config.AuthTokenConfig
+	tokens      policy.TokenStore
+	denylist    policy.JwtDenylist
+	rbac        *policy.RBAC
+	limits      map[string]*RateLimitPolicy
+	ipResolver  *util.ClientIPResolver
+	concurrency *ConcurrencyLimits
+	apiKeys     service.ApiKeyService
 }
 
-func NewMiddlewareProvider(tracer trace.Tracer) *MiddlewareProvider {
-	return &MiddlewareProvider{tracer: tracer}
+type ProviderOption func(*MiddlewareProvider)
+
+// NewMiddlewareProvider without options rate limits every route in memory with the built in default,
+// trusts no proxies, sheds nothing, ignores X-API-Key and lets RequirePermission deny every user
+func NewMiddlewareProvider(tracer trace.Tracer, authConfig *config.AuthTokenConfig, tokens policy.TokenStore, denylist policy.JwtDenylist, options ...ProviderOption) *MiddlewareProvider {
+	provider := &MiddlewareProvider{
+		tracer:     tracer,
+		authConfig: authConfig,
+		tokens:     tokens,
+		denylist:   denylist,
+		limits:     defaultRateLimitPolicies,
+	}
+
+	for _, option := range options {
+		option(provider)
+	}
+
+	return provider
+}
+
+func WithRbac(rbac *policy.RBAC) ProviderOption {
+	return func(m *MiddlewareProvider) {
+		m.rbac = rbac
+	}
+}
+
+// WithLimits ignores a nil map so the built in default stays in place
+func WithLimits(limits map[string]*RateLimitPolicy) ProviderOption {
+	return func(m *MiddlewareProvider) {
+		if limits != nil {
+			m.limits = limits
+		}
+	}
+}
+
+func WithIPResolver(resolver *util.ClientIPResolver) ProviderOption {
+	return func(m *MiddlewareProvider) {
+		m.ipResolver = resolver
+	}
+}
+
+func WithConcurrency(concurrency *ConcurrencyLimits) ProviderOption {
+	return func(m *MiddlewareProvider) {
+		m.concurrency = concurrency
+	}
+}
+
+func WithApiKeys(apiKeys service.ApiKeyService) ProviderOption {
+	return func(m *MiddlewareProvider) {
+		m.apiKeys = apiKeys
+	}
 }
 
 type MiddleWareFunc func(http.Handler) http.Handler
 
+// authenticating marks a handler that puts the caller's principal on the context
+type authenticating struct {
+	http.Handler
+}
+
+// principalKeyed marks a rate limiter counting by principal, without an authenticating handler ahead of it every request counts by ip
+type principalKeyed struct {
+	http.Handler
+	policy string
+}
+
+// CompileHandlers chains middlewares, the first one runs first.
+// Like ServeMux it panics on a route that can't work, a principal keyed rate limit with no authentication before it.
 func (MiddlewareProvider) CompileHandlers(base http.Handler, middlewares ...MiddleWareFunc) http.Handler {
 	final := base
+	unauthenticated := ""
 
 	for i := len(middlewares) - 1; i >= 0; i-- {
 		final = middlewares[i](final)
+
+		switch handler := final.(type) {
+		case principalKeyed:
+			unauthenticated = handler.policy
+		case authenticating:
+			unauthenticated = ""
+		}
+	}
+
+	if unauthenticated != "" {
+		panic(fmt.Sprintf("rate limit policy %q is keyed by principal but no authentication runs before it", unauthenticated))
 	}
 
 	return final
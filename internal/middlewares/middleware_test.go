@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"github.com/abhinash-kml/go-api-server/pkg/ratelimiter"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestCompileHandlersRequiresAuthenticationForPrincipalKeys(t *testing.T) {
+	limits := map[string]*RateLimitPolicy{
+		DefaultRateLimitPolicy: {Name: DefaultRateLimitPolicy, Limiter: ratelimiter.NewFixedWindowLimiter(time.Minute, 10, clock.System{}), Key: ipKey},
+		"writes":               {Name: "writes", Limiter: ratelimiter.NewFixedWindowLimiter(time.Minute, 10, clock.System{}), Key: subjectKey, ByPrincipal: true},
+	}
+	m := NewMiddlewareProvider(noop.NewTracerProvider().Tracer(""), &config.AuthTokenConfig{}, nil, nil, WithLimits(limits))
+	base := http.NotFoundHandler()
+
+	tests := []struct {
+		name        string
+		middlewares []MiddleWareFunc
+		wantPanic   bool
+	}{
+		{name: "ip keyed without authentication", middlewares: []MiddleWareFunc{m.Logger, m.RateLimit}},
+		{name: "after JwtAuthorization", middlewares: []MiddleWareFunc{m.Logger, m.JwtAuthorization, m.RateLimitPolicy("writes")}},
+		{name: "after ApiKeyAuthorization", middlewares: []MiddleWareFunc{m.ApiKeyAuthorization, m.RateLimitPolicy("writes"), m.ApiKeyQuota}},
+		{name: "no authentication", middlewares: []MiddleWareFunc{m.Logger, m.RateLimitPolicy("writes")}, wantPanic: true},
+		{name: "authentication after the limiter", middlewares: []MiddleWareFunc{m.RateLimitPolicy("writes"), m.JwtAuthorization}, wantPanic: true},
+		{name: "second limiter after authentication", middlewares: []MiddleWareFunc{m.RateLimitPolicy("writes"), m.JwtAuthorization, m.RateLimitPolicy("writes")}, wantPanic: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recovered := recover(); (recovered != nil) != tt.wantPanic {
+					t.Errorf("CompileHandlers() panic = %v, want panic %v", recovered, tt.wantPanic)
+				}
+			}()
+
+			m.CompileHandlers(base, tt.middlewares...)
+		})
+	}
+}
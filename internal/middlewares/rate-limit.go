@@ -1,33 +1,237 @@
 package middlewares
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/abhinash-kml/go-api-server/config"
+	controller "github.com/abhinash-kml/go-api-server/internal/controllers"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
 	"github.com/abhinash-kml/go-api-server/pkg/ratelimiter"
+	"github.com/abhinash-kml/go-api-server/pkg/util"
+	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
 )
 
-var limiter = ratelimiter.FixedWindowLimiter{
-	WindowDuration: time.Second * 10,
-	LimitPerWindow: 5,
-	Table:          make(map[string]*ratelimiter.ClientInfo),
+// DefaultRateLimitPolicy is what RateLimit uses, and what a route falls back to when its policy isn't configured
+const DefaultRateLimitPolicy = "default"
+
+// KeyExtractor picks the identity a request is counted against
+type KeyExtractor func(*http.Request) string
+
+// RateLimitPolicy is a named limiter and how requests are keyed into it
+type RateLimitPolicy struct {
+	Name    string
+	Limiter ratelimiter.Limiter
+	Key     KeyExtractor
+	// ByPrincipal policies need authentication earlier in the chain, CompileHandlers enforces it
+	ByPrincipal bool
+}
+
+// Used when the server was given no policies
+var defaultRateLimitPolicies = map[string]*RateLimitPolicy{
+	DefaultRateLimitPolicy: {
+		Name:    DefaultRateLimitPolicy,
+		Limiter: ratelimiter.NewFixedWindowLimiter(time.Second*10, 5, clock.System{}),
+		Key:     ipKey,
+	},
+}
+
+func ipKey(r *http.Request) string {
+	return "ip:" + util.ClientIP(r)
+}
+
+// subjectKey counts authenticated callers by who they are, so it has to run after JwtAuthorization
+func subjectKey(r *http.Request) string {
+	if principal, ok := policy.PrincipalFromContext(r.Context()); ok {
+		return "sub:" + principal.Subject
+	}
+	return ipKey(r)
+}
+
+// apiKeyKey counts machine callers by the credential they authenticated with, anyone else by ip.
+// It has to run after ApiKeyAuthorization or JwtAuthorization.
+func apiKeyKey(r *http.Request) string {
+	principal, ok := policy.PrincipalFromContext(r.Context())
+	switch {
+	case ok && principal.IsApiKey():
+		return principal.Subject
+	case ok && principal.IsClient():
+		return "client:" + principal.ClientID
+	default:
+		return ipKey(r)
+	}
+}
+
+func keyExtractor(name string) (KeyExtractor, error) {
+	switch name {
+	case "", "ip":
+		return ipKey, nil
+	case "subject":
+		return subjectKey, nil
+	case "api_key":
+		return apiKeyKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported key %q", name)
+	}
+}
+
+// NewRateLimitPolicies builds every configured policy, with the redis store the local limiters are the fallback
+func NewRateLimitPolicies(cfg *config.RateLimitConfig, rdb *redis.Client) (map[string]*RateLimitPolicy, error) {
+	if _, ok := cfg.Policies[DefaultRateLimitPolicy]; !ok {
+		return nil, fmt.Errorf("rate_limit policy %q is required", DefaultRateLimitPolicy)
+	}
+
+	cooldown, err := time.ParseDuration(cfg.FallbackCooldown)
+	if err != nil || cooldown <= 0 {
+		return nil, fmt.Errorf("rate_limit fallback_cooldown must be a positive duration")
+	}
+
+	policies := make(map[string]*RateLimitPolicy, len(cfg.Policies))
+	for name, policyConfig := range cfg.Policies {
+		limitPolicy, err := newRateLimitPolicy(name, cfg, policyConfig, cooldown, rdb)
+		if err != nil {
+			return nil, fmt.Errorf("rate_limit policy %q: %w", name, err)
+		}
+		policies[name] = limitPolicy
+	}
+
+	return policies, nil
+}
+
+func newRateLimitPolicy(name string, cfg *config.RateLimitConfig, policyConfig config.RateLimitPolicyConfig, cooldown time.Duration, rdb *redis.Client) (*RateLimitPolicy, error) {
+	window, err := time.ParseDuration(policyConfig.Window)
+	if err != nil {
+		return nil, fmt.Errorf("invalid window: %w", err)
+	}
+	if policyConfig.Limit <= 0 || window <= 0 {
+		return nil, fmt.Errorf("limit and window must be positive")
+	}
+
+	key, err := keyExtractor(policyConfig.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm := policyConfig.Algorithm
+	if algorithm == "" {
+		algorithm = cfg.Algorithm
+	}
+
+	var local ratelimiter.LocalLimiter
+	var distributed ratelimiter.Limiter
+	switch algorithm {
+	case "fixed_window":
+		local = ratelimiter.NewFixedWindowLimiter(window, policyConfig.Limit, clock.System{})
+		distributed = ratelimiter.NewRedisFixedWindowLimiter(rdb, window, policyConfig.Limit)
+	case "token_bucket":
+		refill := window / time.Duration(policyConfig.Limit)
+		local = ratelimiter.NewTokenBucketLimiter(policyConfig.Limit, refill, clock.System{})
+		distributed = ratelimiter.NewRedisTokenBucketLimiter(rdb, policyConfig.Limit, refill)
+	case "sliding_window_log":
+		local = ratelimiter.NewSlidingWindowLogLimiter(window, policyConfig.Limit, clock.System{})
+	case "sliding_window_counter":
+		local = ratelimiter.NewSlidingWindowCounterLimiter(window, policyConfig.Limit, clock.System{})
+	case "gcra":
+		local = ratelimiter.NewGCRALimiter(window, policyConfig.Limit, clock.System{})
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	go local.AutoEvict(max(window, time.Minute))
+
+	limitPolicy := &RateLimitPolicy{
+		Name:        name,
+		Limiter:     local,
+		Key:         key,
+		ByPrincipal: policyConfig.Key == "subject" || policyConfig.Key == "api_key",
+	}
+	switch cfg.Store {
+	case "memory":
+	case "redis":
+		if distributed == nil {
+			return nil, fmt.Errorf("algorithm %q has no redis store", algorithm)
+		}
+		limitPolicy.Limiter = ratelimiter.NewFallbackLimiter(distributed, local, cooldown, clock.System{})
+	default:
+		return nil, fmt.Errorf("unsupported store %q", cfg.Store)
+	}
+
+	return limitPolicy, nil
 }
 
+// RateLimit applies the default policy
 func (m *MiddlewareProvider) RateLimit(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx, span := m.tracer.Start(r.Context(), "middleware.RateLimit")
-		defer span.End()
-
-		// Rate limit logic
-		if !limiter.Allow(r.RemoteAddr) {
-			span.SetAttributes(attribute.Bool("ratelimited", true))
-			http.Error(w, "Rate Limited", http.StatusTooManyRequests)
-			return
+	return m.RateLimitPolicy(DefaultRateLimitPolicy)(next)
+}
+
+// RateLimitPolicy applies a named policy, subject and api_key policies must be chained after JwtAuthorization or ApiKeyAuthorization
+func (m *MiddlewareProvider) RateLimitPolicy(name string) MiddleWareFunc {
+	limitPolicy, ok := m.limits[name]
+	if !ok {
+		zap.L().Warn("Rate limit policy not configured, using default", zap.String("policy", name))
+		limitPolicy = m.limits[DefaultRateLimitPolicy]
+	}
+
+	return func(next http.Handler) http.Handler {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := m.tracer.Start(r.Context(), "middleware.RateLimit")
+			defer span.End()
+
+			span.SetAttributes(attribute.String("ratelimit.policy", limitPolicy.Name))
+
+			// Policies don't share counters even when they key by the same identity
+			decision, err := limitPolicy.Limiter.Allow(ctx, limitPolicy.Name+":"+limitPolicy.Key(r))
+			if err != nil {
+				// A limiter that can't decide lets the request through
+				span.RecordError(err)
+				zap.L().Warn("Rate limiter failed", zap.String("policy", limitPolicy.Name), zap.Error(err))
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			setRateLimitHeaders(w, decision)
+			span.SetAttributes(attribute.Bool("ratelimited", !decision.Allowed),
+				attribute.Int("ratelimit.remaining", decision.Remaining))
+
+			if !decision.Allowed {
+				span.SetStatus(codes.Error, "rate limited")
+				controller.SendProblemDetails(w, controller.ProblemTooManyRequests, []model.ProblemDetailsError{
+					{
+						Message: "Too many requests, slow down",
+						Code:    "RATE_LIMITED",
+					},
+				}, r.URL.String())
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+
+		if limitPolicy.ByPrincipal {
+			return principalKeyed{handler, limitPolicy.Name}
 		}
+		return handler
+	}
+}
 
-		span.SetAttributes(attribute.Bool("ratelimited", false))
+// setRateLimitHeaders follows the IETF RateLimit header fields draft, times are delta seconds
+func setRateLimitHeaders(w http.ResponseWriter, decision ratelimiter.Decision) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(deltaSeconds(time.Until(decision.ResetAt))))
+	if !decision.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(max(deltaSeconds(decision.RetryAfter), 1)))
+	}
+}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+func deltaSeconds(d time.Duration) int {
+	return int(math.Ceil(max(d, 0).Seconds()))
 }
@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	controller "github.com/abhinash-kml/go-api-server/internal/controllers"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// RequirePermission must be chained after JwtAuthorization, users are checked by role and other principals by scope
+func (m *MiddlewareProvider) RequirePermission(permissions ...policy.Permission) MiddleWareFunc {
+	required := make([]string, len(permissions))
+	for i, permission := range permissions {
+		required[i] = string(permission)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := m.tracer.Start(r.Context(), "middleware.RequirePermission")
+			defer span.End()
+
+			span.SetAttributes(attribute.StringSlice("rbac.required", required))
+
+			principal, ok := policy.PrincipalFromContext(ctx)
+			if !ok {
+				span.SetStatus(codes.Error, "no principal")
+				sendUnauthorized(w, r, "Authentication is required", "MISSING_TOKEN")
+				return
+			}
+
+			// Clients and API keys have no role, the scopes they were granted name the same "resource:action" pairs
+			if principal.Kind != policy.PrincipalUser {
+				if !policy.HasScopes(principal.Scopes, required...) {
+					span.SetStatus(codes.Error, "permission denied")
+					controller.SendProblemDetails(w, controller.ProblemForbidden, []model.ProblemDetailsError{
+						{
+							Field:   "scope",
+							Message: "Credential lacks permission " + strings.Join(required, ", "),
+							Code:    "PERMISSION_DENIED",
+						},
+					}, r.URL.String())
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			// No engine configured means nothing is granted
+			if m.rbac == nil || !m.rbac.CanAll(principal.Role, permissions...) {
+				span.SetStatus(codes.Error, "permission denied")
+				controller.SendProblemDetails(w, controller.ProblemForbidden, []model.ProblemDetailsError{
+					{
+						Field:   "role",
+						Message: "Role '" + principal.Role + "' lacks permission " + strings.Join(required, ", "),
+						Code:    "PERMISSION_DENIED",
+					},
+				}, r.URL.String())
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
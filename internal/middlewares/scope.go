@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	controller "github.com/abhinash-kml/go-api-server/internal/controllers"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// RequireScopes must be chained after JwtAuthorization, the token has to carry every listed scope
+func (m *MiddlewareProvider) RequireScopes(scopes ...string) MiddleWareFunc {
+	required := strings.Join(scopes, " ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := m.tracer.Start(r.Context(), "middleware.RequireScopes")
+			defer span.End()
+
+			span.SetAttributes(attribute.StringSlice("scope.required", scopes))
+
+			principal, ok := policy.PrincipalFromContext(ctx)
+			if !ok {
+				span.SetStatus(codes.Error, "no principal")
+				sendUnauthorized(w, r, "Authentication is required", "MISSING_TOKEN")
+				return
+			}
+
+			if !policy.HasScopes(principal.Scopes, scopes...) {
+				span.SetStatus(codes.Error, "insufficient scope")
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="api", error="insufficient_scope", scope="%s"`, required))
+				controller.SendProblemDetails(w, controller.ProblemForbidden, []model.ProblemDetailsError{
+					{
+						Field:   "scope",
+						Message: "Token lacks the required scope: " + required,
+						Code:    "insufficient_scope",
+					},
+				}, r.URL.String())
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
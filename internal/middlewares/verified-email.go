@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"net/http"
+
+	controller "github.com/abhinash-kml/go-api-server/internal/controllers"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// RequireVerifiedEmail must be chained after JwtAuthorization, only users have an email so anyone else passes through
+func (m *MiddlewareProvider) RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := m.tracer.Start(r.Context(), "middleware.RequireVerifiedEmail")
+		defer span.End()
+
+		principal, ok := policy.PrincipalFromContext(ctx)
+		if !ok {
+			span.SetStatus(codes.Error, "no principal")
+			sendUnauthorized(w, r, "Authentication is required", "MISSING_TOKEN")
+			return
+		}
+
+		span.SetAttributes(attribute.Bool("auth.email_verified", principal.EmailVerified))
+
+		if principal.Kind == policy.PrincipalUser && !principal.EmailVerified {
+			span.SetStatus(codes.Error, "email not verified")
+			controller.SendProblemDetails(w, controller.ProblemForbidden, []model.ProblemDetailsError{
+				{
+					Field:   "email",
+					Message: "Verify your email address first, then refresh your token",
+					Code:    "EMAIL_NOT_VERIFIED",
+				},
+			}, r.URL.String())
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
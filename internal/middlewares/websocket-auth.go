@@ -0,0 +1,108 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	controller "github.com/abhinash-kml/go-api-server/internal/controllers"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	"github.com/abhinash-kml/go-api-server/internal/realtime"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// WebSocketAuthorization authenticates an upgrade request before the handshake happens, so failures are plain HTTP.
+// The token comes from the Authorization header, the "bearer" subprotocol (Sec-WebSocket-Protocol: bearer, <token>)
+// or a ticket from POST /realtime/ticket in the ticket query parameter, in that order. Impersonated tokens are refused.
+func (m *MiddlewareProvider) WebSocketAuthorization(allowedOrigins []string, tickets realtime.ITicketStore) MiddleWareFunc {
+	return func(next http.Handler) http.Handler {
+		return authenticating{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := m.tracer.Start(r.Context(), "middleware.WebSocketAuth")
+			defer span.End()
+
+			// Browsers always send Origin, other clients can forge it anyway so an absent one is let through
+			origin := r.Header.Get("Origin")
+			span.SetAttributes(attribute.String("ws.origin", origin))
+			if origin != "" && !slices.Contains(allowedOrigins, "*") && !slices.Contains(allowedOrigins, origin) {
+				span.SetStatus(codes.Error, "origin not allowed")
+				controller.SendProblemDetails(w, controller.ProblemForbidden, []model.ProblemDetailsError{
+					{
+						Field:   "Origin",
+						Message: "Origin is not allowed",
+						Code:    "ORIGIN_NOT_ALLOWED",
+					},
+				}, r.URL.String())
+				return
+			}
+
+			var principal *policy.Principal
+			var failure *authFailure
+
+			if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+				span.SetAttributes(attribute.String("ws.auth", "header"))
+				token, ok := bearerToken(authHeader)
+				if !ok {
+					span.SetStatus(codes.Error, "bad authorization header format")
+					sendUnauthorized(w, r, "Authorization header must be of the form 'Bearer <token>'", "MALFORMED_TOKEN")
+					return
+				}
+				principal, failure = m.authenticate(ctx, span, token)
+			} else if token, ok := subprotocolToken(r); ok {
+				span.SetAttributes(attribute.String("ws.auth", "subprotocol"))
+				principal, failure = m.authenticate(ctx, span, token)
+			} else if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+				span.SetAttributes(attribute.String("ws.auth", "ticket"))
+				var err error
+				principal, err = tickets.Redeem(ctx, ticket)
+				if err != nil {
+					span.RecordError(err)
+					failure = &authFailure{"ticket redemption failed", "Ticket is invalid, expired or already used", "INVALID_TICKET"}
+				}
+			} else {
+				failure = &authFailure{"missing credentials", "A bearer token or ticket is required", "MISSING_TOKEN"}
+			}
+
+			if failure != nil {
+				span.SetStatus(codes.Error, failure.reason)
+				sendUnauthorized(w, r, failure.message, failure.code)
+				return
+			}
+
+			span.SetAttributes(attribute.String("auth.subject", principal.Subject))
+
+			// Same as DenyImpersonation on POST /realtime/ticket, a header or subprotocol token must not get around it
+			if principal.IsImpersonated() {
+				span.SetAttributes(attribute.String("auth.actor", principal.Actor))
+				span.SetStatus(codes.Error, "impersonated token denied")
+				controller.SendProblemDetails(w, controller.ProblemForbidden, []model.ProblemDetailsError{
+					{
+						Field:   "Authorization",
+						Message: "Not allowed while impersonating a user",
+						Code:    "IMPERSONATION_DENIED",
+					},
+				}, r.URL.String())
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(policy.WithPrincipal(ctx, principal)))
+		})}
+	}
+}
+
+// subprotocolToken reads the token offered as the second subprotocol after "bearer"
+func subprotocolToken(r *http.Request) (string, bool) {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(protocol))
+		}
+	}
+
+	index := slices.Index(protocols, realtime.BearerSubprotocol)
+	if index == -1 || index+1 >= len(protocols) || protocols[index+1] == "" {
+		return "", false
+	}
+	return protocols[index+1], true
+}
@@ -0,0 +1,105 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	"github.com/abhinash-kml/go-api-server/internal/realtime"
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"github.com/abhinash-kml/go-api-server/pkg/util"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+var testAccessToken = config.TokenConfig{Secret: "test-secret", Issuer: "api", Audience: "api", Expiration: "15m"}
+
+// accessToken signs with the shared secret, no keyset is loaded in these tests
+func accessToken(t *testing.T, subject, actor string) string {
+	t.Helper()
+
+	claims := model.CustomJwtClaims{
+		Role:             policy.RoleUser,
+		Version:          "0",
+		TokenUse:         model.TokenUseAccess,
+		RegisteredClaims: util.NewRegisteredClaims(&testAccessToken, subject, subject+"-"+actor, time.Minute),
+	}
+	if actor != "" {
+		claims.Actor = &model.ActorClaim{Subject: actor}
+	}
+
+	token, err := util.CreateJwtToken(testAccessToken.Secret, claims)
+	if err != nil {
+		t.Fatalf("CreateJwtToken() error = %v", err)
+	}
+	return token
+}
+
+func TestWebSocketAuthorization(t *testing.T) {
+	authConfig := &config.AuthTokenConfig{AccessToken: testAccessToken}
+	m := NewMiddlewareProvider(noop.NewTracerProvider().Tracer(""), authConfig, policy.NewInMemoryTokenStore(clock.System{}), policy.NewInMemoryJwtDenylist(clock.System{}))
+
+	tickets := realtime.NewInMemoryTicketStore(clock.System{})
+	ticket, err := tickets.Issue(context.Background(), &policy.Principal{Kind: policy.PrincipalUser, Subject: "7", UserID: 7, Role: policy.RoleUser}, time.Minute)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	var subject string
+	handler := m.CompileHandlers(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := policy.PrincipalFromContext(r.Context())
+		subject = principal.Subject
+	}), m.WebSocketAuthorization([]string{"https://app.example.com"}, tickets))
+
+	user := accessToken(t, "7", "")
+	impersonated := accessToken(t, "7", "1")
+
+	tests := []struct {
+		name        string
+		header      string
+		subprotocol string
+		ticket      string
+		origin      string
+		wantStatus  int
+	}{
+		{name: "authorization header", header: "Bearer " + user, wantStatus: http.StatusOK},
+		{name: "bearer subprotocol", subprotocol: "bearer, " + user, wantStatus: http.StatusOK},
+		{name: "impersonated authorization header", header: "Bearer " + impersonated, wantStatus: http.StatusForbidden},
+		{name: "impersonated bearer subprotocol", subprotocol: "bearer, " + impersonated, wantStatus: http.StatusForbidden},
+		{name: "ticket", ticket: ticket, origin: "https://app.example.com", wantStatus: http.StatusOK},
+		{name: "ticket used twice", ticket: ticket, origin: "https://app.example.com", wantStatus: http.StatusUnauthorized},
+		{name: "unknown ticket", ticket: "made-up", wantStatus: http.StatusUnauthorized},
+		{name: "origin not allowed", header: "Bearer " + user, origin: "https://evil.example.com", wantStatus: http.StatusForbidden},
+		{name: "no credentials", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject = ""
+			r := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/realtime?ticket="+tt.ticket, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if tt.subprotocol != "" {
+				r.Header.Set("Sec-WebSocket-Protocol", tt.subprotocol)
+			}
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if reached := subject != ""; reached != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("handler reached = %v with status %d", reached, w.Code)
+			}
+		})
+	}
+}
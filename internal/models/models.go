@@ -7,11 +7,16 @@ import (
 )
 
 type User struct {
-	Id      int    `json:"id" redis:"id"`
-	Name    string `json:"name" redis:"name"`
-	City    string `json:"city" redis:"city"`
-	State   string `json:"state" redis:"state"`
-	Country string `json:"country" redis:"country"`
+	Id            int    `json:"id" redis:"id"`
+	Name          string `json:"name" redis:"name"`
+	City          string `json:"city" redis:"city"`
+	State         string `json:"state" redis:"state"`
+	Country       string `json:"country" redis:"country"`
+	Username      string `json:"username" redis:"username"`
+	Email         string `json:"-" redis:"email"` // Private, never in responses
+	EmailVerified bool   `json:"email_verified" redis:"email_verified"`
+	Role          string `json:"role" redis:"role"`
+	PasswordHash  string `json:"-" redis:"-"` // Never leaves the service boundary
 }
 
 type UserRequestDTO struct {
@@ -23,10 +28,13 @@ type UserRequestDTO struct {
 type UserResponseDTO = User
 
 type UserCreateDTO struct {
-	Name    string `json:"name" validate:"required"`
-	City    string `json:"city" validate:"required"`
-	State   string `json:"state" validate:"required"`
-	Country string `json:"country" validate:"required"`
+	Name     string `json:"name" validate:"required"`
+	City     string `json:"city" validate:"required"`
+	State    string `json:"state" validate:"required"`
+	Country  string `json:"country" validate:"required"`
+	Username string `json:"username" validate:"required,min=3,max=64"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,min=8,password_bytes"` // bcrypt takes at most 72 bytes
 }
 
 type UserDeleteDTO struct {
@@ -203,18 +211,307 @@ type AuthResponse struct {
 	ExpiresIn    int    `json:"expires_in"`
 	RefreshToken string `json:"refresh_token,omitempty"`
 	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"` // Only for the authorization_code grant with the openid scope
+}
+
+type LoginRequest struct {
+	Username string     `json:"username" validate:"required"`
+	Password string     `json:"password" validate:"required"`
+	Scope    string     `json:"scope"`
+	Device   DeviceInfo `json:"-"`
 }
 
 type AccessTokenRequest struct {
-	GrantType    string `json:"grant_type"`
+	GrantType    string     `json:"grant_type" validate:"required"`
+	RefreshToken string     `json:"refresh_token"`
+	ClientId     string     `json:"client_id"`
+	ClientSecret string     `json:"client_secret"`
+	Scope        string     `json:"scope"`
+	Code         string     `json:"code"`
+	RedirectURI  string     `json:"redirect_uri"`
+	CodeVerifier string     `json:"code_verifier"`
+	Device       DeviceInfo `json:"-"`
+}
+
+// DeviceInfo is filled in from the request by the controller, never from the body
+type DeviceInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// Session is one refresh token family, i.e. one login on one device
+type Session struct {
+	Id         string    `json:"id"`
+	UserID     int       `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// Client is a registered OAuth2 client, used by machine callers through client_credentials
+type Client struct {
+	Id           string
+	Name         string
+	SecretHash   string
+	Scopes       []string
+	RedirectURIs []string
+}
+
+// Public clients have no secret to keep, they authenticate the code exchange with PKCE only
+func (c *Client) IsPublic() bool {
+	return c.SecretHash == ""
+}
+
+// AuthorizationRequest is the query of GET /authorize (OpenID Connect Core section 3.1.2.1)
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// AuthorizationConsent is the submitted login and consent form of POST /authorize
+type AuthorizationConsent struct {
+	AuthorizationRequest
+	Username string
+	Password string
+	MfaCode  string
+	Approved bool
+	Device   DeviceInfo
+}
+
+// AuthorizationCode is what a code stands for until it is exchanged, once
+type AuthorizationCode struct {
+	Code          string    `json:"-"`
+	ClientID      string    `json:"client_id"`
+	RedirectURI   string    `json:"redirect_uri"`
+	UserID        int       `json:"user_id"`
+	Scope         string    `json:"scope"`
+	Nonce         string    `json:"nonce,omitempty"`
+	CodeChallenge string    `json:"code_challenge"`
+	AuthTime      time.Time `json:"auth_time"`
+}
+
+// ProfileClaims are released per scope, profile, email and address (OpenID Connect Core section 5.4)
+type ProfileClaims struct {
+	Name              string        `json:"name,omitempty"`
+	PreferredUsername string        `json:"preferred_username,omitempty"`
+	Email             string        `json:"email,omitempty"`
+	EmailVerified     *bool         `json:"email_verified,omitempty"`
+	Address           *AddressClaim `json:"address,omitempty"`
+}
+
+type AddressClaim struct {
+	Locality string `json:"locality,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Country  string `json:"country,omitempty"`
+}
+
+type IDTokenClaims struct {
+	Nonce           string           `json:"nonce,omitempty"`
+	AuthTime        *jwt.NumericDate `json:"auth_time,omitempty"`
+	AuthorizedParty string           `json:"azp,omitempty"`
+	ProfileClaims
+	jwt.RegisteredClaims
+}
+
+type UserInfoResponse struct {
+	Subject string `json:"sub"`
+	ProfileClaims
+}
+
+// OpenIDConfiguration is served on /.well-known/openid-configuration
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+}
+
+type RealtimeTicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// TotpEnrollment is a user's TOTP secret, it only counts once confirmed with a first code
+type TotpEnrollment struct {
+	UserID      int
+	Secret      string
+	ConfirmedAt *time.Time
+}
+
+func (t *TotpEnrollment) Confirmed() bool {
+	return t.ConfirmedAt != nil
+}
+
+type RecoveryCode struct {
+	Id       int
+	UserID   int
+	CodeHash string
+	UsedAt   *time.Time
+}
+
+type TotpEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type TotpConfirmRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// RecoveryCodesResponse is the only time the plain recovery codes are shown
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MfaChallenge is returned by a password login when the user has 2FA enabled
+type MfaChallenge struct {
+	MfaRequired bool   `json:"mfa_required"`
+	MfaToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// MfaLoginRequest takes either a TOTP code or a recovery code
+type MfaLoginRequest struct {
+	MfaToken string     `json:"mfa_token" validate:"required"`
+	Code     string     `json:"code" validate:"required"`
+	Device   DeviceInfo `json:"-"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResendVerificationRequest only needs an email when the account has none yet
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"omitempty,email,max=254"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,password_bytes"`
+}
+
+// ImpersonationRequest asks for an access token acting as another user, the reason goes to the audit trail
+type ImpersonationRequest struct {
+	Reason string     `json:"reason" validate:"required,max=500"`
+	Device DeviceInfo `json:"-"`
+}
+
+// ActorClaim names who is acting on behalf of the subject (RFC 8693 section 4.1)
+type ActorClaim struct {
+	Subject string `json:"sub"`
+}
+
+const AuditActionImpersonate = "impersonate"
+
+// AuditEntry is an append-only record of a privileged action
+type AuditEntry struct {
+	Id        int       `json:"id"`
+	Action    string    `json:"action"`
+	ActorID   int       `json:"actor_id"`
+	TargetID  int       `json:"target_id"`
+	Reason    string    `json:"reason"`
+	IP        string    `json:"ip"`
+	TokenID   string    `json:"token_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ApiKey authenticates a partner without a user account, only a hash of the key is kept
+type ApiKey struct {
+	Id     int      `json:"id"`
+	Name   string   `json:"name"`
+	Prefix string   `json:"prefix"` // Visible part of the key, enough to tell keys apart in logs and listings
+	Hash   string   `json:"-"`
+	Scopes []string `json:"scopes"`
+	// Zero is unlimited
+	DailyQuota   int        `json:"daily_quota"`
+	MonthlyQuota int        `json:"monthly_quota"`
+	CreatedBy    int        `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (k *ApiKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// ApiKeyCreateRequest leaves a quota nil to get the configured default, zero makes it unlimited
+type ApiKeyCreateRequest struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	Scopes       []string `json:"scopes" validate:"dive,required"`
+	DailyQuota   *int     `json:"daily_quota" validate:"omitempty,min=0"`
+	MonthlyQuota *int     `json:"monthly_quota" validate:"omitempty,min=0"`
+}
+
+// ApiKeyCreatedResponse is the only time the plain key is shown
+type ApiKeyCreatedResponse struct {
+	ApiKey
+	Key string `json:"key"`
+}
+
+type ApiKeyQuotaUsage struct {
+	Period    string    `json:"period"`
+	Limit     int       `json:"limit"` // Zero is unlimited
+	Used      int       `json:"used"`
+	Rejected  int       `json:"rejected"`
+	Remaining int       `json:"remaining"`
+	Start     time.Time `json:"period_start"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+type ApiKeyUsageResponse struct {
+	ApiKeyID int                `json:"api_key_id"`
+	Prefix   string             `json:"prefix"`
+	Quotas   []ApiKeyQuotaUsage `json:"quotas"`
+}
+
+// LogoutRequest optionally carries the refresh token to revoke along with the access token
+type LogoutRequest struct {
 	RefreshToken string `json:"refresh_token"`
-	ClientId     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
 }
 
+// LogoutAllRequest targets another user's sessions, only admins may set UserID
+type LogoutAllRequest struct {
+	UserID int `json:"user_id,omitempty"`
+}
+
+const (
+	TokenUseAccess  = "access"
+	TokenUseRefresh = "refresh"
+	// Proves the password step of a 2FA login, only accepted by POST /login/mfa
+	TokenUseMfaPending = "mfa_pending"
+	// Emailed links, single use and never accepted as bearer tokens
+	TokenUseEmailVerify   = "email_verify"
+	TokenUsePasswordReset = "password_reset"
+)
+
 type CustomJwtClaims struct {
-	Role    string `json:"role"`
-	Version string `json:"version"`
+	Role      string `json:"role,omitempty"`
+	Scope     string `json:"scope,omitempty"` // Space delimited (RFC 8693 section 4.2)
+	SessionID string `json:"sid,omitempty"`
+	// Email is only set on email verification tokens, EmailVerified on access tokens
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	// Actor is set on impersonation tokens, the subject is the impersonated user
+	Actor    *ActorClaim `json:"act,omitempty"`
+	Version  string      `json:"version"`
+	TokenUse string      `json:"token_use"` // Access and refresh tokens share signing config, this keeps them apart
 	jwt.RegisteredClaims
 }
 
@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"github.com/redis/go-redis/v9"
+)
+
+// JwtDenylist holds revoked JTIs until the token would have expired anyway
+type JwtDenylist interface {
+	// Deny reports false when the JTI was already denied, which makes it usable as a one-time claim
+	Deny(ctx context.Context, jti string, ttl time.Duration) (bool, error)
+	IsDenied(ctx context.Context, jti string) (bool, error)
+}
+
+type RedisJwtDenylist struct {
+	rdb *redis.Client
+}
+
+func NewRedisJwtDenylist(rdb *redis.Client) *RedisJwtDenylist {
+	return &RedisJwtDenylist{rdb: rdb}
+}
+
+func denylistKey(jti string) string {
+	return fmt.Sprintf("denylist:%s", jti)
+}
+
+func (d *RedisJwtDenylist) Deny(ctx context.Context, jti string, ttl time.Duration) (bool, error) {
+	// Redis rejects a zero expiry, an already expired token needs no entry
+	if ttl <= 0 {
+		ttl = time.Second
+	}
+	return d.rdb.SetNX(ctx, denylistKey(jti), 1, ttl).Result()
+}
+
+func (d *RedisJwtDenylist) IsDenied(ctx context.Context, jti string) (bool, error) {
+	num, err := d.rdb.Exists(ctx, denylistKey(jti)).Result()
+	if err != nil {
+		return false, err
+	}
+	return num == 1, nil
+}
+
+// InMemoryJwtDenylist is for a single instance, pair it with InMemoryTokenStore and run AutoEvict
+type InMemoryJwtDenylist struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	clock   clock.Clock
+}
+
+func NewInMemoryJwtDenylist(clock clock.Clock) *InMemoryJwtDenylist {
+	return &InMemoryJwtDenylist{entries: make(map[string]time.Time), clock: clock}
+}
+
+func (d *InMemoryJwtDenylist) Deny(ctx context.Context, jti string, ttl time.Duration) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.clock.Now()
+	if expiry, ok := d.entries[jti]; ok && now.Before(expiry) {
+		return false, nil
+	}
+	if ttl <= 0 {
+		ttl = time.Second
+	}
+	d.entries[jti] = now.Add(ttl)
+	return true, nil
+}
+
+func (d *InMemoryJwtDenylist) IsDenied(ctx context.Context, jti string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	expiry, ok := d.entries[jti]
+	return ok && d.clock.Now().Before(expiry), nil
+}
+
+// AutoEvict drops expired entries so the map doesn't grow forever, they already read as not denied
+func (d *InMemoryJwtDenylist) AutoEvict(evictDuration time.Duration) {
+	ticker := time.NewTicker(evictDuration)
+	for range ticker.C {
+		d.evict()
+	}
+}
+
+func (d *InMemoryJwtDenylist) evict() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.clock.Now()
+	for jti, expiry := range d.entries {
+		if !now.Before(expiry) {
+			delete(d.entries, jti)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestInMemoryJwtDenylist(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(testStart)
+	denylist := NewInMemoryJwtDenylist(fake)
+
+	steps := []struct {
+		name       string
+		at         time.Duration
+		deny       string // Denied for a minute when set
+		wantClaim  bool
+		check      string
+		wantDenied bool
+	}{
+		{name: "first claim", deny: "a", wantClaim: true, check: "a", wantDenied: true},
+		{name: "second claim fails", at: 30 * time.Second, deny: "a", check: "a", wantDenied: true},
+		{name: "other jti is unaffected", at: 30 * time.Second, check: "b"},
+		{name: "zero ttl still claims", at: 30 * time.Second, deny: "c", wantClaim: true, check: "c", wantDenied: true},
+		{name: "expired at the ttl", at: time.Minute, check: "a"},
+		{name: "claimable again once expired", at: time.Minute, deny: "a", wantClaim: true, check: "a", wantDenied: true},
+	}
+
+	for i, step := range steps {
+		fake.Set(testStart.Add(step.at))
+
+		if step.deny != "" {
+			ttl := time.Minute
+			if step.deny == "c" {
+				ttl = 0
+			}
+			claimed, err := denylist.Deny(ctx, step.deny, ttl)
+			if err != nil || claimed != step.wantClaim {
+				t.Errorf("step %d (%s): Deny() = %v, %v, want %v", i, step.name, claimed, err, step.wantClaim)
+			}
+		}
+
+		denied, err := denylist.IsDenied(ctx, step.check)
+		if err != nil || denied != step.wantDenied {
+			t.Errorf("step %d (%s): IsDenied(%q) = %v, %v, want %v", i, step.name, step.check, denied, err, step.wantDenied)
+		}
+	}
+
+	// "c" ran out a second after it was denied, "a" was denied again and is kept
+	fake.Set(testStart.Add(90 * time.Second))
+	denylist.evict()
+	if _, ok := denylist.entries["c"]; ok || len(denylist.entries) != 1 {
+		t.Errorf("entries after evicting = %v, want only a", denylist.entries)
+	}
+	if denied, _ := denylist.IsDenied(ctx, "a"); !denied {
+		t.Error("evicting dropped an entry that hasn't expired")
+	}
+}
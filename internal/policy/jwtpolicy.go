@@ -2,32 +2,66 @@ package policy
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
 
 	"github.com/redis/go-redis/v9"
 )
 
-func IsJwtInvalidByJTI(jti string, rdb *redis.Client) bool {
-	ctx := context.Background()
-	ok, _ := rdb.SIsMember(ctx, "tokens", jti).Result()
-	if !ok {
-		return false
+// RedisTokenStore shares token versions and refresh token families between instances
+type RedisTokenStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisTokenStore(rdb *redis.Client) *RedisTokenStore {
+	return &RedisTokenStore{rdb: rdb}
+}
+
+func tokenVersionKey(subject string) string {
+	return fmt.Sprintf("token_version:%s", subject)
+}
+
+// CurrentVersion reads a key without TTL, losing it would resurrect every token issued before a bump
+func (s *RedisTokenStore) CurrentVersion(ctx context.Context, subject string) (string, error) {
+	version, err := s.rdb.Get(ctx, tokenVersionKey(subject)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "0", nil
 	}
-	return true
+	return version, err
 }
 
-func InvalidateJwtByJTI(jti string, rdb *redis.Client) bool {
-	ctx := context.Background()
-	num, err := rdb.SAdd(ctx, "tokens", jti).Result()
-	if num != 1 || err != nil {
-		return false
+func (s *RedisTokenStore) BumpVersion(ctx context.Context, subject string) (string, error) {
+	version, err := s.rdb.Incr(ctx, tokenVersionKey(subject)).Result()
+	if err != nil {
+		return "", err
 	}
-	return true
+	return formatVersion(version), nil
 }
 
-func IsJwtValidByVersion(version string) bool {
-	return true
+func formatVersion(version int64) string {
+	return strconv.FormatInt(version, 10)
 }
 
-func InvalidateJwtByVersion(version string) bool {
-	return true
+func IsJwtValidByVersion(ctx context.Context, subject, version string, tokens TokenStore) bool {
+	current, err := tokens.CurrentVersion(ctx, subject)
+	if err != nil {
+		return false // Fail closed
+	}
+
+	// Tokens minted before versioning carry no version, they count as version 0
+	if version == "" {
+		version = "0"
+	}
+
+	tokenVersion, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return false
+	}
+	currentVersion, err := strconv.ParseInt(current, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return tokenVersion >= currentVersion
 }
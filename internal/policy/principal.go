@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+type PrincipalKind string
+
+const (
+	PrincipalUser   PrincipalKind = "user"
+	PrincipalClient PrincipalKind = "client"
+	PrincipalApiKey PrincipalKind = "api_key"
+)
+
+// Machine tokens carry sub=client:<id> so they can never collide with a numeric user id
+const ClientSubjectPrefix = "client:"
+
+// Requests made with an API key run as apikey:<id>, for the same reason
+const ApiKeySubjectPrefix = "apikey:"
+
+// Principal is the authenticated caller of a request, set by the authorization middleware
+type Principal struct {
+	Kind      PrincipalKind
+	Subject   string
+	UserID    int
+	ClientID  string
+	ApiKeyID  int
+	Role      string
+	Scopes    []string
+	SessionID string
+	// EmailVerified is as of token issuance
+	EmailVerified bool
+	Version       string
+	TokenID       string
+	ExpiresAt     time.Time
+	// Actor is the subject of the admin impersonating this user, empty otherwise
+	Actor string
+	// CertificateSubject is the identity of the mTLS client certificate, empty for token callers
+	CertificateSubject string
+}
+
+type principalContextKey struct{}
+
+func NewPrincipalFromClaims(claims *model.CustomJwtClaims) *Principal {
+	principal := &Principal{
+		Kind:          PrincipalUser,
+		Subject:       claims.Subject,
+		Role:          claims.Role,
+		Scopes:        strings.Fields(claims.Scope),
+		SessionID:     claims.SessionID,
+		EmailVerified: claims.EmailVerified,
+		Version:       claims.Version,
+		TokenID:       claims.ID,
+	}
+
+	if clientId, ok := strings.CutPrefix(claims.Subject, ClientSubjectPrefix); ok {
+		principal.Kind = PrincipalClient
+		principal.ClientID = clientId
+	} else if id, err := strconv.Atoi(claims.Subject); err == nil {
+		principal.UserID = id
+	}
+
+	if claims.ExpiresAt != nil {
+		principal.ExpiresAt = claims.ExpiresAt.Time
+	}
+
+	if claims.Actor != nil {
+		principal.Actor = claims.Actor.Subject
+	}
+
+	return principal
+}
+
+// NewPrincipalFromApiKey has no role, RequirePermission and RequireScopes both check the key's scopes
+func NewPrincipalFromApiKey(key *model.ApiKey) *Principal {
+	return &Principal{
+		Kind:     PrincipalApiKey,
+		Subject:  ApiKeySubjectPrefix + strconv.Itoa(key.Id),
+		ApiKeyID: key.Id,
+		Scopes:   key.Scopes,
+	}
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.Kind == PrincipalUser && p.Role == RoleAdmin
+}
+
+func (p *Principal) IsClient() bool {
+	return p.Kind == PrincipalClient
+}
+
+func (p *Principal) IsApiKey() bool {
+	return p.Kind == PrincipalApiKey
+}
+
+func (p *Principal) IsImpersonated() bool {
+	return p.Actor != ""
+}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns false for unauthenticated requests and non-HTTP callers
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/abhinash-kml/go-api-server/config"
+)
+
+// Permission is a "resource:action" pair, "*" and "resource:*" act as wildcards in role grants
+type Permission string
+
+const (
+	PermUsersRead        Permission = "users:read"
+	PermUsersWrite       Permission = "users:write"
+	PermUsersDelete      Permission = "users:delete"
+	PermUsersUnlock      Permission = "users:unlock"
+	PermUsersImpersonate Permission = "users:impersonate"
+	PermPostsRead        Permission = "posts:read"
+	PermPostsWrite       Permission = "posts:write"
+	PermPostsDelete      Permission = "posts:delete"
+	PermCommentsRead     Permission = "comments:read"
+	PermCommentsWrite    Permission = "comments:write"
+	PermCommentsDelete   Permission = "comments:delete"
+	PermApiKeysManage    Permission = "api_keys:manage"
+)
+
+type RBAC struct {
+	roles map[string]map[Permission]struct{}
+}
+
+func NewRBAC(cfg *config.RbacConfig) *RBAC {
+	rbac := &RBAC{roles: make(map[string]map[Permission]struct{}, len(cfg.Roles))}
+
+	for role, permissions := range cfg.Roles {
+		granted := make(map[Permission]struct{}, len(permissions))
+		for _, permission := range permissions {
+			granted[Permission(strings.ToLower(strings.TrimSpace(permission)))] = struct{}{}
+		}
+		rbac.roles[strings.ToLower(role)] = granted
+	}
+
+	return rbac
+}
+
+// Can reports whether the role grants the permission, unknown roles are granted nothing
+func (r *RBAC) Can(role string, permission Permission) bool {
+	granted, ok := r.roles[strings.ToLower(role)]
+	if !ok {
+		return false
+	}
+
+	if _, ok := granted["*"]; ok {
+		return true
+	}
+	if _, ok := granted[permission]; ok {
+		return true
+	}
+
+	resource, _, found := strings.Cut(string(permission), ":")
+	if !found {
+		return false
+	}
+	_, ok = granted[Permission(resource+":*")]
+	return ok
+}
+
+// Grants lists what the role was configured with, wildcards included
+func (r *RBAC) Grants(role string) []string {
+	granted := make([]string, 0, len(r.roles[strings.ToLower(role)]))
+	for permission := range r.roles[strings.ToLower(role)] {
+		granted = append(granted, string(permission))
+	}
+	slices.Sort(granted)
+	return granted
+}
+
+// CanAll reports whether the role grants every one of the permissions
+func (r *RBAC) CanAll(role string, permissions ...Permission) bool {
+	for _, permission := range permissions {
+		if !r.Can(role, permission) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/abhinash-kml/go-api-server/config"
+)
+
+func TestRBACCan(t *testing.T) {
+	rbac := NewRBAC(&config.RbacConfig{Roles: map[string][]string{
+		"admin":  {"*"},
+		"User":   {"users:read", " Posts:* "},
+		"viewer": {},
+	}})
+
+	tests := []struct {
+		role       string
+		permission Permission
+		want       bool
+	}{
+		{"admin", PermUsersDelete, true},
+		{"admin", PermUsersImpersonate, true},
+		{"user", PermUsersRead, true},
+		{"USER", PermUsersRead, true},
+		{"user", PermUsersWrite, false},
+		{"user", PermPostsWrite, true},
+		{"user", PermPostsDelete, true},
+		{"user", PermCommentsWrite, false},
+		{"user", Permission("posts"), false},
+		{"user", Permission("postsx:write"), false},
+		{"viewer", PermPostsRead, false},
+		{"unknown", PermPostsRead, false},
+	}
+
+	for _, tt := range tests {
+		if got := rbac.Can(tt.role, tt.permission); got != tt.want {
+			t.Errorf("Can(%q, %q) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+
+	if !rbac.CanAll("user", PermUsersRead, PermPostsWrite) || rbac.CanAll("user", PermUsersRead, PermUsersWrite) {
+		t.Error("CanAll() has to grant every permission or none")
+	}
+}
@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// A token family is every refresh token descended from one login. Rotation keeps the family,
+// replaying a rotated member means the chain leaked so the whole family gets revoked.
+
+func refreshTokenKey(jti string) string {
+	return fmt.Sprintf("refresh:%s", jti)
+}
+
+func familyKey(family string) string {
+	return fmt.Sprintf("family:%s", family)
+}
+
+func familyRevokedKey(family string) string {
+	return fmt.Sprintf("family:%s:revoked", family)
+}
+
+func (s *RedisTokenStore) TrackRefreshToken(ctx context.Context, jti, family string, ttl time.Duration) error {
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, refreshTokenKey(jti), family, ttl)
+		pipe.SAdd(ctx, familyKey(family), jti)
+		pipe.Expire(ctx, familyKey(family), ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisTokenStore) RefreshTokenFamily(ctx context.Context, jti string) (string, error) {
+	family, err := s.rdb.Get(ctx, refreshTokenKey(jti)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrUntrackedRefreshToken
+	}
+	return family, err
+}
+
+func (s *RedisTokenStore) FamilyMembers(ctx context.Context, family string) ([]string, error) {
+	return s.rdb.SMembers(ctx, familyKey(family)).Result()
+}
+
+func (s *RedisTokenStore) IsFamilyRevoked(ctx context.Context, family string) (bool, error) {
+	num, err := s.rdb.Exists(ctx, familyRevokedKey(family)).Result()
+	if err != nil {
+		return false, err
+	}
+	return num == 1, nil
+}
+
+func (s *RedisTokenStore) RevokeFamily(ctx context.Context, family string, ttl time.Duration) error {
+	return s.rdb.Set(ctx, familyRevokedKey(family), 1, ttl).Err()
+}
+
+func IsTokenFamilyRevoked(ctx context.Context, family string, tokens TokenStore) bool {
+	revoked, err := tokens.IsFamilyRevoked(ctx, family)
+	return err != nil || revoked // Fail closed
+}
+
+func RevokeTokenFamily(ctx context.Context, family string, ttl time.Duration, tokens TokenStore, denylist JwtDenylist) error {
+	members, err := tokens.FamilyMembers(ctx, family)
+	if err != nil {
+		return err
+	}
+
+	for _, jti := range members {
+		if _, err := denylist.Deny(ctx, jti, ttl); err != nil {
+			return err
+		}
+	}
+
+	return tokens.RevokeFamily(ctx, family, ttl)
+}
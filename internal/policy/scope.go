@@ -0,0 +1,43 @@
+package policy
+
+import (
+	"slices"
+	"strings"
+)
+
+// Scopes share the "resource:action" shape of permissions, so "*" and "resource:*" grants cover narrower scopes
+
+func scopeCovers(grant, required string) bool {
+	if grant == "*" || grant == required {
+		return true
+	}
+
+	resource, action, found := strings.Cut(grant, ":")
+	return found && action == "*" && strings.HasPrefix(required, resource+":")
+}
+
+// HasScopes reports whether the granted scopes cover every required one
+func HasScopes(granted []string, required ...string) bool {
+	for _, scope := range required {
+		covered := false
+		for _, grant := range granted {
+			if scopeCovers(grant, scope) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+const ScopeOpenID = "openid"
+
+// OpenID Connect scopes select identity claims, they grant no permission so roles never need to cover them
+var OidcScopes = []string{ScopeOpenID, "profile", "email", "address"}
+
+func IsOidcScope(scope string) bool {
+	return slices.Contains(OidcScopes, scope)
+}
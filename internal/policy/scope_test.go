@@ -0,0 +1,27 @@
+package policy
+
+import "testing"
+
+func TestHasScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  []string
+		required []string
+		want     bool
+	}{
+		{"exact", []string{"posts:read"}, []string{"posts:read"}, true},
+		{"resource wildcard", []string{"posts:*"}, []string{"posts:read", "posts:write"}, true},
+		{"resource wildcard stops at the resource", []string{"posts:*"}, []string{"comments:read"}, false},
+		{"prefix isn't a resource", []string{"post:*"}, []string{"posts:read"}, false},
+		{"everything", []string{"*"}, []string{"users:delete"}, true},
+		{"one missing", []string{"posts:read"}, []string{"posts:read", "posts:write"}, false},
+		{"nothing required", nil, nil, true},
+		{"nothing granted", nil, []string{"posts:read"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := HasScopes(tt.granted, tt.required...); got != tt.want {
+			t.Errorf("%s: HasScopes(%v, %v) = %v, want %v", tt.name, tt.granted, tt.required, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+var ErrUntrackedRefreshToken = errors.New("Refresh token is not tracked")
+
+// TokenStore keeps what revokes tokens besides the denylist: the version of each subject and the refresh token families
+type TokenStore interface {
+	// CurrentVersion is the version new tokens for the subject are stamped with, "0" until the first bump
+	CurrentVersion(ctx context.Context, subject string) (string, error)
+	// BumpVersion rejects every access and refresh token issued to the subject before it
+	BumpVersion(ctx context.Context, subject string) (string, error)
+	TrackRefreshToken(ctx context.Context, jti, family string, ttl time.Duration) error
+	// RefreshTokenFamily returns ErrUntrackedRefreshToken for refresh tokens never tracked or expired
+	RefreshTokenFamily(ctx context.Context, jti string) (string, error)
+	FamilyMembers(ctx context.Context, family string) ([]string, error)
+	IsFamilyRevoked(ctx context.Context, family string) (bool, error)
+	RevokeFamily(ctx context.Context, family string, ttl time.Duration) error
+}
+
+// InMemoryTokenStore is for a single instance, revocations are lost on restart and unseen by other instances
+type InMemoryTokenStore struct {
+	mu       sync.Mutex
+	versions map[string]int64
+	refresh  map[string]trackedRefreshToken
+	families map[string]*tokenFamily
+	clock    clock.Clock
+}
+
+type trackedRefreshToken struct {
+	family string
+	expiry time.Time
+}
+
+type tokenFamily struct {
+	members map[string]struct{}
+	expiry  time.Time
+	// revokedUntil is zero while the family is live
+	revokedUntil time.Time
+}
+
+func NewInMemoryTokenStore(clock clock.Clock) *InMemoryTokenStore {
+	return &InMemoryTokenStore{
+		versions: make(map[string]int64),
+		refresh:  make(map[string]trackedRefreshToken),
+		families: make(map[string]*tokenFamily),
+		clock:    clock,
+	}
+}
+
+func (s *InMemoryTokenStore) CurrentVersion(ctx context.Context, subject string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return formatVersion(s.versions[subject]), nil
+}
+
+// BumpVersion never expires, same as the Redis key, losing it would resurrect the tokens it revoked
+func (s *InMemoryTokenStore) BumpVersion(ctx context.Context, subject string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.versions[subject]++
+	return formatVersion(s.versions[subject]), nil
+}
+
+func (s *InMemoryTokenStore) TrackRefreshToken(ctx context.Context, jti, family string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry := s.clock.Now().Add(ttl)
+	s.refresh[jti] = trackedRefreshToken{family: family, expiry: expiry}
+
+	entry, ok := s.families[family]
+	if !ok {
+		entry = &tokenFamily{members: make(map[string]struct{})}
+		s.families[family] = entry
+	}
+	entry.members[jti] = struct{}{}
+	entry.expiry = expiry
+	return nil
+}
+
+func (s *InMemoryTokenStore) RefreshTokenFamily(ctx context.Context, jti string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tracked, ok := s.refresh[jti]
+	if !ok || !s.clock.Now().Before(tracked.expiry) {
+		return "", ErrUntrackedRefreshToken
+	}
+	return tracked.family, nil
+}
+
+func (s *InMemoryTokenStore) FamilyMembers(ctx context.Context, family string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.families[family]
+	if !ok || !s.clock.Now().Before(entry.expiry) {
+		return nil, nil
+	}
+
+	members := make([]string, 0, len(entry.members))
+	for jti := range entry.members {
+		members = append(members, jti)
+	}
+	return members, nil
+}
+
+func (s *InMemoryTokenStore) IsFamilyRevoked(ctx context.Context, family string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.families[family]
+	return ok && s.clock.Now().Before(entry.revokedUntil), nil
+}
+
+func (s *InMemoryTokenStore) RevokeFamily(ctx context.Context, family string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.families[family]
+	if !ok {
+		entry = &tokenFamily{members: make(map[string]struct{})}
+		s.families[family] = entry
+	}
+	entry.revokedUntil = s.clock.Now().Add(ttl)
+	return nil
+}
+
+// AutoEvict drops refresh tokens and families once they have expired and any revocation has run out
+func (s *InMemoryTokenStore) AutoEvict(evictDuration time.Duration) {
+	ticker := time.NewTicker(evictDuration)
+	for range ticker.C {
+		s.evict()
+	}
+}
+
+func (s *InMemoryTokenStore) evict() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	for jti, tracked := range s.refresh {
+		if !now.Before(tracked.expiry) {
+			delete(s.refresh, jti)
+		}
+	}
+	for family, entry := range s.families {
+		if !now.Before(entry.expiry) && !now.Before(entry.revokedUntil) {
+			delete(s.families, family)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+func TestInMemoryTokenStoreVersions(t *testing.T) {
+	ctx := context.Background()
+	tokens := NewInMemoryTokenStore(clock.NewFake(testStart))
+
+	if version, _ := tokens.CurrentVersion(ctx, "7"); version != "0" {
+		t.Fatalf("CurrentVersion() = %q before any bump, want 0", version)
+	}
+	bumped, _ := tokens.BumpVersion(ctx, "7")
+	if current, _ := tokens.CurrentVersion(ctx, "7"); current != bumped || bumped == "0" {
+		t.Errorf("CurrentVersion() = %q after bumping to %q", current, bumped)
+	}
+
+	if !IsJwtValidByVersion(ctx, "7", bumped, tokens) || IsJwtValidByVersion(ctx, "7", "0", tokens) {
+		t.Error("only tokens stamped with the bumped version should stay valid")
+	}
+}
+
+func TestRevokeTokenFamilyOnReuse(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(testStart)
+	tokens := NewInMemoryTokenStore(fake)
+	denylist := NewInMemoryJwtDenylist(fake)
+	ttl := time.Hour
+
+	// Login, then one rotation: the first refresh token is denied as it's used
+	tokens.TrackRefreshToken(ctx, "r1", "family", ttl)
+	denylist.Deny(ctx, "r1", ttl)
+	tokens.TrackRefreshToken(ctx, "r2", "family", ttl)
+	tokens.TrackRefreshToken(ctx, "other", "other-family", ttl)
+
+	// Replaying r1 is reuse, the whole family goes
+	fake.Advance(time.Minute)
+	family, err := tokens.RefreshTokenFamily(ctx, "r1")
+	if err != nil || family != "family" {
+		t.Fatalf("RefreshTokenFamily(r1) = %q, %v, want family", family, err)
+	}
+	if claimed, _ := denylist.Deny(ctx, "r1", ttl); claimed {
+		t.Fatal("a rotated refresh token was claimed a second time")
+	}
+	if err := RevokeTokenFamily(ctx, family, ttl, tokens, denylist); err != nil {
+		t.Fatalf("RevokeTokenFamily() error = %v", err)
+	}
+
+	if denied, _ := denylist.IsDenied(ctx, "r2"); !denied {
+		t.Error("the family's current refresh token is still usable")
+	}
+	if !IsTokenFamilyRevoked(ctx, "family", tokens) {
+		t.Error("family is not revoked, its access tokens would keep working")
+	}
+	if denied, _ := denylist.IsDenied(ctx, "other"); denied || IsTokenFamilyRevoked(ctx, "other-family", tokens) {
+		t.Error("revoking one family touched another")
+	}
+
+	members, _ := tokens.FamilyMembers(ctx, "family")
+	slices.Sort(members)
+	if !slices.Equal(members, []string{"r1", "r2"}) {
+		t.Errorf("FamilyMembers() = %v, want r1 and r2", members)
+	}
+}
+
+func TestInMemoryTokenStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(testStart)
+	tokens := NewInMemoryTokenStore(fake)
+
+	tokens.TrackRefreshToken(ctx, "r1", "live", time.Hour)
+	tokens.TrackRefreshToken(ctx, "r2", "revoked", time.Hour)
+	tokens.RevokeFamily(ctx, "revoked", 2*time.Hour)
+
+	fake.Advance(time.Hour)
+	if _, err := tokens.RefreshTokenFamily(ctx, "r1"); !errors.Is(err, ErrUntrackedRefreshToken) {
+		t.Errorf("RefreshTokenFamily() at expiry = %v, want ErrUntrackedRefreshToken", err)
+	}
+	if members, _ := tokens.FamilyMembers(ctx, "live"); members != nil {
+		t.Errorf("FamilyMembers() at expiry = %v, want none", members)
+	}
+
+	// The revocation outlives the family's tokens, it has to survive eviction
+	tokens.evict()
+	if len(tokens.refresh) != 0 || len(tokens.families) != 1 || !IsTokenFamilyRevoked(ctx, "revoked", tokens) {
+		t.Errorf("after evicting: %d refresh tokens and %d families, want 0 and the revoked one", len(tokens.refresh), len(tokens.families))
+	}
+
+	fake.Advance(time.Hour)
+	tokens.evict()
+	if len(tokens.families) != 0 || IsTokenFamilyRevoked(ctx, "revoked", tokens) {
+		t.Error("the revoked family was kept after its revocation ran out")
+	}
+}
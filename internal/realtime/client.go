@@ -18,8 +18,13 @@ const (
 	PingInterval = (PongWait * 9) / 10
 	// Maximum message size
 	MaxMessageSize = 512 * 1024
+	// Maximum size of one incoming frame
+	ReadLimit = 1064
 )
 
+// Subprotocol a client offers ahead of its access token, browsers can't set an Authorization header on upgrade
+const BearerSubprotocol = "bearer"
+
 type ConnectionStats struct {
 	ConnectedAt      time.Time
 	LastPingAt       time.Time
@@ -28,19 +33,27 @@ type ConnectionStats struct {
 	MessagesReceived int64
 	PingsSent        int64
 	PongsReceived    int64
+	// Inbound messages dropped for going over their category's limits
+	MessageRateViolations int64
+	ByteRateViolations    int64
 }
 
 type Client struct {
 	uid   string
+	sid   string // Login session the connection was authenticated with, empty for tokens minted before sessions
 	conn  *websocket.Conn
 	send  chan *Envelope
 	hub   *Hub
 	stats ConnectionStats
+
+	limiter *connectionLimiter // nil is unlimited
 }
 
-func NewClient(uid string, conn *websocket.Conn, hub *Hub) *Client {
-	return &Client{
+// NewClient with nil limits lets the connection send as fast as it likes
+func NewClient(uid, sid string, conn *websocket.Conn, hub *Hub, limits *InboundLimits) *Client {
+	client := &Client{
 		uid:  uid,
+		sid:  sid,
 		conn: conn,
 		send: make(chan *Envelope, 100),
 		hub:  hub,
@@ -48,6 +61,12 @@ func NewClient(uid string, conn *websocket.Conn, hub *Hub) *Client {
 			ConnectedAt: time.Now(),
 		},
 	}
+
+	if limits != nil {
+		client.limiter = limits.newConnectionLimiter()
+	}
+
+	return client
 }
 
 func (c *Client) ReadIncoming() {
@@ -61,7 +80,7 @@ func (c *Client) ReadIncoming() {
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(1064)
+	c.conn.SetReadLimit(ReadLimit)
 	c.conn.SetReadDeadline(time.Now().Add(PongWait))
 
 	c.conn.SetPongHandler(func(appData string) error {
@@ -71,10 +90,8 @@ func (c *Client) ReadIncoming() {
 	})
 
 	for {
-		message := new(Envelope)
-		message.Header.SourceID = c.uid
-
-		err := c.conn.ReadJSON(message)
+		// Read the raw frame first, its size counts against the byte limit
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err,
 				websocket.CloseGoingAway,
@@ -85,10 +102,78 @@ func (c *Client) ReadIncoming() {
 			break
 		}
 
+		message := new(Envelope)
+		if err := json.Unmarshal(data, message); err != nil {
+			break
+		}
+
+		c.RecordMessageReceived()
+
+		// System envelopes are the server's own, one from a client could only be a forged warning
+		if message.Header.Category == CategorySystem {
+			zap.L().Info("Websocket closed for sending a system message", zap.String("uid", c.uid))
+			c.Disconnect(websocket.ClosePolicyViolation, "system messages are sent by the server only")
+			break
+		}
+
+		if !c.allowInbound(message, len(data)) {
+			continue
+		}
+
+		// Set after decoding, the client doesn't get to pick who the message is from
+		message.Header.SourceID = c.uid
+
 		c.hub.send <- message
 	}
 }
 
+// allowInbound drops a message over its category's limits and warns the client. Once it has run out of warnings
+// the connection is closed with 1008, which ends the read loop on its next read.
+func (c *Client) allowInbound(message *Envelope, size int) bool {
+	if c.limiter == nil {
+		return true
+	}
+
+	violation, retryAfter := c.limiter.check(message.Header.Category, size)
+	switch violation {
+	case violationNone:
+		return true
+	case violationMessages:
+		atomic.AddInt64(&c.stats.MessageRateViolations, 1)
+	case violationBytes:
+		atomic.AddInt64(&c.stats.ByteRateViolations, 1)
+	}
+
+	warningsLeft, ok := c.limiter.warn()
+	if !ok {
+		zap.L().Info("Websocket closed for exceeding inbound limits", zap.String("uid", c.uid),
+			zap.String("category", message.Header.Category.String()))
+		c.Disconnect(websocket.ClosePolicyViolation, "inbound rate limit exceeded")
+		return false
+	}
+
+	limit := "messages"
+	if violation == violationBytes {
+		limit = "bytes"
+	}
+	data, _ := json.Marshal(SystemWarning{
+		Code:         "RATE_LIMITED",
+		Message:      "Too many " + limit + " per second in this category, the message was dropped",
+		Category:     message.Header.Category.String(),
+		RetryAfter:   retryAfter.Milliseconds(),
+		WarningsLeft: warningsLeft,
+	})
+	warning := NewEnvelope("", c.hub.nodeID.String(), c.uid, message.Header.CorrelationID, CategorySystem, TypeSystemWarning, data, time.Now())
+
+	// Never block the read loop on a slow writer, a client that can't take the warning just misses it
+	select {
+	case c.send <- &warning:
+	default:
+	}
+
+	return false
+}
+
 func (c *Client) WriteOutgoing() {
 	// Ticker for periodic ping message
 	ticker := time.NewTicker(PingInterval)
@@ -142,6 +227,12 @@ func (c *Client) WriteOutgoing() {
 	}
 }
 
+// Disconnect sends a close frame and drops the connection, ReadIncoming then unregisters the client
+func (c *Client) Disconnect(code int, reason string) {
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(WriteWait))
+	c.conn.Close()
+}
+
 func (c *Client) RecordMessageSent() {
 	atomic.AddInt64(&c.stats.MessagesSend, 1)
 }
@@ -169,6 +260,9 @@ func (c *Client) GetStats() ConnectionStats {
 		MessagesReceived: atomic.LoadInt64(&c.stats.MessagesReceived),
 		PingsSent:        atomic.LoadInt64(&c.stats.PingsSent),
 		PongsReceived:    atomic.LoadInt64(&c.stats.PongsReceived),
+
+		MessageRateViolations: atomic.LoadInt64(&c.stats.MessageRateViolations),
+		ByteRateViolations:    atomic.LoadInt64(&c.stats.ByteRateViolations),
 	}
 }
 
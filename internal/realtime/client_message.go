@@ -16,6 +16,30 @@ const (
 	CategorySystem
 )
 
+var messageCategoryNames = map[MessageCategory]string{
+	CategoryMessage:      "message",
+	CategoryBroadcast:    "broadcast",
+	CategoryNotification: "notification",
+	CategorySystem:       "system",
+}
+
+func (c MessageCategory) String() string {
+	if name, ok := messageCategoryNames[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseMessageCategory is the reverse of String, for config
+func ParseMessageCategory(name string) (MessageCategory, bool) {
+	for category, categoryName := range messageCategoryNames {
+		if categoryName == name {
+			return category, true
+		}
+	}
+	return 0, false
+}
+
 const (
 	TypeMessage = iota + 1
 	TypeMessageReply
@@ -23,6 +47,11 @@ const (
 	TypeMessageReact
 )
 
+// Types of CategorySystem envelopes, only ever sent by the server. ReadIncoming closes a client that sends one.
+const (
+	TypeSystemWarning MessageType = iota + 1
+)
+
 const (
 	StatusSent ReceiptStatus = iota + 1
 	StatusDelivered
@@ -95,3 +124,12 @@ type ReadReceipt struct {
 	CorrelationID string        `json:"cid"`
 	Status        ReceiptStatus `json:"status"`
 }
+
+// SystemWarning is the data of a TypeSystemWarning envelope
+type SystemWarning struct {
+	Code         string `json:"code"`
+	Message      string `json:"message"`
+	Category     string `json:"category"`
+	RetryAfter   int64  `json:"retry_after_ms"`
+	WarningsLeft int    `json:"warnings_left"` // Before the connection is closed
+}
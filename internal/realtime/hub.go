@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
@@ -27,6 +28,7 @@ type Hub struct {
 	broadcast         chan *Envelope
 	incomingBroadcast chan *Envelope
 	subscribe         chan string
+	disconnect        chan string
 
 	store      ISessionStore
 	pubsub     IPubSub
@@ -51,6 +53,7 @@ func NewHub(store ISessionStore, pubsub IPubSub, pubsubtype int) *Hub {
 		broadcast:         make(chan *Envelope, 100),
 		incomingBroadcast: make(chan *Envelope, 100),
 		subscribe:         make(chan string, 100),
+		disconnect:        make(chan string, 100),
 		store:             store,
 		pubsub:            pubsub,
 		pubsubtype:        pubsubtype,
@@ -85,6 +88,11 @@ func (h *Hub) Broadcast(message *Envelope) {
 	h.broadcast <- message
 }
 
+// DisconnectSession closes connections opened with a revoked login session, on this node only
+func (h *Hub) DisconnectSession(sid string) {
+	h.disconnect <- sid
+}
+
 func (h *Hub) Stop() {
 	h.cancel()
 }
@@ -107,6 +115,8 @@ func (h *Hub) Run() {
 			h.HandleClientMessages(message)
 		case subscription := <-h.subscribe:
 			h.HandleSubscribtionRequests(subscription)
+		case sid := <-h.disconnect:
+			h.HandleSessionDisconnect(sid)
 		}
 	}
 }
@@ -127,6 +137,15 @@ func (h *Hub) HandleUnregistration(c *Client) {
 	zap.L().Debug("Websocket client disconnected", zap.String("uid", c.uid))
 }
 
+func (h *Hub) HandleSessionDisconnect(sid string) {
+	h.store.ForEach(func(c *Client) {
+		if c.sid == sid {
+			zap.L().Debug("Websocket session revoked", zap.String("uid", c.uid), zap.String("sid", sid))
+			c.Disconnect(websocket.ClosePolicyViolation, "session revoked")
+		}
+	})
+}
+
 func (h *Hub) HandleClientMessages(message *Envelope) {
 	zap.L().Debug("Websocket Message", zap.String("from", message.Header.SenderID), zap.String("to", message.Header.RecieverID), zap.String("payload", string(message.Data)))
 
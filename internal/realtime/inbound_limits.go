@@ -0,0 +1,151 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"github.com/abhinash-kml/go-api-server/pkg/ratelimiter"
+)
+
+const defaultInboundCategory = "default"
+
+// InboundLimit caps what one connection may send in a category, zero is unlimited
+type InboundLimit struct {
+	MessagesPerSecond int
+	BytesPerSecond    int
+}
+
+// InboundLimits is shared by every connection, each client counts against its own buckets
+type InboundLimits struct {
+	categories    map[MessageCategory]InboundLimit
+	fallback      InboundLimit
+	maxWarnings   int
+	warningWindow time.Duration
+	clock         clock.Clock
+}
+
+func NewInboundLimits(cfg *config.RealtimeInboundLimitsConfig, clock clock.Clock) (*InboundLimits, error) {
+	window, err := time.ParseDuration(cfg.WarningWindow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid warning_window: %w", err)
+	}
+	if cfg.MaxWarnings < 0 || window <= 0 {
+		return nil, fmt.Errorf("max_warnings can't be negative and warning_window must be positive")
+	}
+
+	limits := &InboundLimits{
+		categories:    make(map[MessageCategory]InboundLimit, len(cfg.Categories)),
+		maxWarnings:   cfg.MaxWarnings,
+		warningWindow: window,
+		clock:         clock,
+	}
+
+	for name, categoryConfig := range cfg.Categories {
+		limit := InboundLimit(categoryConfig)
+		if limit.MessagesPerSecond < 0 || limit.BytesPerSecond < 0 {
+			return nil, fmt.Errorf("inbound limit %q can't be negative", name)
+		}
+		// The bucket holds a second's worth, a frame bigger than that could never get through
+		if limit.BytesPerSecond > 0 && limit.BytesPerSecond < ReadLimit {
+			return nil, fmt.Errorf("inbound limit %q: bytes_per_second must be at least the %d byte read limit", name, ReadLimit)
+		}
+
+		if name == defaultInboundCategory {
+			limits.fallback = limit
+			continue
+		}
+
+		category, ok := ParseMessageCategory(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown message category %q", name)
+		}
+		limits.categories[category] = limit
+	}
+
+	return limits, nil
+}
+
+func (l *InboundLimits) limit(category MessageCategory) InboundLimit {
+	if limit, ok := l.categories[category]; ok {
+		return limit
+	}
+	return l.fallback
+}
+
+// inboundBuckets are nil for an unlimited dimension
+type inboundBuckets struct {
+	messages *ratelimiter.TokenBucketLimiter
+	bytes    *ratelimiter.TokenBucketLimiter
+}
+
+// connectionLimiter is one connection's share of InboundLimits, only its read loop touches it
+type connectionLimiter struct {
+	limits     *InboundLimits
+	buckets    map[MessageCategory]*inboundBuckets
+	violations *ratelimiter.FixedWindowLimiter
+}
+
+func (l *InboundLimits) newConnectionLimiter() *connectionLimiter {
+	return &connectionLimiter{
+		limits:     l,
+		buckets:    make(map[MessageCategory]*inboundBuckets),
+		violations: ratelimiter.NewFixedWindowLimiter(l.warningWindow, l.maxWarnings, l.clock),
+	}
+}
+
+// tokenBucket refills a second's worth over a second, one token at a time
+func tokenBucket(perSecond int, clock clock.Clock) *ratelimiter.TokenBucketLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return ratelimiter.NewTokenBucketLimiter(perSecond, time.Second/time.Duration(perSecond), clock)
+}
+
+func (c *connectionLimiter) bucketsFor(category MessageCategory) *inboundBuckets {
+	buckets, ok := c.buckets[category]
+	if !ok {
+		limit := c.limits.limit(category)
+		buckets = &inboundBuckets{
+			messages: tokenBucket(limit.MessagesPerSecond, c.limits.clock),
+			bytes:    tokenBucket(limit.BytesPerSecond, c.limits.clock),
+		}
+		c.buckets[category] = buckets
+	}
+	return buckets
+}
+
+type inboundViolation int
+
+const (
+	violationNone inboundViolation = iota
+	violationMessages
+	violationBytes
+)
+
+// check counts a frame of size bytes and reports the first limit it broke, with how long until it would fit
+func (c *connectionLimiter) check(category MessageCategory, size int) (inboundViolation, time.Duration) {
+	buckets := c.bucketsFor(category)
+
+	// Local buckets never return an error
+	if buckets.messages != nil {
+		if decision, _ := buckets.messages.Allow(context.Background(), ""); !decision.Allowed {
+			return violationMessages, decision.RetryAfter
+		}
+	}
+	if buckets.bytes != nil {
+		if decision, _ := buckets.bytes.AllowN(context.Background(), "", size); !decision.Allowed {
+			return violationBytes, decision.RetryAfter
+		}
+	}
+
+	return violationNone, 0
+}
+
+// warn records a violation, false means the connection has used up its warnings and has to go
+func (c *connectionLimiter) warn() (int, bool) {
+	decision, _ := c.violations.Allow(context.Background(), "")
+	return decision.Remaining, decision.Allowed
+}
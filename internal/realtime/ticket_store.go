@@ -0,0 +1,112 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/internal/connections"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrTicketInvalid = errors.New("Ticket invalid or already used")
+
+// ITicketStore hands out single-use tickets for browsers, which can't set headers on a WebSocket upgrade
+type ITicketStore interface {
+	Issue(ctx context.Context, principal *policy.Principal, ttl time.Duration) (string, error)
+	Redeem(ctx context.Context, ticket string) (*policy.Principal, error)
+}
+
+type RedisTicketStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisTicketStore(conn *connections.RedisConnection) *RedisTicketStore {
+	return &RedisTicketStore{rdb: conn.Client}
+}
+
+func ticketKey(ticket string) string {
+	return fmt.Sprintf("ws_ticket:%s", ticket)
+}
+
+func (s *RedisTicketStore) Issue(ctx context.Context, principal *policy.Principal, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(principal)
+	if err != nil {
+		return "", err
+	}
+
+	ticket := uuid.NewString()
+	if err := s.rdb.Set(ctx, ticketKey(ticket), data, ttl).Err(); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+func (s *RedisTicketStore) Redeem(ctx context.Context, ticket string) (*policy.Principal, error) {
+	// GETDEL makes redemption atomic, a ticket can't be used twice
+	data, err := s.rdb.GetDel(ctx, ticketKey(ticket)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrTicketInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	principal := new(policy.Principal)
+	if err := json.Unmarshal(data, principal); err != nil {
+		return nil, err
+	}
+	return principal, nil
+}
+
+type ticketEntry struct {
+	principal *policy.Principal
+	expiresAt time.Time
+}
+
+type InMemoryTicketStore struct {
+	tickets map[string]ticketEntry
+	mu      sync.Mutex
+	clock   clock.Clock
+}
+
+func NewInMemoryTicketStore(clock clock.Clock) *InMemoryTicketStore {
+	return &InMemoryTicketStore{
+		tickets: make(map[string]ticketEntry),
+		clock:   clock,
+	}
+}
+
+func (s *InMemoryTicketStore) Issue(ctx context.Context, principal *policy.Principal, ttl time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	for ticket, entry := range s.tickets {
+		if now.After(entry.expiresAt) {
+			delete(s.tickets, ticket)
+		}
+	}
+
+	ticket := uuid.NewString()
+	s.tickets[ticket] = ticketEntry{principal: principal, expiresAt: now.Add(ttl)}
+	return ticket, nil
+}
+
+func (s *InMemoryTicketStore) Redeem(ctx context.Context, ticket string) (*policy.Principal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.tickets[ticket]
+	delete(s.tickets, ticket)
+	if !ok || s.clock.Now().After(entry.expiresAt) {
+		return nil, ErrTicketInvalid
+	}
+	return entry.principal, nil
+}
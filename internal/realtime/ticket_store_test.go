@@ -0,0 +1,43 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+func TestInMemoryTicketStore(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := NewInMemoryTicketStore(fake)
+	principal := &policy.Principal{Kind: policy.PrincipalUser, Subject: "7", UserID: 7, Role: policy.RoleUser}
+
+	ticket, err := store.Issue(ctx, principal, 30*time.Second)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	got, err := store.Redeem(ctx, ticket)
+	if err != nil || got.Subject != "7" {
+		t.Fatalf("Redeem() = %+v, %v, want the issuing principal", got, err)
+	}
+
+	// A ticket is good for one upgrade only
+	if _, err := store.Redeem(ctx, ticket); !errors.Is(err, ErrTicketInvalid) {
+		t.Errorf("second Redeem() = %v, want ErrTicketInvalid", err)
+	}
+
+	expiring, _ := store.Issue(ctx, principal, 30*time.Second)
+	fake.Advance(31 * time.Second)
+	if _, err := store.Redeem(ctx, expiring); !errors.Is(err, ErrTicketInvalid) {
+		t.Errorf("Redeem() of an expired ticket = %v, want ErrTicketInvalid", err)
+	}
+
+	if _, err := store.Redeem(ctx, "made-up"); !errors.Is(err, ErrTicketInvalid) {
+		t.Errorf("Redeem() of an unknown ticket = %v, want ErrTicketInvalid", err)
+	}
+}
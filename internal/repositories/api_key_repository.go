@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+// ApiKeyRepository never deletes, a revoked key stays listed with its usage
+type ApiKeyRepository interface {
+	Create(context.Context, model.ApiKey) (*model.ApiKey, error)
+	GetAll(context.Context) ([]model.ApiKey, error)
+	GetById(context.Context, int) (*model.ApiKey, error)
+	GetByPrefix(context.Context, string) (*model.ApiKey, error)
+	Revoke(context.Context, int, time.Time) error
+}
+
+type InMemoryApiKeyRepository struct {
+	keys   []model.ApiKey
+	mu     sync.Mutex
+	tracer oteltracer.Tracer
+}
+
+func NewInMemoryApiKeyRepository(tracer oteltracer.Tracer) *InMemoryApiKeyRepository {
+	return &InMemoryApiKeyRepository{tracer: tracer}
+}
+
+func (e *InMemoryApiKeyRepository) Create(ctx context.Context, key model.ApiKey) (*model.ApiKey, error) {
+	_, span := e.tracer.Start(ctx, "Create.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("api_key.prefix", key.Prefix))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key.Id = len(e.keys) + 1
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+	e.keys = append(e.keys, key)
+
+	return &key, nil
+}
+
+func (e *InMemoryApiKeyRepository) GetAll(ctx context.Context) ([]model.ApiKey, error) {
+	_, span := e.tracer.Start(ctx, "GetAll.Repository")
+	defer span.End()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return slices.Clone(e.keys), nil
+}
+
+func (e *InMemoryApiKeyRepository) GetById(ctx context.Context, id int) (*model.ApiKey, error) {
+	_, span := e.tracer.Start(ctx, "GetById.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("api_key.id", id))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, key := range e.keys {
+		if key.Id == id {
+			return &key, nil
+		}
+	}
+	return nil, ErrNoRecord
+}
+
+func (e *InMemoryApiKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*model.ApiKey, error) {
+	_, span := e.tracer.Start(ctx, "GetByPrefix.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("api_key.prefix", prefix))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, key := range e.keys {
+		if key.Prefix == prefix {
+			return &key, nil
+		}
+	}
+	return nil, ErrNoRecord
+}
+
+// Revoke keeps the first revocation time when called again
+func (e *InMemoryApiKeyRepository) Revoke(ctx context.Context, id int, at time.Time) error {
+	_, span := e.tracer.Start(ctx, "Revoke.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("api_key.id", id))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i := range e.keys {
+		if e.keys[i].Id == id {
+			if e.keys[i].RevokedAt == nil {
+				e.keys[i].RevokedAt = &at
+			}
+			return nil
+		}
+	}
+	return ErrNoRecord
+}
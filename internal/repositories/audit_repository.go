@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+// AuditRepository is append only, entries are never updated or deleted
+type AuditRepository interface {
+	Record(context.Context, model.AuditEntry) error
+}
+
+type InMemoryAuditRepository struct {
+	entries []model.AuditEntry
+	mu      sync.Mutex
+	tracer  oteltracer.Tracer
+}
+
+func NewInMemoryAuditRepository(tracer oteltracer.Tracer) *InMemoryAuditRepository {
+	return &InMemoryAuditRepository{tracer: tracer}
+}
+
+func (e *InMemoryAuditRepository) Record(ctx context.Context, entry model.AuditEntry) error {
+	_, span := e.tracer.Start(ctx, "Record.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("audit.action", entry.Action))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	entry.Id = len(e.entries) + 1
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	e.entries = append(e.entries, entry)
+
+	return nil
+}
+
+// Entries returns a copy of everything recorded so far
+func (e *InMemoryAuditRepository) Entries() []model.AuditEntry {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]model.AuditEntry(nil), e.entries...)
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/internal/connections"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+// AuthCodeRepository holds authorization codes between the redirect and the token exchange
+type AuthCodeRepository interface {
+	SaveCode(context.Context, model.AuthorizationCode, time.Duration) error
+	// ConsumeCode returns ErrNoRecord for unknown, expired or already exchanged codes
+	ConsumeCode(context.Context, string) (*model.AuthorizationCode, error)
+}
+
+type RedisAuthCodeRepository struct {
+	rdb    *redis.Client
+	tracer oteltracer.Tracer
+}
+
+func NewRedisAuthCodeRepository(conn *connections.RedisConnection, tracer oteltracer.Tracer) *RedisAuthCodeRepository {
+	return &RedisAuthCodeRepository{rdb: conn.Client, tracer: tracer}
+}
+
+func authCodeKey(code string) string {
+	return fmt.Sprintf("auth_code:%s", code)
+}
+
+func (r *RedisAuthCodeRepository) SaveCode(ctx context.Context, code model.AuthorizationCode, ttl time.Duration) error {
+	ctx, span := r.tracer.Start(ctx, "SaveCode.Repository")
+	defer span.End()
+
+	data, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+
+	if err := r.rdb.Set(ctx, authCodeKey(code.Code), data, ttl).Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to save authorization code")
+		return err
+	}
+	return nil
+}
+
+func (r *RedisAuthCodeRepository) ConsumeCode(ctx context.Context, code string) (*model.AuthorizationCode, error) {
+	ctx, span := r.tracer.Start(ctx, "ConsumeCode.Repository")
+	defer span.End()
+
+	// GETDEL makes the exchange atomic, a code can't be redeemed twice
+	data, err := r.rdb.GetDel(ctx, authCodeKey(code)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	authCode := new(model.AuthorizationCode)
+	if err := json.Unmarshal(data, authCode); err != nil {
+		return nil, err
+	}
+	authCode.Code = code
+
+	return authCode, nil
+}
+
+type authCodeEntry struct {
+	code      model.AuthorizationCode
+	expiresAt time.Time
+}
+
+type InMemoryAuthCodeRepository struct {
+	codes  map[string]authCodeEntry
+	mu     sync.Mutex
+	tracer oteltracer.Tracer
+}
+
+func NewInMemoryAuthCodeRepository(tracer oteltracer.Tracer) *InMemoryAuthCodeRepository {
+	return &InMemoryAuthCodeRepository{
+		codes:  make(map[string]authCodeEntry),
+		tracer: tracer,
+	}
+}
+
+func (r *InMemoryAuthCodeRepository) SaveCode(ctx context.Context, code model.AuthorizationCode, ttl time.Duration) error {
+	_, span := r.tracer.Start(ctx, "SaveCode.Repository")
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range r.codes {
+		if now.After(entry.expiresAt) {
+			delete(r.codes, key)
+		}
+	}
+
+	r.codes[code.Code] = authCodeEntry{code: code, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (r *InMemoryAuthCodeRepository) ConsumeCode(ctx context.Context, code string) (*model.AuthorizationCode, error) {
+	_, span := r.tracer.Start(ctx, "ConsumeCode.Repository")
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.codes[code]
+	delete(r.codes, code)
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, ErrNoRecord
+	}
+	return &entry.code, nil
+}
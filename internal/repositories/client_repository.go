@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+// ClientRepository holds the registered OAuth2 clients
+type ClientRepository interface {
+	GetById(context.Context, string) (*model.Client, error)
+}
+
+// ConfigClientRepository serves clients registered under auth.clients in config, they are read once at startup
+type ConfigClientRepository struct {
+	clients map[string]model.Client
+	tracer  oteltracer.Tracer
+}
+
+func NewConfigClientRepository(clients []config.ClientConfig, tracer oteltracer.Tracer) *ConfigClientRepository {
+	repo := &ConfigClientRepository{
+		clients: make(map[string]model.Client, len(clients)),
+		tracer:  tracer,
+	}
+
+	for _, client := range clients {
+		repo.clients[client.Id] = model.Client{
+			Id:           client.Id,
+			Name:         client.Name,
+			SecretHash:   client.SecretHash,
+			Scopes:       client.Scopes,
+			RedirectURIs: client.RedirectURIs,
+		}
+	}
+
+	return repo
+}
+
+func (r *ConfigClientRepository) GetById(ctx context.Context, id string) (*model.Client, error) {
+	_, span := r.tracer.Start(ctx, "GetById.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("client.id", id))
+
+	client, ok := r.clients[id]
+	if !ok {
+		span.SetStatus(codes.Error, "client not found")
+		return nil, ErrNoRecord
+	}
+
+	return &client, nil
+}
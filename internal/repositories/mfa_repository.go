@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+type MfaRepository interface {
+	GetTotp(context.Context, int) (*model.TotpEnrollment, error)
+	SaveTotp(context.Context, model.TotpEnrollment) error
+	ReplaceRecoveryCodes(context.Context, int, []string) error
+	GetUnusedRecoveryCodes(context.Context, int) ([]model.RecoveryCode, error)
+	// UseRecoveryCode reports false if the code was already used, so two racing logins can't both spend it
+	UseRecoveryCode(context.Context, int) (bool, error)
+}
+
+type InMemoryMfaRepository struct {
+	totp          map[int]model.TotpEnrollment
+	recoveryCodes []model.RecoveryCode
+	mu            sync.Mutex
+	tracer        oteltracer.Tracer
+}
+
+func NewInMemoryMfaRepository(tracer oteltracer.Tracer) *InMemoryMfaRepository {
+	return &InMemoryMfaRepository{
+		totp:   make(map[int]model.TotpEnrollment),
+		tracer: tracer,
+	}
+}
+
+func (e *InMemoryMfaRepository) GetTotp(ctx context.Context, userId int) (*model.TotpEnrollment, error) {
+	_, span := e.tracer.Start(ctx, "GetTotp.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	enrollment, ok := e.totp[userId]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+	return &enrollment, nil
+}
+
+func (e *InMemoryMfaRepository) SaveTotp(ctx context.Context, enrollment model.TotpEnrollment) error {
+	_, span := e.tracer.Start(ctx, "SaveTotp.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", enrollment.UserID))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.totp[enrollment.UserID] = enrollment
+	return nil
+}
+
+func (e *InMemoryMfaRepository) ReplaceRecoveryCodes(ctx context.Context, userId int, hashes []string) error {
+	_, span := e.tracer.Start(ctx, "ReplaceRecoveryCodes.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	kept := e.recoveryCodes[:0]
+	nextId := 1
+	for _, code := range e.recoveryCodes {
+		nextId = max(nextId, code.Id+1)
+		if code.UserID != userId {
+			kept = append(kept, code)
+		}
+	}
+
+	for index, hash := range hashes {
+		kept = append(kept, model.RecoveryCode{Id: nextId + index, UserID: userId, CodeHash: hash})
+	}
+	e.recoveryCodes = kept
+
+	return nil
+}
+
+func (e *InMemoryMfaRepository) GetUnusedRecoveryCodes(ctx context.Context, userId int) ([]model.RecoveryCode, error) {
+	_, span := e.tracer.Start(ctx, "GetUnusedRecoveryCodes.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	codes := make([]model.RecoveryCode, 0)
+	for _, code := range e.recoveryCodes {
+		if code.UserID == userId && code.UsedAt == nil {
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
+
+func (e *InMemoryMfaRepository) UseRecoveryCode(ctx context.Context, id int) (bool, error) {
+	_, span := e.tracer.Start(ctx, "UseRecoveryCode.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("recovery_code.id", id))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for index := range e.recoveryCodes {
+		if e.recoveryCodes[index].Id == id {
+			if e.recoveryCodes[index].UsedAt != nil {
+				return false, nil
+			}
+			now := time.Now()
+			e.recoveryCodes[index].UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
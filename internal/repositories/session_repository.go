@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/internal/connections"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+// SessionRepository stores sessions for as long as their refresh token family lives
+type SessionRepository interface {
+	SaveSession(context.Context, model.Session, time.Duration) error
+	GetById(context.Context, string) (*model.Session, error)
+	GetSessionsOfUser(context.Context, int) ([]model.Session, error)
+	DeleteSession(context.Context, string) error
+}
+
+type RedisSessionRepository struct {
+	rdb    *redis.Client
+	tracer oteltracer.Tracer
+}
+
+func NewRedisSessionRepository(conn *connections.RedisConnection, tracer oteltracer.Tracer) *RedisSessionRepository {
+	return &RedisSessionRepository{rdb: conn.Client, tracer: tracer}
+}
+
+func sessionKey(id string) string {
+	return fmt.Sprintf("session:%s", id)
+}
+
+func userSessionsKey(userId int) string {
+	return fmt.Sprintf("user_sessions:%d", userId)
+}
+
+// SaveSession creates or updates a session and pushes its expiry out to ttl
+func (r *RedisSessionRepository) SaveSession(ctx context.Context, session model.Session, ttl time.Duration) error {
+	ctx, span := r.tracer.Start(ctx, "SaveSession.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("session.id", session.Id),
+		attribute.Int("user.id", session.UserID))
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, sessionKey(session.Id), data, ttl)
+		pipe.SAdd(ctx, userSessionsKey(session.UserID), session.Id)
+		pipe.Expire(ctx, userSessionsKey(session.UserID), ttl)
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to save session")
+	}
+	return err
+}
+
+func (r *RedisSessionRepository) GetById(ctx context.Context, id string) (*model.Session, error) {
+	ctx, span := r.tracer.Start(ctx, "GetById.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("session.id", id))
+
+	data, err := r.rdb.Get(ctx, sessionKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch session")
+		return nil, err
+	}
+
+	session := new(model.Session)
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (r *RedisSessionRepository) GetSessionsOfUser(ctx context.Context, userId int) ([]model.Session, error) {
+	ctx, span := r.tracer.Start(ctx, "GetSessionsOfUser.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	ids, err := r.rdb.SMembers(ctx, userSessionsKey(userId)).Result()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch session ids")
+		return nil, err
+	}
+
+	sessions := make([]model.Session, 0, len(ids))
+	for _, id := range ids {
+		session, err := r.GetById(ctx, id)
+		if errors.Is(err, ErrNoRecord) {
+			r.rdb.SRem(ctx, userSessionsKey(userId), id) // Expired, drop it from the index
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+
+	return sessions, nil
+}
+
+func (r *RedisSessionRepository) DeleteSession(ctx context.Context, id string) error {
+	ctx, span := r.tracer.Start(ctx, "DeleteSession.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("session.id", id))
+
+	session, err := r.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(id))
+		pipe.SRem(ctx, userSessionsKey(session.UserID), id)
+		return nil
+	})
+	return err
+}
+
+type sessionEntry struct {
+	session   model.Session
+	expiresAt time.Time
+}
+
+// InMemorySessionRepository is for a single instance, sessions are lost on restart
+type InMemorySessionRepository struct {
+	sessions map[string]sessionEntry
+	mu       sync.Mutex
+	tracer   oteltracer.Tracer
+}
+
+func NewInMemorySessionRepository(tracer oteltracer.Tracer) *InMemorySessionRepository {
+	return &InMemorySessionRepository{
+		sessions: make(map[string]sessionEntry),
+		tracer:   tracer,
+	}
+}
+
+func (r *InMemorySessionRepository) SaveSession(ctx context.Context, session model.Session, ttl time.Duration) error {
+	_, span := r.tracer.Start(ctx, "SaveSession.Repository")
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, entry := range r.sessions {
+		if now.After(entry.expiresAt) {
+			delete(r.sessions, id)
+		}
+	}
+
+	r.sessions[session.Id] = sessionEntry{session: session, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (r *InMemorySessionRepository) GetById(ctx context.Context, id string) (*model.Session, error) {
+	_, span := r.tracer.Start(ctx, "GetById.Repository")
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.sessions[id]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, ErrNoRecord
+	}
+	session := entry.session
+	return &session, nil
+}
+
+func (r *InMemorySessionRepository) GetSessionsOfUser(ctx context.Context, userId int) ([]model.Session, error) {
+	_, span := r.tracer.Start(ctx, "GetSessionsOfUser.Repository")
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	sessions := []model.Session{}
+	for _, entry := range r.sessions {
+		if entry.session.UserID == userId && !now.After(entry.expiresAt) {
+			sessions = append(sessions, entry.session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *InMemorySessionRepository) DeleteSession(ctx context.Context, id string) error {
+	_, span := r.tracer.Start(ctx, "DeleteSession.Repository")
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[id]; !ok {
+		return ErrNoRecord
+	}
+	delete(r.sessions, id)
+	return nil
+}
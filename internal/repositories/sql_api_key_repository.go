@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/internal/connections"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+const apiKeyColumns = `id, name, prefix, key_hash, scope, daily_quota, monthly_quota, created_by, created_at, revoked_at`
+
+type PostgresApiKeyRepository struct {
+	db     *sql.DB
+	tracer oteltracer.Tracer
+}
+
+func NewPostgresApiKeyRepository(connection *connections.PostgresConnection, tracer oteltracer.Tracer) *PostgresApiKeyRepository {
+	return &PostgresApiKeyRepository{db: connection.DB, tracer: tracer}
+}
+
+type rowScanner interface {
+	Scan(...any) error
+}
+
+// Scopes are stored space delimited, the same way tokens carry them
+func scanApiKey(row rowScanner) (*model.ApiKey, error) {
+	var key model.ApiKey
+	var scope string
+	var revokedAt sql.NullTime
+	if err := row.Scan(&key.Id, &key.Name, &key.Prefix, &key.Hash, &scope, &key.DailyQuota, &key.MonthlyQuota, &key.CreatedBy, &key.CreatedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+
+	key.Scopes = strings.Fields(scope)
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+func (r *PostgresApiKeyRepository) Create(ctx context.Context, key model.ApiKey) (*model.ApiKey, error) {
+	ctx, span := r.tracer.Start(ctx, "Create.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("api_key.prefix", key.Prefix))
+
+	query := `INSERT INTO api_keys(name, prefix, key_hash, scope, daily_quota, monthly_quota, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7)
+				RETURNING ` + apiKeyColumns + `;`
+	created, err := scanApiKey(r.db.QueryRowContext(ctx, query, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, " "), key.DailyQuota, key.MonthlyQuota, key.CreatedBy))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to create api key")
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (r *PostgresApiKeyRepository) GetAll(ctx context.Context) ([]model.ApiKey, error) {
+	ctx, span := r.tracer.Start(ctx, "GetAll.Repository")
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id;`)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch api keys")
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]model.ApiKey, 0, 10)
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *PostgresApiKeyRepository) GetById(ctx context.Context, id int) (*model.ApiKey, error) {
+	ctx, span := r.tracer.Start(ctx, "GetById.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("api_key.id", id))
+
+	key, err := scanApiKey(r.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1;`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch api key")
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (r *PostgresApiKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*model.ApiKey, error) {
+	ctx, span := r.tracer.Start(ctx, "GetByPrefix.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("api_key.prefix", prefix))
+
+	key, err := scanApiKey(r.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE prefix = $1;`, prefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch api key")
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Revoke keeps the first revocation time when called again
+func (r *PostgresApiKeyRepository) Revoke(ctx context.Context, id int, at time.Time) error {
+	ctx, span := r.tracer.Start(ctx, "Revoke.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("api_key.id", id))
+
+	result, err := r.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1;`, id, at)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to revoke api key")
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/abhinash-kml/go-api-server/internal/connections"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+type PostgresAuditRepository struct {
+	db     *sql.DB
+	tracer oteltracer.Tracer
+}
+
+func NewPostgresAuditRepository(connection *connections.PostgresConnection, tracer oteltracer.Tracer) *PostgresAuditRepository {
+	return &PostgresAuditRepository{db: connection.DB, tracer: tracer}
+}
+
+func (r *PostgresAuditRepository) Record(ctx context.Context, entry model.AuditEntry) error {
+	ctx, span := r.tracer.Start(ctx, "Record.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("audit.action", entry.Action),
+		attribute.Int("audit.actor_id", entry.ActorID),
+		attribute.Int("audit.target_id", entry.TargetID))
+
+	query := `INSERT INTO audit_log(action, actor_id, target_id, reason, ip, token_id) VALUES ($1, $2, $3, $4, $5, $6);`
+	if _, err := r.db.ExecContext(ctx, query, entry.Action, entry.ActorID, entry.TargetID, entry.Reason, entry.IP, entry.TokenID); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to record audit entry")
+		return err
+	}
+
+	return nil
+}
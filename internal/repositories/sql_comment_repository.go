@@ -157,9 +157,9 @@ func (r *PostgresCommentRepository) UpdateComment(ctx context.Context, dto model
 	fmt.Println(sqlString)
 
 	// Execute the update call
-	_, err := r.db.Exec(sqlString, args...)
-	if err != nil {
-		zap.L().Fatal("Failed to execute sql query", zap.Error(err))
+	if _, err := r.db.Exec(sqlString, args...); err != nil {
+		span.RecordError(err)
+		return err
 	}
 
 	return nil
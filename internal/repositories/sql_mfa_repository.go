@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/abhinash-kml/go-api-server/internal/connections"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+type PostgresMfaRepository struct {
+	db     *sql.DB
+	tracer oteltracer.Tracer
+}
+
+func NewPostgresMfaRepository(connection *connections.PostgresConnection, tracer oteltracer.Tracer) *PostgresMfaRepository {
+	return &PostgresMfaRepository{db: connection.DB, tracer: tracer}
+}
+
+func (r *PostgresMfaRepository) GetTotp(ctx context.Context, userId int) (*model.TotpEnrollment, error) {
+	ctx, span := r.tracer.Start(ctx, "GetTotp.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	query := `SELECT user_id, secret, confirmed_at FROM user_totp WHERE user_id = $1;`
+	var enrollment model.TotpEnrollment
+	var confirmedAt sql.NullTime
+	if err := r.db.QueryRowContext(ctx, query, userId).Scan(&enrollment.UserID, &enrollment.Secret, &confirmedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch totp enrollment")
+		return nil, err
+	}
+
+	if confirmedAt.Valid {
+		enrollment.ConfirmedAt = &confirmedAt.Time
+	}
+
+	return &enrollment, nil
+}
+
+func (r *PostgresMfaRepository) SaveTotp(ctx context.Context, enrollment model.TotpEnrollment) error {
+	ctx, span := r.tracer.Start(ctx, "SaveTotp.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", enrollment.UserID))
+
+	query := `INSERT INTO user_totp(user_id, secret, confirmed_at) VALUES ($1, $2, $3)
+				ON CONFLICT (user_id)
+				DO UPDATE SET
+					secret = EXCLUDED.secret,
+					confirmed_at = EXCLUDED.confirmed_at;`
+	if _, err := r.db.ExecContext(ctx, query, enrollment.UserID, enrollment.Secret, enrollment.ConfirmedAt); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to save totp enrollment")
+		return err
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes drops every previous code of the user, used or not
+func (r *PostgresMfaRepository) ReplaceRecoveryCodes(ctx context.Context, userId int, hashes []string) error {
+	ctx, span := r.tracer.Start(ctx, "ReplaceRecoveryCodes.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1;`, userId); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	for _, hash := range hashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes(user_id, code_hash) VALUES ($1, $2);`, userId, hash); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresMfaRepository) GetUnusedRecoveryCodes(ctx context.Context, userId int) ([]model.RecoveryCode, error) {
+	ctx, span := r.tracer.Start(ctx, "GetUnusedRecoveryCodes.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	query := `SELECT id, user_id, code_hash FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL;`
+	rows, err := r.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch recovery codes")
+		return nil, err
+	}
+	defer rows.Close()
+
+	recoveryCodes := make([]model.RecoveryCode, 0, 10)
+	for rows.Next() {
+		var code model.RecoveryCode
+		if err := rows.Scan(&code.Id, &code.UserID, &code.CodeHash); err != nil {
+			return nil, err
+		}
+		recoveryCodes = append(recoveryCodes, code)
+	}
+
+	return recoveryCodes, rows.Err()
+}
+
+func (r *PostgresMfaRepository) UseRecoveryCode(ctx context.Context, id int) (bool, error) {
+	ctx, span := r.tracer.Start(ctx, "UseRecoveryCode.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("recovery_code.id", id))
+
+	result, err := r.db.ExecContext(ctx, `UPDATE recovery_codes SET used_at = NOW() WHERE id = $1 AND used_at IS NULL;`, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to use recovery code")
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
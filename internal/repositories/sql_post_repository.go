@@ -158,9 +158,9 @@ func (r *PostgresPostRepository) UpdatePost(ctx context.Context, dto model.PostU
 	fmt.Println(sqlString)
 
 	// Execute the update call
-	_, err := r.db.Exec(sqlString, args...)
-	if err != nil {
-		zap.L().Fatal("Failed to execute sql query", zap.Error(err))
+	if _, err := r.db.Exec(sqlString, args...); err != nil {
+		span.RecordError(err)
+		return err
 	}
 
 	return nil
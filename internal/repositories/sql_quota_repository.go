@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/abhinash-kml/go-api-server/internal/connections"
+	"github.com/abhinash-kml/go-api-server/pkg/ratelimiter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+// PostgresQuotaRepository is the ratelimiter.QuotaPersister behind the quota cache, past periods are kept as usage history
+type PostgresQuotaRepository struct {
+	db     *sql.DB
+	tracer oteltracer.Tracer
+}
+
+func NewPostgresQuotaRepository(connection *connections.PostgresConnection, tracer oteltracer.Tracer) *PostgresQuotaRepository {
+	return &PostgresQuotaRepository{db: connection.DB, tracer: tracer}
+}
+
+// Save writes a batch of counters in one transaction. Snapshots are absolute, GREATEST keeps one a node took
+// before another from winding a counter back.
+func (r *PostgresQuotaRepository) Save(ctx context.Context, snapshots []ratelimiter.QuotaSnapshot) error {
+	ctx, span := r.tracer.Start(ctx, "Save.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("quota.snapshots", len(snapshots)))
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO quota_usage(key, period, period_start, used, rejected) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (key, period, period_start) DO UPDATE SET
+		used = GREATEST(quota_usage.used, EXCLUDED.used), rejected = GREATEST(quota_usage.rejected, EXCLUDED.rejected);`
+	for _, snapshot := range snapshots {
+		if _, err := tx.ExecContext(ctx, query, snapshot.Key, snapshot.Window.Period, snapshot.Window.Start, snapshot.Used, snapshot.Rejected); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to save quota counter")
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *PostgresQuotaRepository) Counters(ctx context.Context, key string, windows []ratelimiter.QuotaWindow) ([]ratelimiter.QuotaCounter, error) {
+	ctx, span := r.tracer.Start(ctx, "Counters.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("quota.key", key))
+
+	counters := make([]ratelimiter.QuotaCounter, len(windows))
+	for i, window := range windows {
+		query := `SELECT used, rejected FROM quota_usage WHERE key = $1 AND period = $2 AND period_start = $3;`
+		err := r.db.QueryRowContext(ctx, query, key, window.Period, window.Start).Scan(&counters[i].Used, &counters[i].Rejected)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to fetch quota counter")
+			return nil, err
+		}
+	}
+
+	return counters, nil
+}
@@ -16,6 +16,9 @@ import (
 	"go.uber.org/zap"
 )
 
+// Credential columns are nullable for users seeded from mocks, coalesce them so scanning into strings never fails
+const userColumns = `id, name, city, state, country, COALESCE(username, ''), COALESCE(email, ''), email_verified, role, COALESCE(password_hash, '')`
+
 type PostgresUserRepository struct {
 	db     *sql.DB
 	tracer oteltracer.Tracer
@@ -53,6 +56,12 @@ func (r *PostgresUserRepository) Setup() error {
 		}
 	}
 
+	// Mocks are inserted with explicit ids, move the serial past them so registrations don't collide
+	_, err = r.db.Exec(`SELECT setval(pg_get_serial_sequence('users', 'id'), COALESCE(MAX(id), 1)) FROM users;`)
+	if err != nil {
+		zap.L().Fatal("Failed to reset users id sequence", zap.Error(err))
+	}
+
 	return nil
 }
 
@@ -60,7 +69,7 @@ func (r *PostgresUserRepository) GetUsers(ctx context.Context) ([]model.User, er
 	ctx, span := r.tracer.Start(ctx, "GetUsers.Repository")
 	defer span.End()
 
-	query := `SELECT * FROM users;`
+	query := `SELECT ` + userColumns + ` FROM users;`
 	rows, err := r.db.Query(query)
 	if err != nil {
 		zap.L().Info("Error querying rows")
@@ -72,7 +81,7 @@ func (r *PostgresUserRepository) GetUsers(ctx context.Context) ([]model.User, er
 	var user model.User
 
 	for rows.Next() {
-		rows.Scan(&user.Id, &user.Name, &user.City, &user.State, &user.Country)
+		rows.Scan(&user.Id, &user.Name, &user.City, &user.State, &user.Country, &user.Username, &user.Email, &user.EmailVerified, &user.Role, &user.PasswordHash)
 		users = append(users, user)
 	}
 
@@ -83,14 +92,94 @@ func (r *PostgresUserRepository) GetById(ctx context.Context, id int) (*model.Us
 	ctx, span := r.tracer.Start(ctx, "GetById.Repository")
 	defer span.End()
 
-	query := `SELECT * FROM users WHERE id = $1;`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1;`
+	var user model.User
+	if err := r.db.QueryRow(query, id).Scan(&user.Id, &user.Name, &user.City, &user.State, &user.Country, &user.Username, &user.Email, &user.EmailVerified, &user.Role, &user.PasswordHash); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+
+}
+
+func (r *PostgresUserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	ctx, span := r.tracer.Start(ctx, "GetByUsername.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1;`
+	var user model.User
+	if err := r.db.QueryRow(query, username).Scan(&user.Id, &user.Name, &user.City, &user.State, &user.Country, &user.Username, &user.Email, &user.EmailVerified, &user.Role, &user.PasswordHash); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *PostgresUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	ctx, span := r.tracer.Start(ctx, "GetByEmail.Repository")
+	defer span.End()
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1;`
 	var user model.User
-	if err := r.db.QueryRow(query, id).Scan(&user.Id, &user.Name, &user.City, &user.State, &user.Country); err != nil {
+	if err := r.db.QueryRow(query, email).Scan(&user.Id, &user.Name, &user.City, &user.State, &user.Country, &user.Username, &user.Email, &user.EmailVerified, &user.Role, &user.PasswordHash); err != nil {
 		return nil, err
 	}
 
 	return &user, nil
+}
+
+// SetEmailVerified only marks the address it is given, so a link for an address that was since changed does nothing
+func (r *PostgresUserRepository) SetEmailVerified(ctx context.Context, id int, email string) error {
+	ctx, span := r.tracer.Start(ctx, "SetEmailVerified.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", id))
 
+	result, err := r.db.Exec(`UPDATE users SET email_verified = TRUE WHERE id = $1 AND email = $2;`, id, email)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (r *PostgresUserRepository) SetEmail(ctx context.Context, id int, email string) error {
+	ctx, span := r.tracer.Start(ctx, "SetEmail.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", id))
+
+	result, err := r.db.Exec(`UPDATE users SET email = $2, email_verified = FALSE WHERE id = $1 AND (email IS NULL OR email = '');`, id, email)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (r *PostgresUserRepository) SetPasswordHash(ctx context.Context, id int, passwordHash string) error {
+	ctx, span := r.tracer.Start(ctx, "SetPasswordHash.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", id))
+
+	result, err := r.db.Exec(`UPDATE users SET password_hash = $2 WHERE id = $1;`, id, passwordHash)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
 }
 
 // TODO: Check this implementation
@@ -98,8 +187,8 @@ func (r *PostgresUserRepository) InsertUser(ctx context.Context, user model.User
 	ctx, span := r.tracer.Start(ctx, "InsertUser.Repository")
 	defer span.End()
 
-	query := `INSERT INTO users(name, city, state, country) VALUES($1, $2, $3, $4)`
-	if _, err := r.db.Exec(query, user.Name, user.City, user.State, user.Country); err != nil {
+	query := `INSERT INTO users(name, city, state, country, username, email, role, password_hash) VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
+	if _, err := r.db.Exec(query, user.Name, user.City, user.State, user.Country, user.Username, user.Email, user.Role, user.PasswordHash); err != nil {
 		return err
 	}
 
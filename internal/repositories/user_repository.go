@@ -29,10 +29,16 @@ type UserRepository interface {
 	// CRUD logics
 	GetUsers(context.Context) ([]model.User, error)
 	GetById(context.Context, int) (*model.User, error)
+	GetByUsername(context.Context, string) (*model.User, error)
+	GetByEmail(context.Context, string) (*model.User, error)
 	InsertUser(context.Context, model.User) error
 	UpdateUser(context.Context, model.UserUpdateDTO) error
 	ReplaceUser(context.Context, model.UserReplaceDTO) error
 	DeleteUser(context.Context, int) error
+	SetEmailVerified(context.Context, int, string) error
+	// SetEmail gives an account without an address one, it never replaces an existing address
+	SetEmail(context.Context, int, string) error
+	SetPasswordHash(context.Context, int, string) error
 	Count() int
 }
 
@@ -100,6 +106,88 @@ func (e *InMemoryUsersRepository) GetById(ctx context.Context, id int) (*model.U
 	return nil, ErrNoRecord
 }
 
+func (e *InMemoryUsersRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	ctx, span := e.tracer.Start(ctx, "GetByUsername.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	// Users seeded from mocks have no username, never let an empty lookup match them
+	for index := range e.users {
+		if username != "" && e.users[index].Username == username {
+			return &e.users[index], nil
+		}
+	}
+
+	span.SetAttributes(attribute.Bool("user.found", false))
+	span.SetStatus(codes.Error, "failed to fetch user in repoitory")
+	return nil, ErrNoRecord
+}
+
+func (e *InMemoryUsersRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	ctx, span := e.tracer.Start(ctx, "GetByEmail.Repository")
+	defer span.End()
+
+	for index := range e.users {
+		if email != "" && e.users[index].Email == email {
+			return &e.users[index], nil
+		}
+	}
+
+	span.SetAttributes(attribute.Bool("user.found", false))
+	span.SetStatus(codes.Error, "failed to fetch user in repoitory")
+	return nil, ErrNoRecord
+}
+
+func (e *InMemoryUsersRepository) SetEmailVerified(ctx context.Context, id int, email string) error {
+	ctx, span := e.tracer.Start(ctx, "SetEmailVerified.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", id))
+
+	for index := range e.users {
+		if e.users[index].Id == id && e.users[index].Email == email {
+			e.users[index].EmailVerified = true
+			return nil
+		}
+	}
+
+	return ErrNoRecord
+}
+
+func (e *InMemoryUsersRepository) SetEmail(ctx context.Context, id int, email string) error {
+	ctx, span := e.tracer.Start(ctx, "SetEmail.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", id))
+
+	for index := range e.users {
+		if e.users[index].Id == id && e.users[index].Email == "" {
+			e.users[index].Email = email
+			e.users[index].EmailVerified = false
+			return nil
+		}
+	}
+
+	return ErrNoRecord
+}
+
+func (e *InMemoryUsersRepository) SetPasswordHash(ctx context.Context, id int, passwordHash string) error {
+	ctx, span := e.tracer.Start(ctx, "SetPasswordHash.Repository")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", id))
+
+	for index := range e.users {
+		if e.users[index].Id == id {
+			e.users[index].PasswordHash = passwordHash
+			return nil
+		}
+	}
+
+	return ErrNoRecord
+}
+
 func (e *InMemoryUsersRepository) InsertUser(ctx context.Context, user model.User) error {
 	ctx, span := e.tracer.Start(ctx, "InsertUser.Repository")
 	defer span.End()
@@ -108,7 +196,8 @@ func (e *InMemoryUsersRepository) InsertUser(ctx context.Context, user model.Use
 		attribute.String("user.name", user.Name),
 		attribute.String("user.city", user.City),
 		attribute.String("user.state", user.State),
-		attribute.String("user.country", user.Country))
+		attribute.String("user.country", user.Country),
+		attribute.String("user.username", user.Username))
 
 	e.users = append(e.users, user)
 
@@ -181,7 +270,10 @@ func (e *InMemoryUsersRepository) ReplaceUser(ctx context.Context, dto model.Use
 
 	for index := range e.users {
 		if e.users[index].Id == dto.Id {
-			e.users[index] = model.User(dto)
+			e.users[index].Name = dto.Name
+			e.users[index].City = dto.City
+			e.users[index].State = dto.State
+			e.users[index].Country = dto.Country
 			break
 		}
 	}
@@ -3,18 +3,17 @@ package servers
 import (
 	"context"
 	"crypto/tls"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
 	"github.com/abhinash-kml/go-api-server/config"
 	controller "github.com/abhinash-kml/go-api-server/internal/controllers"
 	"github.com/abhinash-kml/go-api-server/internal/middlewares"
-	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
 	"github.com/abhinash-kml/go-api-server/internal/realtime"
+	service "github.com/abhinash-kml/go-api-server/internal/services"
 	"github.com/abhinash-kml/go-api-server/pkg/util"
 	"github.com/gorilla/websocket"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
@@ -30,10 +29,42 @@ type CustomHttpServer struct {
 	mux        *http.ServeMux
 	authConfig *config.AuthTokenConfig
 
+	// HTTPS certificates, reloaded from disk while serving
+	tlsConfig    *config.TlsConfig
+	certReloader *CertReloader
+
+	// Token versions and refresh token families
+	tokens policy.TokenStore
+
+	// Revoked token ids
+	denylist policy.JwtDenylist
+
+	// Role to permission policy for RequirePermission
+	rbac *policy.RBAC
+
+	// Resolves the client address behind trusted proxies, nil trusts none
+	ipResolver *util.ClientIPResolver
+
+	// Adaptive in-flight caps per route class, nil sheds nothing
+	concurrency *middlewares.ConcurrencyLimits
+
+	// Partner API keys for ApiKeyAuthorization, nil ignores X-API-Key
+	apiKeys service.ApiKeyService
+
+	// Named rate limit policies, nil limits every route in memory with the built in default
+	limits map[string]*middlewares.RateLimitPolicy
+
 	// Controllers
+	authcontroller     controller.AuthController
 	userscontroller    controller.UsersController
 	postscontroller    controller.PostsController
 	commentscontroller controller.CommentsController
+	realtimecontroller controller.RealtimeController
+	sessionscontroller controller.SessionsController
+	mfacontroller      controller.MfaController
+	accountcontroller  controller.AccountController
+	oidccontroller     controller.OidcController
+	apikeyscontroller  controller.ApiKeysController
 
 	// Logger
 	logger zap.Logger
@@ -41,6 +72,13 @@ type CustomHttpServer struct {
 	// Realtime hub for message exchange
 	hub *realtime.Hub
 
+	// Upgrade tickets and origin allowlist for /realtime
+	tickets        realtime.ITicketStore
+	realtimeConfig *config.RealtimeConfig
+
+	// Per connection inbound message limits, nil is unlimited
+	realtimeLimits *realtime.InboundLimits
+
 	// Before start hooks
 	beforeStartHooks []Hook
 
@@ -66,6 +104,7 @@ func NewHttpWithConfig(config *config.HttpConfig, authConfig *config.AuthTokenCo
 	wrapper := &CustomHttpServer{
 		server:     internal,
 		authConfig: authConfig,
+		tlsConfig:  &config.Tls,
 	}
 
 	for _, option := range options {
@@ -158,6 +197,12 @@ func WithLogger(logger zap.Logger) FunctionalOption {
 	}
 }
 
+func WithAuthController(controller controller.AuthController) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.authcontroller = controller
+	}
+}
+
 func WithUsersController(controller controller.UsersController) FunctionalOption {
 	return func(c *CustomHttpServer) {
 		c.userscontroller = controller
@@ -176,91 +221,135 @@ func WithCommentsController(controller controller.CommentsController) Functional
 	}
 }
 
-func WithHub(hub *realtime.Hub) FunctionalOption {
+func WithTokenStore(tokens policy.TokenStore) FunctionalOption {
 	return func(c *CustomHttpServer) {
-		c.hub = hub
+		c.tokens = tokens
 	}
 }
 
-func (s *CustomHttpServer) SetupDefaultRoutes() error {
-	tracer := otel.Tracer("middlewares")
-	m := middlewares.NewMiddlewareProvider(tracer)
+func WithJwtDenylist(denylist policy.JwtDenylist) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.denylist = denylist
+	}
+}
 
-	// Token routes
-	s.mux.Handle("GET /login", m.CompileHandlers(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		accessTokenDuration, err := time.ParseDuration(s.authConfig.AccessToken.Expiration)
-		if err != nil {
-			log.Fatal("Failed to parse time duration for access token expiration")
-		}
-		refreshTokenDuration, err := time.ParseDuration(s.authConfig.RefreshToken.Expiration)
-		if err != nil {
-			log.Fatal("Failed to parse time duration for access token expiration")
-		}
+func WithRbac(rbac *policy.RBAC) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.rbac = rbac
+	}
+}
 
-		s.logger.Debug("Access Token", zap.Float64("Duration", accessTokenDuration.Minutes()))
-		s.logger.Debug("Refresh Token", zap.Float64("Duration", refreshTokenDuration.Minutes()))
-
-		// Create access token
-		accessToken, err := util.CreateJwtToken(
-			s.authConfig.AccessToken.Secret,
-			s.authConfig.AccessToken.Issuer,
-			"123", // This subject should be filled with real entity identifier
-			[]string{s.authConfig.AccessToken.Audience},
-			"testid",
-			accessTokenDuration,
-		)
-		if err != nil {
-			s.logger.Info("Access Token creation error", zap.Error(err))
-		}
+func WithSessionsController(controller controller.SessionsController) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.sessionscontroller = controller
+	}
+}
 
-		// Create refresh token
-		refreshToken, err := util.CreateJwtToken(
-			s.authConfig.RefreshToken.Secret,
-			s.authConfig.RefreshToken.Issuer,
-			"123", // This subject should be filled with real entity identifier
-			[]string{s.authConfig.RefreshToken.Audience},
-			"testid",
-			refreshTokenDuration,
-		)
-		if err != nil {
-			s.logger.Info("Refresh Token creation error", zap.Error(err))
-		}
+func WithMfaController(controller controller.MfaController) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.mfacontroller = controller
+	}
+}
 
-		response := model.AuthResponse{
-			AccessToken:  accessToken,
-			TokenType:    "Bearer",
-			ExpiresIn:    10,
-			RefreshToken: refreshToken,
-			Scope:        "null",
-		}
+func WithAccountController(controller controller.AccountController) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.accountcontroller = controller
+	}
+}
+
+func WithOidcController(controller controller.OidcController) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.oidccontroller = controller
+	}
+}
+
+func WithApiKeysController(controller controller.ApiKeysController) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.apikeyscontroller = controller
+	}
+}
 
-		s.logger.Debug("Auth Response", zap.String("Info", fmt.Sprintf("%+v", response)))
-		json.NewEncoder(w).Encode(response)
+func WithRealtimeController(controller controller.RealtimeController) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.realtimecontroller = controller
+	}
+}
 
-	}), m.RateLimit, m.Logger))
+func WithTicketStore(store realtime.ITicketStore) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.tickets = store
+	}
+}
 
-	// Subjected to change
-	s.mux.Handle("POST /access_token", m.CompileHandlers(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var requestBody model.AccessTokenRequest
-		json.NewDecoder(r.Body).Decode(&requestBody)
+func WithClientIPResolver(resolver *util.ClientIPResolver) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.ipResolver = resolver
+	}
+}
 
-		// Verify jwt using util function
-		_, claims, err := util.VerifyJwtToken(&s.authConfig.RefreshToken, requestBody.RefreshToken)
-		if err != nil {
-			s.logger.Info("Refresh token verfication failed", zap.Error(err))
-		}
+func WithConcurrencyLimits(concurrency *middlewares.ConcurrencyLimits) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.concurrency = concurrency
+	}
+}
 
-		// Check if Token ID i.e JTI is in ban list
-		// using real logic like checking JTI from banlist store
-		tokenId := claims.ID
+func WithApiKeyService(apiKeys service.ApiKeyService) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.apiKeys = apiKeys
+	}
+}
 
-		// This is placeholder logic
-		fmt.Println("Got token id:", tokenId)
+func WithRateLimitPolicies(limits map[string]*middlewares.RateLimitPolicy) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.limits = limits
+	}
+}
 
-		// If banned then dont provide a new token
-		// Else provide a new access token
+func WithRealtimeConfig(config *config.RealtimeConfig) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.realtimeConfig = config
+	}
+}
 
-	}), m.RateLimit, m.Logger))
+func WithRealtimeLimits(limits *realtime.InboundLimits) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.realtimeLimits = limits
+	}
+}
+
+func WithHub(hub *realtime.Hub) FunctionalOption {
+	return func(c *CustomHttpServer) {
+		c.hub = hub
+	}
+}
+
+func (s *CustomHttpServer) SetupDefaultRoutes() error {
+	tracer := otel.Tracer("middlewares")
+	m := middlewares.NewMiddlewareProvider(tracer, s.authConfig, s.tokens, s.denylist,
+		middlewares.WithRbac(s.rbac),
+		middlewares.WithLimits(s.limits),
+		middlewares.WithIPResolver(s.ipResolver),
+		middlewares.WithConcurrency(s.concurrency),
+		middlewares.WithApiKeys(s.apiKeys))
+
+	// Before routing, the client address is resolved behind trusted proxies and verified client certificates
+	// become principals, JwtAuthorization accepts those in place of a token
+	s.server.Handler = otelhttp.NewHandler(m.CompileHandlers(s.mux, m.ClientIP, m.ClientCertificate), "api-server")
+
+	// Liveness, never rate limited or shed so an overloaded node isn't mistaken for a dead one
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+
+	// Token routes
+	s.mux.Handle("POST /login", m.CompileHandlers(http.HandlerFunc(s.authcontroller.Login), m.LoadShed("auth"), m.RateLimitPolicy("login"), m.Logger))
+	s.mux.Handle("POST /login/mfa", m.CompileHandlers(http.HandlerFunc(s.authcontroller.LoginMfa), m.LoadShed("auth"), m.RateLimitPolicy("login"), m.Logger))
+	s.mux.Handle("POST /access_token", m.CompileHandlers(http.HandlerFunc(s.authcontroller.AccessToken), m.LoadShed("auth"), m.RateLimitPolicy("login"), m.Logger))
+	s.mux.Handle("POST /logout", m.CompileHandlers(http.HandlerFunc(s.authcontroller.Logout), m.LoadShed("auth"), m.RateLimit, m.Logger, m.JwtAuthorization))
+	s.mux.Handle("POST /logout/all", m.CompileHandlers(http.HandlerFunc(s.authcontroller.LogoutAll), m.LoadShed("auth"), m.RateLimit, m.Logger, m.JwtAuthorization, m.DenyImpersonation))
+	s.mux.Handle("GET /.well-known/jwks.json", m.CompileHandlers(http.HandlerFunc(s.authcontroller.Jwks), m.LoadShed("auth"), m.RateLimit, m.Logger))
 
 	// INFO: SSE disconnects due to IdleTimeout, find solution for it
 	s.mux.Handle("GET /sse", m.CompileHandlers(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -293,14 +382,22 @@ func (s *CustomHttpServer) SetupDefaultRoutes() error {
 		}
 	})))
 
+	var allowedOrigins []string
+	if s.realtimeConfig != nil {
+		allowedOrigins = s.realtimeConfig.AllowedOrigins
+	}
+
+	s.mux.Handle("POST /realtime/ticket", m.CompileHandlers(http.HandlerFunc(s.realtimecontroller.IssueTicket), m.LoadShed("auth"), m.RateLimit, m.Logger, m.JwtAuthorization, m.DenyImpersonation))
 	s.mux.Handle("GET /realtime", m.CompileHandlers(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		uid := r.Header.Get("uid")
+		principal, _ := policy.PrincipalFromContext(r.Context())
+		uid := principal.Subject
 
 		upgrader := websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
+			Subprotocols:    []string{realtime.BearerSubprotocol},
 			CheckOrigin: func(r *http.Request) bool {
-				return true
+				return true // Checked by WebSocketAuthorization before the upgrade
 			},
 		}
 
@@ -310,7 +407,7 @@ func (s *CustomHttpServer) SetupDefaultRoutes() error {
 			return
 		}
 
-		realtimeClient := realtime.NewClient(uid, connection, s.hub)
+		realtimeClient := realtime.NewClient(uid, principal.SessionID, connection, s.hub, s.realtimeLimits)
 		s.hub.Register(realtimeClient)
 		s.hub.Subscribe(uid)
 
@@ -319,33 +416,67 @@ func (s *CustomHttpServer) SetupDefaultRoutes() error {
 
 		// Start outgoing loop
 		go realtimeClient.WriteOutgoing()
-	})))
+	}), m.Logger, m.WebSocketAuthorization(allowedOrigins, s.tickets)))
 
 	// Users routes
-	s.mux.Handle("GET /users", m.CompileHandlers(http.HandlerFunc(s.userscontroller.GetUsers), m.Logger, m.RateLimit /* m.JwtAuthorization */)) // On test
-	s.mux.Handle("GET /users/{id}", m.CompileHandlers(http.HandlerFunc(s.userscontroller.GetById), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("GET /users/{id}/posts", m.CompileHandlers(http.HandlerFunc(s.userscontroller.GetPostsOfUser), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("POST /users", m.CompileHandlers(http.HandlerFunc(s.userscontroller.PostUser), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("PUT /users", m.CompileHandlers(http.HandlerFunc(s.userscontroller.PutUser), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("PATCH /users", m.CompileHandlers(http.HandlerFunc(s.userscontroller.PatchUser), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("DELETE /users", m.CompileHandlers(http.HandlerFunc(s.userscontroller.DeleteUser), m.Logger, m.RateLimit /* m.JwtAuthorization */))
+	s.mux.Handle("GET /users", m.CompileHandlers(http.HandlerFunc(s.userscontroller.GetUsers), m.LoadShed("reads"), m.Logger, m.ApiKeyAuthorization, m.RateLimitPolicy("reads"), m.ApiKeyQuota)) // On test
+	s.mux.Handle("GET /users/{id}", m.CompileHandlers(http.HandlerFunc(s.userscontroller.GetById), m.LoadShed("reads"), m.Logger, m.ApiKeyAuthorization, m.RateLimitPolicy("reads"), m.ApiKeyQuota))
+	s.mux.Handle("GET /users/{id}/posts", m.CompileHandlers(http.HandlerFunc(s.userscontroller.GetPostsOfUser), m.LoadShed("reads"), m.Logger, m.ApiKeyAuthorization, m.RateLimitPolicy("reads"), m.ApiKeyQuota))
+	s.mux.Handle("POST /users", m.CompileHandlers(http.HandlerFunc(s.userscontroller.PostUser), m.LoadShed("writes"), m.Logger, m.RateLimit)) // Registration stays open
+	s.mux.Handle("PUT /users", m.CompileHandlers(http.HandlerFunc(s.userscontroller.PutUser), m.LoadShed("writes"), m.Logger, m.JwtAuthorization, m.RateLimitPolicy("writes"), m.DenyImpersonation, m.RequirePermission(policy.PermUsersWrite), m.RequireScopes("users:write")))
+	s.mux.Handle("PATCH /users", m.CompileHandlers(http.HandlerFunc(s.userscontroller.PatchUser), m.LoadShed("writes"), m.Logger, m.JwtAuthorization, m.RateLimitPolicy("writes"), m.DenyImpersonation, m.RequirePermission(policy.PermUsersWrite), m.RequireScopes("users:write")))
+	s.mux.Handle("DELETE /users", m.CompileHandlers(http.HandlerFunc(s.userscontroller.DeleteUser), m.LoadShed("writes"), m.Logger, m.JwtAuthorization, m.RateLimitPolicy("writes"), m.DenyImpersonation, m.RequirePermission(policy.PermUsersDelete), m.RequireScopes("users:delete")))
+
+	// Admin support tools, impersonation is audited and unlock lifts a login lockout early
+	s.mux.Handle("POST /users/{id}/impersonate", m.CompileHandlers(http.HandlerFunc(s.authcontroller.Impersonate), m.LoadShed("auth"), m.Logger, m.RateLimit, m.JwtAuthorization, m.DenyImpersonation, m.RequirePermission(policy.PermUsersImpersonate)))
+	s.mux.Handle("POST /users/{id}/unlock", m.CompileHandlers(http.HandlerFunc(s.authcontroller.UnlockUser), m.LoadShed("auth"), m.Logger, m.RateLimit, m.JwtAuthorization, m.RequirePermission(policy.PermUsersUnlock)))
+
+	// Partner API keys, managed by admins, a key may read its own usage
+	s.mux.Handle("POST /api-keys", m.CompileHandlers(http.HandlerFunc(s.apikeyscontroller.CreateApiKey), m.LoadShed("auth"), m.Logger, m.RateLimit, m.JwtAuthorization, m.DenyImpersonation, m.RequirePermission(policy.PermApiKeysManage)))
+	s.mux.Handle("GET /api-keys", m.CompileHandlers(http.HandlerFunc(s.apikeyscontroller.GetApiKeys), m.LoadShed("auth"), m.Logger, m.RateLimit, m.JwtAuthorization, m.DenyImpersonation, m.RequirePermission(policy.PermApiKeysManage)))
+	s.mux.Handle("DELETE /api-keys/{id}", m.CompileHandlers(http.HandlerFunc(s.apikeyscontroller.RevokeApiKey), m.LoadShed("auth"), m.Logger, m.RateLimit, m.JwtAuthorization, m.DenyImpersonation, m.RequirePermission(policy.PermApiKeysManage)))
+	s.mux.Handle("GET /api-keys/{id}/usage", m.CompileHandlers(http.HandlerFunc(s.apikeyscontroller.GetApiKeyUsage), m.LoadShed("auth"), m.Logger, m.ApiKeyAuthorization, m.JwtAuthorization, m.RateLimit))
+
+	// Session routes
+	s.mux.Handle("GET /users/{id}/sessions", m.CompileHandlers(http.HandlerFunc(s.sessionscontroller.GetSessions), m.LoadShed("auth"), m.Logger, m.RateLimit, m.JwtAuthorization))
+	s.mux.Handle("DELETE /users/{id}/sessions/{sid}", m.CompileHandlers(http.HandlerFunc(s.sessionscontroller.DeleteSession), m.LoadShed("auth"), m.Logger, m.RateLimit, m.JwtAuthorization, m.DenyImpersonation))
+
+	// OpenID Connect provider, the code is exchanged on /access_token
+	if s.authConfig.Oidc.Enabled {
+		s.mux.Handle("GET /.well-known/openid-configuration", m.CompileHandlers(http.HandlerFunc(s.oidccontroller.Discovery), m.LoadShed("auth"), m.RateLimit, m.Logger))
+		s.mux.Handle("GET /authorize", m.CompileHandlers(http.HandlerFunc(s.oidccontroller.AuthorizePage), m.LoadShed("auth"), m.RateLimit, m.Logger))
+		s.mux.Handle("POST /authorize", m.CompileHandlers(http.HandlerFunc(s.oidccontroller.Authorize), m.LoadShed("auth"), m.RateLimitPolicy("login"), m.Logger))
+		s.mux.Handle("GET /userinfo", m.CompileHandlers(http.HandlerFunc(s.oidccontroller.UserInfo), m.LoadShed("auth"), m.RateLimit, m.Logger, m.JwtAuthorization, m.RequireScopes(policy.ScopeOpenID)))
+		s.mux.Handle("POST /userinfo", m.CompileHandlers(http.HandlerFunc(s.oidccontroller.UserInfo), m.LoadShed("auth"), m.RateLimit, m.Logger, m.JwtAuthorization, m.RequireScopes(policy.ScopeOpenID)))
+	}
+
+	// Account recovery and verification, reached from emailed links so no bearer token
+	s.mux.Handle("POST /password/forgot", m.CompileHandlers(http.HandlerFunc(s.accountcontroller.ForgotPassword), m.LoadShed("auth"), m.RateLimitPolicy("login"), m.Logger))
+	s.mux.Handle("POST /password/reset", m.CompileHandlers(http.HandlerFunc(s.accountcontroller.ResetPassword), m.LoadShed("auth"), m.RateLimitPolicy("login"), m.Logger))
+	s.mux.Handle("GET /verify-email", m.CompileHandlers(http.HandlerFunc(s.accountcontroller.VerifyEmail), m.LoadShed("auth"), m.RateLimit, m.Logger))
+	// Resending runs as the account, one created without an address can add it here
+	s.mux.Handle("POST /verify-email/resend", m.CompileHandlers(http.HandlerFunc(s.accountcontroller.ResendVerification), m.LoadShed("auth"), m.RateLimitPolicy("login"), m.Logger, m.JwtAuthorization, m.DenyImpersonation))
+
+	// Two-factor routes
+	s.mux.Handle("POST /2fa/totp/enroll", m.CompileHandlers(http.HandlerFunc(s.mfacontroller.EnrollTotp), m.LoadShed("auth"), m.RateLimit, m.Logger, m.JwtAuthorization, m.DenyImpersonation))
+	s.mux.Handle("POST /2fa/totp/confirm", m.CompileHandlers(http.HandlerFunc(s.mfacontroller.ConfirmTotp), m.LoadShed("auth"), m.RateLimit, m.Logger, m.JwtAuthorization, m.DenyImpersonation))
 
 	// Post routes
-	s.mux.Handle("GET /posts", m.CompileHandlers(http.HandlerFunc(s.postscontroller.GetPosts), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("GET /posts/{id}", m.CompileHandlers(http.HandlerFunc(s.postscontroller.GetById), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("GET /posts/{id}/comments", m.CompileHandlers(http.HandlerFunc(s.postscontroller.GetCommentsOfPost), m.Logger, m.RateLimit /* m.JwtAuthorization */)) // NEW
-	s.mux.Handle("POST /posts", m.CompileHandlers(http.HandlerFunc(s.postscontroller.PostPost), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("PUT /posts", m.CompileHandlers(http.HandlerFunc(s.postscontroller.PutPost), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("PATCH /posts", m.CompileHandlers(http.HandlerFunc(s.postscontroller.PatchPost), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("DELETE /posts", m.CompileHandlers(http.HandlerFunc(s.postscontroller.DeletePost), m.Logger, m.RateLimit /* m.JwtAuthorization */))
+	s.mux.Handle("GET /posts", m.CompileHandlers(http.HandlerFunc(s.postscontroller.GetPosts), m.LoadShed("reads"), m.Logger, m.ApiKeyAuthorization, m.RateLimitPolicy("reads"), m.ApiKeyQuota))
+	s.mux.Handle("GET /posts/{id}", m.CompileHandlers(http.HandlerFunc(s.postscontroller.GetById), m.LoadShed("reads"), m.Logger, m.ApiKeyAuthorization, m.RateLimitPolicy("reads"), m.ApiKeyQuota))
+	s.mux.Handle("GET /posts/{id}/comments", m.CompileHandlers(http.HandlerFunc(s.postscontroller.GetCommentsOfPost), m.LoadShed("reads"), m.Logger, m.ApiKeyAuthorization, m.RateLimitPolicy("reads"), m.ApiKeyQuota)) // NEW
+	s.mux.Handle("POST /posts", m.CompileHandlers(http.HandlerFunc(s.postscontroller.PostPost), m.LoadShed("writes"), m.Logger, m.JwtAuthorization, m.RateLimitPolicy("writes"), m.RequirePermission(policy.PermPostsWrite), m.RequireScopes("posts:write"), m.RequireVerifiedEmail))
+	s.mux.Handle("PUT /posts", m.CompileHandlers(http.HandlerFunc(s.postscontroller.PutPost), m.LoadShed("writes"), m.Logger, m.JwtAuthorization, m.RateLimitPolicy("writes"), m.RequirePermission(policy.PermPostsWrite), m.RequireScopes("posts:write")))
+	s.mux.Handle("PATCH /posts", m.CompileHandlers(http.HandlerFunc(s.postscontroller.PatchPost), m.LoadShed("writes"), m.Logger, m.JwtAuthorization, m.RateLimitPolicy("writes"), m.RequirePermission(policy.PermPostsWrite), m.RequireScopes("posts:write")))
+	s.mux.Handle("DELETE /posts", m.CompileHandlers(http.HandlerFunc(s.postscontroller.DeletePost), m.LoadShed("writes"), m.Logger, m.JwtAuthorization, m.RateLimitPolicy("writes"), m.RequirePermission(policy.PermPostsDelete), m.RequireScopes("posts:delete")))
 
 	// Comments routes
-	s.mux.Handle("GET /comments", m.CompileHandlers(http.HandlerFunc(s.commentscontroller.GetComments), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("GET /comments/{id}", m.CompileHandlers(http.HandlerFunc(s.commentscontroller.GetById), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("POST /comments", m.CompileHandlers(http.HandlerFunc(s.commentscontroller.PostComment), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("PUT /comments", m.CompileHandlers(http.HandlerFunc(s.commentscontroller.PutComment), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("PATCH /comments", m.CompileHandlers(http.HandlerFunc(s.commentscontroller.PatchComment), m.Logger, m.RateLimit /* m.JwtAuthorization */))
-	s.mux.Handle("DELETE /comments", m.CompileHandlers(http.HandlerFunc(s.commentscontroller.DeleteComment), m.Logger, m.RateLimit /* m.JwtAuthorization */))
+	s.mux.Handle("GET /comments", m.CompileHandlers(http.HandlerFunc(s.commentscontroller.GetComments), m.LoadShed("reads"), m.Logger, m.ApiKeyAuthorization, m.RateLimitPolicy("reads"), m.ApiKeyQuota))
+	s.mux.Handle("GET /comments/{id}", m.CompileHandlers(http.HandlerFunc(s.commentscontroller.GetById), m.LoadShed("reads"), m.Logger, m.ApiKeyAuthorization, m.RateLimitPolicy("reads"), m.ApiKeyQuota))
+	s.mux.Handle("POST /comments", m.CompileHandlers(http.HandlerFunc(s.commentscontroller.PostComment), m.LoadShed("writes"), m.Logger, m.JwtAuthorization, m.RateLimitPolicy("writes"), m.RequirePermission(policy.PermCommentsWrite), m.RequireScopes("comments:write"), m.RequireVerifiedEmail))
+	s.mux.Handle("PUT /comments", m.CompileHandlers(http.HandlerFunc(s.commentscontroller.PutComment), m.LoadShed("writes"), m.Logger, m.JwtAuthorization, m.RateLimitPolicy("writes"), m.RequirePermission(policy.PermCommentsWrite), m.RequireScopes("comments:write")))
+	s.mux.Handle("PATCH /comments", m.CompileHandlers(http.HandlerFunc(s.commentscontroller.PatchComment), m.LoadShed("writes"), m.Logger, m.JwtAuthorization, m.RateLimitPolicy("writes"), m.RequirePermission(policy.PermCommentsWrite), m.RequireScopes("comments:write")))
+	s.mux.Handle("DELETE /comments", m.CompileHandlers(http.HandlerFunc(s.commentscontroller.DeleteComment), m.LoadShed("writes"), m.Logger, m.JwtAuthorization, m.RateLimitPolicy("writes"), m.RequirePermission(policy.PermCommentsDelete), m.RequireScopes("comments:delete")))
 
 	return nil
 }
@@ -383,12 +514,28 @@ func (s *CustomHttpServer) Start() error {
 		}
 	}
 
+	// Certificates from config take over any WithTlsConfig
+	if s.tlsConfig != nil && s.tlsConfig.Enabled() {
+		reloader, err := NewCertReloader(s.tlsConfig, s.logger)
+		if err != nil {
+			s.logger.Error("Failed to load tls certificates", zap.Error(err))
+			return err
+		}
+		s.certReloader = reloader
+		s.server.TLSConfig = reloader.TlsConfig()
+		go reloader.Watch()
+	}
+
 	fmt.Println("Starting HTTP server on ", s.server.Addr)
 
 	// Start the actual server on another goroutine and listen for error on buffered channel
 	errChan := make(chan error, 1)
 	go func() {
-		errChan <- s.server.ListenAndServe()
+		if s.server.TLSConfig != nil {
+			errChan <- s.server.ListenAndServeTLS("", "")
+		} else {
+			errChan <- s.server.ListenAndServe()
+		}
 	}()
 
 	// Start realtime hub
@@ -441,6 +588,10 @@ func (s *CustomHttpServer) Stop() error {
 	defer cancel()
 	s.server.Shutdown(ctx)
 
+	if s.certReloader != nil {
+		s.certReloader.Stop()
+	}
+
 	// Execute after stop hooks
 	for _, hooks := range s.afterStopHooks {
 		if err := hooks(); err != nil {
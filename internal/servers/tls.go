@@ -0,0 +1,173 @@
+package servers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	"go.uber.org/zap"
+)
+
+// CertReloader serves the certificate and client CA bundle named in config, swapping them when the files change
+type CertReloader struct {
+	config   *config.TlsConfig
+	auth     tls.ClientAuthType
+	interval time.Duration
+	logger   zap.Logger
+
+	current atomic.Pointer[tls.Config]
+	stamps  map[string]fileStamp
+	stop    chan struct{}
+}
+
+// fileStamp is what we compare between polls, a rename over the file changes both
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func NewCertReloader(cfg *config.TlsConfig, logger zap.Logger) (*CertReloader, error) {
+	auth, err := parseClientAuth(cfg.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	if auth != tls.NoClientCert && cfg.ClientCAFile == "" {
+		return nil, fmt.Errorf("client_auth %q requires client_ca_file", cfg.ClientAuth)
+	}
+
+	interval, err := time.ParseDuration(cfg.ReloadInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reload_interval: %w", err)
+	}
+
+	reloader := &CertReloader{
+		config:   cfg,
+		auth:     auth,
+		interval: interval,
+		logger:   logger,
+		stop:     make(chan struct{}),
+	}
+
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+func parseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unsupported client_auth %q", mode)
+	}
+}
+
+// TlsConfig is handed to the http.Server, every handshake picks up the latest loaded config
+func (r *CertReloader) TlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+func (r *CertReloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+	return files
+}
+
+func (r *CertReloader) load() error {
+	stamps := make(map[string]fileStamp, 3)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+
+	next := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   r.auth,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	if r.config.ClientCAFile != "" {
+		data, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in %s", r.config.ClientCAFile)
+		}
+		next.ClientCAs = pool
+	}
+
+	r.current.Store(next)
+	r.stamps = stamps
+
+	return nil
+}
+
+func (r *CertReloader) changed() bool {
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			// Mid-rotation, try again on the next tick
+			return false
+		}
+		if stamp := r.stamps[file]; !stamp.modTime.Equal(info.ModTime()) || stamp.size != info.Size() {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch polls the files until Stop, a bad reload keeps serving the previous certificates
+func (r *CertReloader) Watch() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+
+			if err := r.load(); err != nil {
+				r.logger.Error("Failed reloading tls certificates", zap.Error(err))
+				continue
+			}
+			r.logger.Info("Reloaded tls certificates", zap.String("cert", r.config.CertFile))
+		}
+	}
+}
+
+func (r *CertReloader) Stop() {
+	close(r.stop)
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	"github.com/abhinash-kml/go-api-server/internal/connections"
+	"github.com/abhinash-kml/go-api-server/internal/mailer"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
+	"github.com/abhinash-kml/go-api-server/pkg/util"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrEmailTaken        = errors.New("Email already registered")
+	ErrInvalidEmailToken = errors.New("Invalid or expired link")
+	ErrEmailRequired     = errors.New("Email required")
+	ErrEmailAlreadySet   = errors.New("Account already has an email address")
+	ErrEmailVerified     = errors.New("Email already verified")
+)
+
+// AccountService handles the emailed flows, verification of the address and password reset
+type AccountService interface {
+	SendVerificationEmail(context.Context, *model.User) error
+	VerifyEmail(context.Context, string) error
+	ResendVerification(context.Context, string) error
+	ForgotPassword(context.Context, string) error
+	ResetPassword(context.Context, model.ResetPasswordRequest) error
+}
+
+type LocalAccountService struct {
+	repo     repository.UserRepository
+	mailer   mailer.Mailer
+	cache    *redis.Client
+	tokens   policy.TokenStore
+	denylist policy.JwtDenylist
+	config   *config.AuthTokenConfig
+	tracer   oteltracer.Tracer
+}
+
+func NewLocalAccountService(repository repository.UserRepository, mailer mailer.Mailer, conn *connections.RedisConnection, tokens policy.TokenStore, denylist policy.JwtDenylist, config *config.AuthTokenConfig, tracer oteltracer.Tracer) *LocalAccountService {
+	return &LocalAccountService{
+		repo:     repository,
+		mailer:   mailer,
+		cache:    conn.Client,
+		tokens:   tokens,
+		denylist: denylist,
+		config:   config,
+		tracer:   tracer,
+	}
+}
+
+func (s *LocalAccountService) SendVerificationEmail(ctx context.Context, user *model.User) error {
+	ctx, span := s.tracer.Start(ctx, "SendVerificationEmail.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", user.Id))
+
+	if user.Email == "" || user.EmailVerified {
+		return nil
+	}
+
+	ttl, err := time.ParseDuration(s.config.Email.VerifyExpiration)
+	if err != nil {
+		return err
+	}
+
+	claims := newJwtClaims(&s.config.AccessToken, strconv.Itoa(user.Id), newTokenId(), ttl)
+	claims.Email = user.Email
+	claims.TokenUse = model.TokenUseEmailVerify
+	token, err := util.CreateJwtToken(s.config.AccessToken.Secret, claims)
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening the link below, it expires in %s.\n\n%s\n",
+			user.Name, ttl, withQuery(s.config.Email.VerifyURL, url.Values{"token": {token}})),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to send verification email")
+		return err
+	}
+
+	return nil
+}
+
+func (s *LocalAccountService) VerifyEmail(ctx context.Context, token string) error {
+	ctx, span := s.tracer.Start(ctx, "VerifyEmail.Service")
+	defer span.End()
+
+	claims, err := s.redeem(ctx, token, model.TokenUseEmailVerify)
+	if err != nil {
+		span.SetStatus(codes.Error, "invalid verification token")
+		return err
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return ErrInvalidEmailToken
+	}
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	if err := s.repo.SetEmailVerified(ctx, userId, claims.Email); err != nil {
+		span.RecordError(err)
+		if errors.Is(err, repository.ErrNoRecord) {
+			return ErrInvalidEmailToken // The user or their address changed since the link was sent
+		}
+		return err
+	}
+
+	// Access tokens only pick up the new status on the next refresh or login
+	s.cache.Del(ctx, fmt.Sprintf("user:%d", userId))
+
+	return nil
+}
+
+// ResendVerification mails the caller a fresh link, an account without an address takes the one given here
+func (s *LocalAccountService) ResendVerification(ctx context.Context, email string) error {
+	ctx, span := s.tracer.Start(ctx, "ResendVerification.Service")
+	defer span.End()
+
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok || principal.UserID == 0 {
+		span.SetStatus(codes.Error, "no user principal")
+		return ErrForbidden
+	}
+
+	span.SetAttributes(attribute.Int("user.id", principal.UserID))
+
+	user, err := s.repo.GetById(ctx, principal.UserID)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	if user.EmailVerified {
+		span.SetStatus(codes.Error, "email already verified")
+		return ErrEmailVerified
+	}
+
+	switch {
+	case user.Email == "" && email == "":
+		span.SetStatus(codes.Error, "no email to verify")
+		return ErrEmailRequired
+	case user.Email == "":
+		if _, err := s.repo.GetByEmail(ctx, email); err == nil {
+			span.SetStatus(codes.Error, "email already registered")
+			return ErrEmailTaken
+		} else if !errors.Is(err, repository.ErrNoRecord) && !errors.Is(err, sql.ErrNoRows) {
+			span.RecordError(err)
+			return err
+		}
+
+		if err := s.repo.SetEmail(ctx, user.Id, email); err != nil {
+			span.RecordError(err)
+			if errors.Is(err, repository.ErrNoRecord) {
+				return ErrEmailAlreadySet // Another request set one first
+			}
+			return err
+		}
+		s.cache.Del(ctx, fmt.Sprintf("user:%d", user.Id))
+
+		updated := *user
+		updated.Email = email
+		user = &updated
+	case email != "" && email != user.Email:
+		// Changing an address goes through its own flow, this only finishes verifying the current one
+		span.SetStatus(codes.Error, "email differs from the account's")
+		return ErrEmailAlreadySet
+	}
+
+	return s.SendVerificationEmail(ctx, user)
+}
+
+// ForgotPassword never reveals whether the address is registered, the mail goes out in the background
+// so the response time doesn't tell either
+func (s *LocalAccountService) ForgotPassword(ctx context.Context, email string) error {
+	ctx, span := s.tracer.Start(ctx, "ForgotPassword.Service")
+	defer span.End()
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoRecord) || errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		span.RecordError(err)
+		return err
+	}
+
+	span.SetAttributes(attribute.Int("user.id", user.Id))
+
+	go func(ctx context.Context) {
+		if err := s.sendPasswordReset(ctx, user); err != nil {
+			zap.L().Error("Failed to send password reset email", zap.Int("user", user.Id), zap.Error(err))
+		}
+	}(context.WithoutCancel(ctx))
+
+	return nil
+}
+
+func (s *LocalAccountService) sendPasswordReset(ctx context.Context, user *model.User) error {
+	ttl, err := time.ParseDuration(s.config.Email.ResetExpiration)
+	if err != nil {
+		return err
+	}
+
+	subject := strconv.Itoa(user.Id)
+	version, err := s.tokens.CurrentVersion(ctx, subject)
+	if err != nil {
+		return err
+	}
+
+	// Bound to the token version, so a completed reset (which bumps it) voids every other outstanding link
+	claims := newJwtClaims(&s.config.AccessToken, subject, newTokenId(), ttl)
+	claims.Version = version
+	claims.TokenUse = model.TokenUsePasswordReset
+	token, err := util.CreateJwtToken(s.config.AccessToken.Secret, claims)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account %s. If that was you, open the link below within %s.\n\n%s\n\nIf it wasn't, you can ignore this email.\n",
+			user.Name, user.Username, ttl, withQuery(s.config.Email.ResetURL, url.Values{"token": {token}})),
+	})
+}
+
+// ResetPassword sets the new password and logs the user out everywhere
+func (s *LocalAccountService) ResetPassword(ctx context.Context, dto model.ResetPasswordRequest) error {
+	ctx, span := s.tracer.Start(ctx, "ResetPassword.Service")
+	defer span.End()
+
+	claims, err := s.redeem(ctx, dto.Token, model.TokenUsePasswordReset)
+	if err != nil {
+		span.SetStatus(codes.Error, "invalid reset token")
+		return err
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return ErrInvalidEmailToken
+	}
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	passwordHash, err := util.HashPassword(dto.Password)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	if err := s.repo.SetPasswordHash(ctx, userId, passwordHash); err != nil {
+		span.RecordError(err)
+		if errors.Is(err, repository.ErrNoRecord) {
+			return ErrInvalidEmailToken
+		}
+		return err
+	}
+
+	if _, err := s.tokens.BumpVersion(ctx, claims.Subject); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to revoke tokens after password reset")
+		return err
+	}
+
+	zap.L().Info("Password reset", zap.Int("user", userId))
+
+	return nil
+}
+
+// redeem verifies an emailed token and burns its JTI, of two racing requests only the first gets through
+func (s *LocalAccountService) redeem(ctx context.Context, token, use string) (*model.CustomJwtClaims, error) {
+	claims, err := verifyJwtToken(&s.config.AccessToken, token)
+	if err != nil || claims.TokenUse != use {
+		return nil, ErrInvalidEmailToken
+	}
+
+	if use == model.TokenUsePasswordReset && !policy.IsJwtValidByVersion(ctx, claims.Subject, claims.Version, s.tokens) {
+		return nil, ErrInvalidEmailToken
+	}
+
+	first, err := s.denylist.Deny(ctx, claims.ID, time.Until(claims.ExpiresAt.Time))
+	if err != nil {
+		return nil, err
+	}
+	if !first {
+		return nil, ErrInvalidEmailToken
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
+	"github.com/abhinash-kml/go-api-server/pkg/ratelimiter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+var ErrInvalidApiKey = errors.New("Invalid API key")
+
+// Keys look like ak_<8 char id>_<secret>, the part before the second underscore is the visible prefix
+const (
+	apiKeyMarker       = "ak_"
+	apiKeyPrefixLength = len(apiKeyMarker) + 8
+)
+
+type ApiKeyService interface {
+	CreateApiKey(context.Context, model.ApiKeyCreateRequest) (*model.ApiKeyCreatedResponse, error)
+	GetApiKeys(context.Context) ([]model.ApiKey, error)
+	RevokeApiKey(context.Context, int) error
+	GetUsage(context.Context, int) (*model.ApiKeyUsageResponse, error)
+	Authenticate(context.Context, string) (*model.ApiKey, error)
+	ConsumeQuota(context.Context, *model.ApiKey) (ratelimiter.Decision, error)
+}
+
+type LocalApiKeyService struct {
+	repo   repository.ApiKeyRepository
+	quotas *ratelimiter.QuotaLimiter
+	config *config.ApiKeyConfig
+	tracer oteltracer.Tracer
+
+	// Keys found by prefix, so Authenticate stays off the database for a key's every request
+	cache      map[string]cachedApiKey
+	cacheMutex sync.Mutex
+}
+
+type cachedApiKey struct {
+	key     model.ApiKey
+	expires time.Time
+}
+
+func NewLocalApiKeyService(repository repository.ApiKeyRepository, quotas *ratelimiter.QuotaLimiter, config *config.ApiKeyConfig, tracer oteltracer.Tracer) *LocalApiKeyService {
+	return &LocalApiKeyService{
+		repo:   repository,
+		quotas: quotas,
+		config: config,
+		tracer: tracer,
+		cache:  make(map[string]cachedApiKey),
+	}
+}
+
+// CreateApiKey is admin only, the plain key is returned here and never again
+func (s *LocalApiKeyService) CreateApiKey(ctx context.Context, dto model.ApiKeyCreateRequest) (*model.ApiKeyCreatedResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "CreateApiKey.Service")
+	defer span.End()
+
+	principal, err := authorizeAdmin(ctx)
+	if err != nil {
+		span.SetStatus(codes.Error, "caller may not create api keys")
+		return nil, err
+	}
+
+	plain, prefix, err := newApiKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := model.ApiKey{
+		Name:         dto.Name,
+		Prefix:       prefix,
+		Hash:         hashApiKey(plain),
+		Scopes:       dto.Scopes,
+		DailyQuota:   s.config.DailyQuota,
+		MonthlyQuota: s.config.MonthlyQuota,
+		CreatedBy:    principal.UserID,
+	}
+	if dto.DailyQuota != nil {
+		key.DailyQuota = *dto.DailyQuota
+	}
+	if dto.MonthlyQuota != nil {
+		key.MonthlyQuota = *dto.MonthlyQuota
+	}
+
+	created, err := s.repo.Create(ctx, key)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to save api key")
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("api_key.id", created.Id),
+		attribute.String("api_key.prefix", created.Prefix))
+
+	return &model.ApiKeyCreatedResponse{ApiKey: *created, Key: plain}, nil
+}
+
+// GetApiKeys is admin only and includes revoked keys
+func (s *LocalApiKeyService) GetApiKeys(ctx context.Context) ([]model.ApiKey, error) {
+	ctx, span := s.tracer.Start(ctx, "GetApiKeys.Service")
+	defer span.End()
+
+	if _, err := authorizeAdmin(ctx); err != nil {
+		span.SetStatus(codes.Error, "caller may not list api keys")
+		return nil, err
+	}
+
+	keys, err := s.repo.GetAll(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch api keys from repository")
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("api_key.count", len(keys)))
+	return keys, nil
+}
+
+// RevokeApiKey is admin only and takes effect on the key's next request to this node, other nodes within cache_expiration
+func (s *LocalApiKeyService) RevokeApiKey(ctx context.Context, id int) error {
+	ctx, span := s.tracer.Start(ctx, "RevokeApiKey.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("api_key.id", id))
+
+	if _, err := authorizeAdmin(ctx); err != nil {
+		span.SetStatus(codes.Error, "caller may not revoke api keys")
+		return err
+	}
+
+	if err := s.repo.Revoke(ctx, id, time.Now()); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+	for prefix, cached := range s.cache {
+		if cached.key.Id == id {
+			delete(s.cache, prefix)
+		}
+	}
+
+	return nil
+}
+
+// GetUsage is open to admins and to the key itself
+func (s *LocalApiKeyService) GetUsage(ctx context.Context, id int) (*model.ApiKeyUsageResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "GetUsage.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("api_key.id", id))
+
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok || !(principal.IsAdmin() || (principal.IsApiKey() && principal.ApiKeyID == id)) {
+		span.SetStatus(codes.Error, "caller may not read api key usage")
+		return nil, ErrForbidden
+	}
+
+	key, err := s.repo.GetById(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	usage, err := s.quotas.Usage(ctx, quotaKey(key), keyQuotas(key)...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch quota counters")
+		return nil, err
+	}
+
+	response := &model.ApiKeyUsageResponse{
+		ApiKeyID: key.Id,
+		Prefix:   key.Prefix,
+		Quotas:   make([]model.ApiKeyQuotaUsage, len(usage)),
+	}
+	for i, quota := range usage {
+		response.Quotas[i] = model.ApiKeyQuotaUsage{
+			Period:    string(quota.Period),
+			Limit:     quota.Limit,
+			Used:      quota.Used,
+			Rejected:  quota.Rejected,
+			Remaining: quota.Remaining,
+			Start:     quota.Start,
+			ResetAt:   quota.ResetAt,
+		}
+	}
+
+	return response, nil
+}
+
+// Authenticate finds the key by its prefix and checks the rest, unknown, revoked and wrong keys all look the same
+func (s *LocalApiKeyService) Authenticate(ctx context.Context, plain string) (*model.ApiKey, error) {
+	ctx, span := s.tracer.Start(ctx, "Authenticate.Service")
+	defer span.End()
+
+	if !strings.HasPrefix(plain, apiKeyMarker) || len(plain) <= apiKeyPrefixLength || plain[apiKeyPrefixLength] != '_' {
+		span.SetStatus(codes.Error, "malformed api key")
+		return nil, ErrInvalidApiKey
+	}
+
+	prefix := plain[:apiKeyPrefixLength]
+	span.SetAttributes(attribute.String("api_key.prefix", prefix))
+
+	key, err := s.keyByPrefix(ctx, prefix)
+	if errors.Is(err, repository.ErrNoRecord) {
+		span.SetStatus(codes.Error, "unknown api key")
+		return nil, ErrInvalidApiKey
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashApiKey(plain)), []byte(key.Hash)) != 1 || key.Revoked() {
+		span.SetStatus(codes.Error, "invalid or revoked api key")
+		return nil, ErrInvalidApiKey
+	}
+
+	span.SetAttributes(attribute.Int("api_key.id", key.Id))
+	return key, nil
+}
+
+// keyByPrefix reuses a key looked up within cache_expiration, unknown prefixes always go to the repository
+func (s *LocalApiKeyService) keyByPrefix(ctx context.Context, prefix string) (*model.ApiKey, error) {
+	now := time.Now()
+
+	s.cacheMutex.Lock()
+	cached, ok := s.cache[prefix]
+	s.cacheMutex.Unlock()
+	if ok && now.Before(cached.expires) {
+		return &cached.key, nil
+	}
+
+	key, err := s.repo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl, err := time.ParseDuration(s.config.CacheExpiration); err == nil && ttl > 0 {
+		s.cacheMutex.Lock()
+		s.cache[prefix] = cachedApiKey{key: *key, expires: now.Add(ttl)}
+		s.cacheMutex.Unlock()
+	}
+
+	return key, nil
+}
+
+// ConsumeQuota counts one request against the key's daily and monthly quotas
+func (s *LocalApiKeyService) ConsumeQuota(ctx context.Context, key *model.ApiKey) (ratelimiter.Decision, error) {
+	ctx, span := s.tracer.Start(ctx, "ConsumeQuota.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("api_key.id", key.Id))
+
+	decision, err := s.quotas.Allow(ctx, quotaKey(key), keyQuotas(key)...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to count quota")
+		return decision, err
+	}
+
+	span.SetAttributes(attribute.Bool("quota.allowed", decision.Allowed))
+	return decision, nil
+}
+
+func quotaKey(key *model.ApiKey) string {
+	return policy.ApiKeySubjectPrefix + strconv.Itoa(key.Id)
+}
+
+func keyQuotas(key *model.ApiKey) []ratelimiter.Quota {
+	return []ratelimiter.Quota{
+		{Period: ratelimiter.QuotaDaily, Limit: key.DailyQuota},
+		{Period: ratelimiter.QuotaMonthly, Limit: key.MonthlyQuota},
+	}
+}
+
+// newApiKey returns the key to hand out once and its visible prefix
+func newApiKey() (string, string, error) {
+	id := make([]byte, 5)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	prefix := apiKeyMarker + strings.ToLower(recoveryCodeEncoding.EncodeToString(id))
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// Keys carry 256 random bits, a fast hash is enough and keeps every request off bcrypt
+func hashApiKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// countingApiKeyRepository counts the lookups that reach the repository
+type countingApiKeyRepository struct {
+	*repository.InMemoryApiKeyRepository
+	lookups int
+}
+
+func (r *countingApiKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*model.ApiKey, error) {
+	r.lookups++
+	return r.InMemoryApiKeyRepository.GetByPrefix(ctx, prefix)
+}
+
+func TestApiKeyAuthenticateCachesUntilRevoked(t *testing.T) {
+	tracer := noop.NewTracerProvider().Tracer("test")
+	repo := &countingApiKeyRepository{InMemoryApiKeyRepository: repository.NewInMemoryApiKeyRepository(tracer)}
+	s := NewLocalApiKeyService(repo, nil, &config.ApiKeyConfig{CacheExpiration: "1m"}, tracer)
+
+	admin := policy.WithPrincipal(context.Background(), &policy.Principal{Kind: policy.PrincipalUser, Subject: "1", UserID: 1, Role: policy.RoleAdmin})
+	created, err := s.CreateApiKey(admin, model.ApiKeyCreateRequest{Name: "partner"})
+	if err != nil {
+		t.Fatalf("CreateApiKey() error = %v", err)
+	}
+
+	for range 3 {
+		if _, err := s.Authenticate(context.Background(), created.Key); err != nil {
+			t.Fatalf("Authenticate() error = %v", err)
+		}
+	}
+	if repo.lookups != 1 {
+		t.Errorf("repository lookups = %d for three requests, want 1", repo.lookups)
+	}
+
+	wrong := []byte(created.Key)
+	wrong[len(wrong)-1] ^= 1
+	if _, err := s.Authenticate(context.Background(), string(wrong)); !errors.Is(err, ErrInvalidApiKey) {
+		t.Errorf("Authenticate() with a wrong secret and a cached prefix = %v, want ErrInvalidApiKey", err)
+	}
+
+	if err := s.RevokeApiKey(admin, created.Id); err != nil {
+		t.Fatalf("RevokeApiKey() error = %v", err)
+	}
+	if _, err := s.Authenticate(context.Background(), created.Key); !errors.Is(err, ErrInvalidApiKey) {
+		t.Errorf("Authenticate() after revoking = %v, want ErrInvalidApiKey", err)
+	}
+}
@@ -0,0 +1,794 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
+	"github.com/abhinash-kml/go-api-server/pkg/util"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidCredentials   = errors.New("Invalid credentials")
+	ErrUnsupportedGrantType = errors.New("Unsupported grant type")
+	ErrInvalidGrant         = errors.New("Invalid grant")
+	ErrRefreshTokenReused   = fmt.Errorf("%w: refresh token reuse detected", ErrInvalidGrant)
+	ErrInvalidClient        = errors.New("Invalid client")
+	ErrInvalidScope         = errors.New("Invalid scope")
+)
+
+const (
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeAuthorizationCode = "authorization_code"
+)
+
+// Compared against when the username doesn't exist so a miss costs as much as a wrong password
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := util.HashPassword("dummy-password-for-timing")
+	return hash
+})
+
+type AuthService interface {
+	// Login returns a challenge instead of tokens when the user has 2FA enabled
+	Login(context.Context, model.LoginRequest) (*model.AuthResponse, *model.MfaChallenge, error)
+	LoginMfa(context.Context, model.MfaLoginRequest) (*model.AuthResponse, error)
+	AccessToken(context.Context, model.AccessTokenRequest) (*model.AuthResponse, error)
+	Logout(context.Context, string) error
+	LogoutAll(context.Context, int) error
+	UnlockAccount(context.Context, int) error
+	Impersonate(context.Context, int, model.ImpersonationRequest) (*model.AuthResponse, error)
+}
+
+type LocalAuthService struct {
+	repo      repository.UserRepository
+	clients   repository.ClientRepository
+	sessions  repository.SessionRepository
+	authCodes repository.AuthCodeRepository
+	audit     repository.AuditRepository
+	mfa       MfaService
+	lockout   *LoginLockout
+	tokens    policy.TokenStore
+	denylist  policy.JwtDenylist
+	rbac      *policy.RBAC
+	config    *config.AuthTokenConfig
+	tracer    oteltracer.Tracer
+}
+
+func NewLocalAuthService(repository repository.UserRepository, clients repository.ClientRepository, sessions repository.SessionRepository, authCodes repository.AuthCodeRepository, audit repository.AuditRepository, mfa MfaService, lockout *LoginLockout, tokens policy.TokenStore, denylist policy.JwtDenylist, rbac *policy.RBAC, config *config.AuthTokenConfig, tracer oteltracer.Tracer) *LocalAuthService {
+	return &LocalAuthService{
+		repo:      repository,
+		clients:   clients,
+		sessions:  sessions,
+		authCodes: authCodes,
+		audit:     audit,
+		mfa:       mfa,
+		lockout:   lockout,
+		tokens:    tokens,
+		denylist:  denylist,
+		rbac:      rbac,
+		config:    config,
+		tracer:    tracer,
+	}
+}
+
+func (s *LocalAuthService) Login(ctx context.Context, dto model.LoginRequest) (*model.AuthResponse, *model.MfaChallenge, error) {
+	ctx, span := s.tracer.Start(ctx, "Login.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", dto.Username))
+
+	user, err := s.authenticatePassword(ctx, dto.Username, dto.Password, dto.Device.IP)
+	if err != nil {
+		span.SetStatus(codes.Error, "password authentication failed")
+		return nil, nil, err
+	}
+
+	span.SetAttributes(attribute.Int("user.id", user.Id))
+
+	scope, err := s.userScope(user, dto.Scope)
+	if err != nil {
+		span.SetStatus(codes.Error, "scope not allowed for role")
+		return nil, nil, err
+	}
+
+	enrolled, err := s.mfa.IsEnrolled(ctx, user.Id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to check mfa enrollment")
+		return nil, nil, err
+	}
+
+	// With 2FA the failures only clear once the second factor is in too
+	if enrolled {
+		challenge, err := s.mfaChallenge(ctx, user, scope)
+		return nil, challenge, err
+	}
+
+	s.lockout.Success(ctx, user.Username)
+
+	response, err := s.issueTokens(ctx, user, "", scope, dto.Device)
+	return response, nil, err
+}
+
+// authenticatePassword checks credentials behind the lockout, failures count against both the username and the IP
+func (s *LocalAuthService) authenticatePassword(ctx context.Context, username, password, ip string) (*model.User, error) {
+	if err := s.lockout.Check(ctx, username, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoRecord) || errors.Is(err, sql.ErrNoRows) {
+			util.ComparePassword(dummyPasswordHash(), password)
+			s.lockout.Failure(ctx, username, ip)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	// Users without a password hash (e.g. seeded from mocks) can't log in with credentials
+	if user.PasswordHash == "" || !util.ComparePassword(user.PasswordHash, password) {
+		s.lockout.Failure(ctx, username, ip)
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+// mfaChallenge issues the short-lived token proving the password step, it carries the already validated scope
+func (s *LocalAuthService) mfaChallenge(ctx context.Context, user *model.User, scope string) (*model.MfaChallenge, error) {
+	pendingDuration, err := time.ParseDuration(s.config.Mfa.PendingExpiration)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := strconv.Itoa(user.Id)
+	version, err := s.tokens.CurrentVersion(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := newJwtClaims(&s.config.AccessToken, subject, newTokenId(), pendingDuration)
+	claims.Scope = scope
+	claims.Version = version
+	claims.TokenUse = model.TokenUseMfaPending
+	mfaToken, err := util.CreateJwtToken(s.config.AccessToken.Secret, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.MfaChallenge{
+		MfaRequired: true,
+		MfaToken:    mfaToken,
+		ExpiresIn:   int(pendingDuration.Seconds()),
+	}, nil
+}
+
+// LoginMfa completes a 2FA login, exchanging the mfa_pending token and a code for the real token pair
+func (s *LocalAuthService) LoginMfa(ctx context.Context, dto model.MfaLoginRequest) (*model.AuthResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "LoginMfa.Service")
+	defer span.End()
+
+	claims, err := verifyJwtToken(&s.config.AccessToken, dto.MfaToken)
+	if err != nil || claims.TokenUse != model.TokenUseMfaPending {
+		span.SetStatus(codes.Error, "invalid mfa token")
+		return nil, ErrInvalidGrant
+	}
+
+	span.SetAttributes(attribute.String("token.jti", claims.ID))
+
+	if denied, err := s.denylist.IsDenied(ctx, claims.ID); err != nil || denied {
+		span.SetStatus(codes.Error, "mfa token already used")
+		return nil, ErrInvalidGrant
+	}
+
+	// A logout everywhere or password reset while the challenge was pending voids it
+	if !policy.IsJwtValidByVersion(ctx, claims.Subject, claims.Version, s.tokens) {
+		span.SetStatus(codes.Error, "token version outdated")
+		return nil, ErrInvalidGrant
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		span.SetStatus(codes.Error, "malformed subject")
+		return nil, ErrInvalidGrant
+	}
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	user, err := s.repo.GetById(ctx, userId)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "subject no longer exists")
+		return nil, ErrInvalidGrant
+	}
+
+	// Wrong codes count against the account like wrong passwords, six digits are quick to guess otherwise
+	if err := s.lockout.Check(ctx, user.Username, dto.Device.IP); err != nil {
+		span.SetStatus(codes.Error, "login locked out")
+		return nil, err
+	}
+
+	if err := s.mfa.VerifyCode(ctx, user.Id, dto.Code); err != nil {
+		if errors.Is(err, ErrInvalidMfaCode) {
+			s.lockout.Failure(ctx, user.Username, dto.Device.IP)
+		}
+		span.SetStatus(codes.Error, "mfa verification failed")
+		return nil, err
+	}
+
+	// The pending token is single use, of two racing exchanges only the first gets tokens
+	first, err := s.denylist.Deny(ctx, claims.ID, time.Until(claims.ExpiresAt.Time))
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if !first {
+		span.SetStatus(codes.Error, "mfa token already used")
+		return nil, ErrInvalidGrant
+	}
+
+	s.lockout.Success(ctx, user.Username)
+
+	// The role may have changed while the challenge was pending
+	scope, err := s.userScope(user, claims.Scope)
+	if err != nil {
+		span.SetStatus(codes.Error, "scope no longer allowed for role")
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, "", scope, dto.Device)
+}
+
+// AccessToken is the token endpoint, dispatching on the requested grant
+func (s *LocalAuthService) AccessToken(ctx context.Context, dto model.AccessTokenRequest) (*model.AuthResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "AccessToken.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("grant_type", dto.GrantType))
+
+	switch dto.GrantType {
+	case GrantTypeRefreshToken:
+		return s.refresh(ctx, dto.RefreshToken, dto.Scope, dto.Device)
+	case GrantTypeClientCredentials:
+		return s.clientCredentials(ctx, dto)
+	case GrantTypeAuthorizationCode:
+		return s.authorizationCode(ctx, dto)
+	default:
+		span.SetStatus(codes.Error, "unsupported grant type")
+		return nil, ErrUnsupportedGrantType
+	}
+}
+
+// clientCredentials authenticates a registered client and issues an access token only, no refresh token (RFC 6749 section 4.4.3)
+func (s *LocalAuthService) clientCredentials(ctx context.Context, dto model.AccessTokenRequest) (*model.AuthResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "ClientCredentials.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("client.id", dto.ClientId))
+
+	if dto.ClientId == "" || dto.ClientSecret == "" {
+		span.SetStatus(codes.Error, "missing client credentials")
+		return nil, ErrInvalidClient
+	}
+
+	client, err := s.clients.GetById(ctx, dto.ClientId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoRecord) {
+			util.ComparePassword(dummyPasswordHash(), dto.ClientSecret)
+			span.SetStatus(codes.Error, "unknown client")
+			return nil, ErrInvalidClient
+		}
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch client from repository")
+		return nil, err
+	}
+
+	if !util.ComparePassword(client.SecretHash, dto.ClientSecret) {
+		span.SetStatus(codes.Error, "client secret mismatch")
+		return nil, ErrInvalidClient
+	}
+
+	// No requested scope means everything the client is registered for
+	scopes := client.Scopes
+	if requested := strings.Fields(dto.Scope); len(requested) > 0 {
+		if !policy.HasScopes(client.Scopes, requested...) {
+			span.SetStatus(codes.Error, "scope not allowed for client")
+			return nil, ErrInvalidScope
+		}
+		scopes = requested
+	}
+
+	accessTokenDuration, err := time.ParseDuration(s.config.AccessToken.Expiration)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := policy.ClientSubjectPrefix + client.Id
+	version, err := s.tokens.CurrentVersion(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := strings.Join(scopes, " ")
+	claims := newJwtClaims(&s.config.AccessToken, subject, newTokenId(), accessTokenDuration)
+	claims.Scope = scope
+	claims.Version = version
+	claims.TokenUse = model.TokenUseAccess
+	accessToken, err := util.CreateJwtToken(s.config.AccessToken.Secret, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("token.scope", scope))
+
+	return &model.AuthResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(accessTokenDuration.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// refresh rotates a refresh token: the presented JTI is burned and a new pair is issued in the same family.
+// Presenting a JTI that was already burned revokes the whole family.
+// A requested scope may only narrow what the refresh token was granted (RFC 6749 section 6).
+func (s *LocalAuthService) refresh(ctx context.Context, refreshToken, requestedScope string, device model.DeviceInfo) (*model.AuthResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "Refresh.Service")
+	defer span.End()
+
+	claims, err := verifyJwtToken(&s.config.RefreshToken, refreshToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "refresh token verification failed")
+		return nil, ErrInvalidGrant
+	}
+
+	if claims.TokenUse != model.TokenUseRefresh {
+		span.SetStatus(codes.Error, "not a refresh token")
+		return nil, ErrInvalidGrant
+	}
+
+	span.SetAttributes(attribute.String("token.jti", claims.ID))
+
+	family, err := s.tokens.RefreshTokenFamily(ctx, claims.ID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "refresh token is not tracked")
+		return nil, ErrInvalidGrant
+	}
+
+	span.SetAttributes(attribute.String("token.family", family))
+
+	if policy.IsTokenFamilyRevoked(ctx, family, s.tokens) {
+		span.SetStatus(codes.Error, "token family revoked")
+		return nil, ErrInvalidGrant
+	}
+
+	if !policy.IsJwtValidByVersion(ctx, claims.Subject, claims.Version, s.tokens) {
+		span.SetStatus(codes.Error, "token version outdated")
+		return nil, ErrInvalidGrant
+	}
+
+	// Adding to the denylist is atomic, so of two racing requests with the same token only one wins
+	first, err := s.denylist.Deny(ctx, claims.ID, time.Until(claims.ExpiresAt.Time))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to deny refresh token")
+		return nil, err
+	}
+	if !first {
+		refreshTokenDuration, _ := time.ParseDuration(s.config.RefreshToken.Expiration)
+		if err := policy.RevokeTokenFamily(ctx, family, refreshTokenDuration, s.tokens, s.denylist); err != nil {
+			span.RecordError(err)
+		}
+
+		zap.L().Warn("Refresh token reuse detected, token family revoked",
+			zap.String("subject", claims.Subject),
+			zap.String("family", family),
+			zap.String("jti", claims.ID))
+		span.SetStatus(codes.Error, "refresh token reuse detected")
+		return nil, ErrRefreshTokenReused
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "malformed subject")
+		return nil, ErrInvalidGrant
+	}
+
+	user, err := s.repo.GetById(ctx, userId)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "subject no longer exists")
+		return nil, ErrInvalidGrant
+	}
+
+	scope := claims.Scope
+	if requested := strings.Fields(requestedScope); len(requested) > 0 {
+		if !policy.HasScopes(strings.Fields(claims.Scope), requested...) {
+			span.SetStatus(codes.Error, "scope exceeds original grant")
+			return nil, ErrInvalidScope
+		}
+		scope = strings.Join(requested, " ")
+	}
+
+	// The role may have been downgraded since the original grant
+	scope, err = s.userScope(user, scope)
+	if err != nil {
+		span.SetStatus(codes.Error, "scope no longer allowed for role")
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, family, scope, device)
+}
+
+// userScope validates requested scopes against the user's role, nothing requested means everything the role grants
+func (s *LocalAuthService) userScope(user *model.User, requested string) (string, error) {
+	role := user.Role
+	if role == "" {
+		role = policy.RoleUser
+	}
+
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return strings.Join(s.rbac.Grants(role), " "), nil
+	}
+
+	for _, scope := range scopes {
+		if policy.IsOidcScope(scope) {
+			continue
+		}
+		if !s.rbac.Can(role, policy.Permission(scope)) {
+			return "", ErrInvalidScope
+		}
+	}
+
+	return strings.Join(scopes, " "), nil
+}
+
+// Logout denies the caller's access token and, when presented, their refresh token along with its family
+func (s *LocalAuthService) Logout(ctx context.Context, refreshToken string) error {
+	ctx, span := s.tracer.Start(ctx, "Logout.Service")
+	defer span.End()
+
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok || principal.TokenID == "" {
+		span.SetStatus(codes.Error, "no token principal")
+		return ErrForbidden
+	}
+
+	span.SetAttributes(attribute.String("token.jti", principal.TokenID))
+
+	if _, err := s.denylist.Deny(ctx, principal.TokenID, time.Until(principal.ExpiresAt)); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to deny access token")
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	claims, err := verifyJwtToken(&s.config.RefreshToken, refreshToken)
+	if err != nil || claims.TokenUse != model.TokenUseRefresh {
+		span.SetStatus(codes.Error, "invalid refresh token")
+		return ErrInvalidGrant
+	}
+
+	// Nobody gets to log out someone else's session by holding their refresh token
+	if claims.Subject != principal.Subject {
+		span.SetStatus(codes.Error, "refresh token subject mismatch")
+		return ErrInvalidGrant
+	}
+
+	if _, err := s.denylist.Deny(ctx, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to deny refresh token")
+		return err
+	}
+
+	family, err := s.tokens.RefreshTokenFamily(ctx, claims.ID)
+	if err != nil {
+		return nil // Untracked or expired family, the denied JTI is enough
+	}
+
+	refreshTokenDuration, _ := time.ParseDuration(s.config.RefreshToken.Expiration)
+	if err := policy.RevokeTokenFamily(ctx, family, refreshTokenDuration, s.tokens, s.denylist); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to revoke token family")
+		return err
+	}
+
+	if err := s.sessions.DeleteSession(ctx, family); err != nil && !errors.Is(err, repository.ErrNoRecord) {
+		span.RecordError(err)
+	}
+
+	return nil
+}
+
+// LogoutAll revokes every token of a user by bumping their token version.
+// A zero userId means the caller themself, targeting anyone else requires an admin.
+func (s *LocalAuthService) LogoutAll(ctx context.Context, userId int) error {
+	_, span := s.tracer.Start(ctx, "LogoutAll.Service")
+	defer span.End()
+
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok || principal.UserID == 0 {
+		span.SetStatus(codes.Error, "no user principal")
+		return ErrForbidden
+	}
+
+	if userId == 0 {
+		userId = principal.UserID
+	}
+	if userId != principal.UserID && !principal.IsAdmin() {
+		span.SetStatus(codes.Error, "not allowed to log out other users")
+		return ErrForbidden
+	}
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	version, err := s.tokens.BumpVersion(ctx, strconv.Itoa(userId))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to bump token version")
+		return err
+	}
+
+	zap.L().Info("All tokens of user revoked",
+		zap.Int("user", userId),
+		zap.String("by", principal.Subject),
+		zap.String("version", version))
+
+	return nil
+}
+
+// UnlockAccount clears the failed login count and any lock of a user, admins only
+func (s *LocalAuthService) UnlockAccount(ctx context.Context, userId int) error {
+	ctx, span := s.tracer.Start(ctx, "UnlockAccount.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok || !principal.IsAdmin() {
+		span.SetStatus(codes.Error, "not allowed to unlock accounts")
+		return ErrForbidden
+	}
+
+	user, err := s.repo.GetById(ctx, userId)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, repository.ErrNoRecord) || errors.Is(err, sql.ErrNoRows) {
+			return repository.ErrNoRecord
+		}
+		return err
+	}
+
+	if err := s.lockout.Unlock(ctx, user.Username); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to unlock account")
+		return err
+	}
+
+	zap.L().Info("Account unlocked",
+		zap.Int("user", userId),
+		zap.String("by", principal.Subject))
+
+	return nil
+}
+
+// Impersonate issues a short-lived access token for another user carrying an act claim naming the admin.
+// There is no refresh token or session, and the issuance is audited before the token is handed out.
+func (s *LocalAuthService) Impersonate(ctx context.Context, userId int, dto model.ImpersonationRequest) (*model.AuthResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "Impersonate.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	// An impersonation token can't be used to start another one
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok || !principal.IsAdmin() || principal.IsImpersonated() {
+		span.SetStatus(codes.Error, "not allowed to impersonate")
+		return nil, ErrForbidden
+	}
+
+	user, err := s.repo.GetById(ctx, userId)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, repository.ErrNoRecord) || errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	// Acting as yourself or another admin gains nothing but a way around the audit trail
+	if user.Id == principal.UserID || user.Role == policy.RoleAdmin {
+		span.SetStatus(codes.Error, "target can't be impersonated")
+		return nil, ErrForbidden
+	}
+
+	duration, err := time.ParseDuration(s.config.Impersonation.Expiration)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err := s.userScope(user, "")
+	if err != nil {
+		return nil, err
+	}
+
+	subject := strconv.Itoa(user.Id)
+	version, err := s.tokens.CurrentVersion(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := newJwtClaims(&s.config.AccessToken, subject, newTokenId(), duration)
+	claims.Role = user.Role
+	if claims.Role == "" {
+		claims.Role = policy.RoleUser
+	}
+	claims.Scope = scope
+	claims.EmailVerified = user.EmailVerified
+	claims.Actor = &model.ActorClaim{Subject: principal.Subject}
+	claims.Version = version
+	claims.TokenUse = model.TokenUseAccess
+	token, err := util.CreateJwtToken(s.config.AccessToken.Secret, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// No audit entry, no token
+	if err := s.audit.Record(ctx, model.AuditEntry{
+		Action:   model.AuditActionImpersonate,
+		ActorID:  principal.UserID,
+		TargetID: user.Id,
+		Reason:   dto.Reason,
+		IP:       dto.Device.IP,
+		TokenID:  claims.ID,
+	}); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to audit impersonation")
+		return nil, err
+	}
+
+	zap.L().Info("Impersonation token issued",
+		zap.Int("user", user.Id),
+		zap.String("by", principal.Subject),
+		zap.String("jti", claims.ID))
+
+	return &model.AuthResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(duration.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// issueTokens mints an access/refresh pair and records the family as a session, an empty family starts a new one
+func (s *LocalAuthService) issueTokens(ctx context.Context, user *model.User, family, scope string, device model.DeviceInfo) (*model.AuthResponse, error) {
+	accessTokenDuration, err := time.ParseDuration(s.config.AccessToken.Expiration)
+	if err != nil {
+		return nil, err
+	}
+	refreshTokenDuration, err := time.ParseDuration(s.config.RefreshToken.Expiration)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := strconv.Itoa(user.Id)
+
+	if family == "" {
+		family = newTokenId()
+	}
+	refreshTokenId := newTokenId()
+
+	role := user.Role
+	if role == "" {
+		role = policy.RoleUser
+	}
+
+	version, err := s.tokens.CurrentVersion(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	accessClaims := newJwtClaims(&s.config.AccessToken, subject, newTokenId(), accessTokenDuration)
+	accessClaims.Role = role
+	accessClaims.Scope = scope
+	accessClaims.SessionID = family
+	accessClaims.EmailVerified = user.EmailVerified
+	accessClaims.Version = version
+	accessClaims.TokenUse = model.TokenUseAccess
+	accessToken, err := util.CreateJwtToken(s.config.AccessToken.Secret, accessClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshClaims := newJwtClaims(&s.config.RefreshToken, subject, refreshTokenId, refreshTokenDuration)
+	refreshClaims.Scope = scope
+	refreshClaims.SessionID = family
+	refreshClaims.Version = version
+	refreshClaims.TokenUse = model.TokenUseRefresh
+	refreshToken, err := util.CreateJwtToken(s.config.RefreshToken.Secret, refreshClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.tokens.TrackRefreshToken(ctx, refreshTokenId, family, refreshTokenDuration); err != nil {
+		return nil, err
+	}
+
+	if err := s.saveSession(ctx, user.Id, family, device, refreshTokenDuration); err != nil {
+		return nil, err
+	}
+
+	return &model.AuthResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenDuration.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        scope,
+	}, nil
+}
+
+// saveSession creates the session on login and bumps its last use on every refresh
+func (s *LocalAuthService) saveSession(ctx context.Context, userId int, family string, device model.DeviceInfo, ttl time.Duration) error {
+	now := time.Now()
+	session, err := s.sessions.GetById(ctx, family)
+	if errors.Is(err, repository.ErrNoRecord) {
+		session = &model.Session{
+			Id:        family,
+			UserID:    userId,
+			CreatedAt: now,
+		}
+	} else if err != nil {
+		return err
+	}
+
+	session.UserAgent = device.UserAgent
+	session.IP = device.IP
+	session.LastUsedAt = now
+
+	return s.sessions.SaveSession(ctx, *session, ttl)
+}
+
+// newJwtClaims starts our claims from the registered ones of the token config
+func newJwtClaims(cfg *config.TokenConfig, subject, id string, expiry time.Duration) *model.CustomJwtClaims {
+	return &model.CustomJwtClaims{RegisteredClaims: util.NewRegisteredClaims(cfg, subject, id, expiry)}
+}
+
+func verifyJwtToken(cfg *config.TokenConfig, token string) (*model.CustomJwtClaims, error) {
+	claims := &model.CustomJwtClaims{}
+	if _, err := util.VerifyJwtToken(cfg, token, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func newTokenId() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString() // V7 only fails if the random source does, fall back to V4
+	}
+	return id.String()
+}
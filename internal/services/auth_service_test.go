@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+)
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	s := newTestAuthService(t)
+	alice, _ := s.repo.GetById(ctx, 7)
+
+	refresh := func(token string) (*model.AuthResponse, error) {
+		return s.AccessToken(ctx, model.AccessTokenRequest{GrantType: GrantTypeRefreshToken, RefreshToken: token})
+	}
+
+	login, err := s.issueTokens(ctx, alice, "", "posts:read", model.DeviceInfo{})
+	if err != nil {
+		t.Fatalf("issueTokens() error = %v", err)
+	}
+	other, err := s.issueTokens(ctx, alice, "", "posts:read", model.DeviceInfo{})
+	if err != nil {
+		t.Fatalf("issueTokens() error = %v", err)
+	}
+
+	rotated, err := refresh(login.RefreshToken)
+	if err != nil {
+		t.Fatalf("first refresh error = %v", err)
+	}
+
+	// Replaying the spent token gives the theft away
+	if _, err := refresh(login.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replayed refresh = %v, want ErrRefreshTokenReused", err)
+	}
+
+	// The whole family goes with it, including the token the legitimate holder rotated to
+	if _, err := refresh(rotated.RefreshToken); !errors.Is(err, ErrInvalidGrant) {
+		t.Errorf("refresh with the rotated token = %v, want ErrInvalidGrant", err)
+	}
+
+	// Other logins of the same user are a different family
+	if _, err := refresh(other.RefreshToken); err != nil {
+		t.Errorf("refresh of another session = %v", err)
+	}
+}
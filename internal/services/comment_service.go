@@ -125,13 +125,20 @@ func (s *LocalCommentService) InsertComment(ctx context.Context, comment model.C
 		attribute.Int("comment.postid", comment.Postid),
 		attribute.String("comment.body", comment.Body))
 
+	authorID, err := authorFromContext(ctx, comment.Authorid)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "caller may not author a comment")
+		return err
+	}
+
 	newcomment := model.Comment{
 		Id:       s.repo.Count() + 1,
-		AuthorID: comment.Authorid,
+		AuthorID: authorID,
 		PostId:   comment.Postid,
 		Body:     comment.Body,
 	}
-	err := s.repo.InsertComment(ctx, newcomment)
+	err = s.repo.InsertComment(ctx, newcomment)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to insert comment in repository")
@@ -147,6 +154,12 @@ func (s *LocalCommentService) DeleteComment(ctx context.Context, id int) error {
 
 	span.SetAttributes(attribute.Int("comment.id", id))
 
+	if err := s.authorize(ctx, id); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "caller may not modify comment")
+		return err
+	}
+
 	err := s.repo.DeleteComment(ctx, id)
 	if err != nil {
 		span.RecordError(err)
@@ -165,6 +178,18 @@ func (s *LocalCommentService) UpdateComment(ctx context.Context, dto model.Comme
 	span.SetAttributes(attribute.Int("comment.id", dto.Id),
 		attribute.Int("comment.patch.num", len(dto.Patches)))
 
+	if err := checkPatches(dto.Patches, "body"); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid comment patch")
+		return err
+	}
+
+	if err := s.authorize(ctx, dto.Id); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "caller may not modify comment")
+		return err
+	}
+
 	err := s.repo.UpdateComment(ctx, dto)
 	if err != nil {
 		span.RecordError(err)
@@ -179,7 +204,13 @@ func (s *LocalCommentService) ReplaceComment(ctx context.Context, dto model.Comm
 	ctx, span := s.tracer.Start(ctx, "ReplaceComment.Service")
 	defer span.End()
 
-	// Span attributes as per update dto
+	span.SetAttributes(attribute.Int("comment.id", dto.Id))
+
+	if err := s.authorize(ctx, dto.Id); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "caller may not modify comment")
+		return err
+	}
 
 	err := s.repo.ReplaceComment(ctx, dto)
 	if err != nil {
@@ -191,6 +222,19 @@ func (s *LocalCommentService) ReplaceComment(ctx context.Context, dto model.Comm
 	return nil
 }
 
+// authorize loads the comment fresh from the repository so a stale cache can't grant access
+func (s *LocalCommentService) authorize(ctx context.Context, id int) error {
+	comment, err := s.repo.GetById(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoRecord) || errors.Is(err, sql.ErrNoRows) {
+			return repository.ErrNoRecord
+		}
+		return err
+	}
+
+	return authorizeAuthor(ctx, comment.AuthorID)
+}
+
 func ConvertCommentToCommentResponseDTO(comment *model.Comment) model.CommentResponseDTO {
 	return model.CommentResponseDTO{
 		Id:       comment.Id,
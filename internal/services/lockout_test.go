@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"github.com/abhinash-kml/go-api-server/pkg/ratelimiter"
+)
+
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	lockout, err := NewLoginLockout(ratelimiter.NewInMemoryLockoutStore(fake), &config.LockoutConfig{
+		Account: config.LockoutPolicyConfig{Threshold: 3, Base: "1m", Max: "10m", Window: "15m"},
+		IP:      config.LockoutPolicyConfig{Threshold: 5, Base: "1m", Max: "1h", Window: "15m"},
+	})
+	if err != nil {
+		t.Fatalf("NewLoginLockout() error = %v", err)
+	}
+
+	// Unknown usernames lock like real ones
+	for range 3 {
+		lockout.Failure(ctx, "nobody", "198.51.100.1")
+	}
+	var lockoutErr *LockoutError
+	err = lockout.Check(ctx, "nobody", "198.51.100.2")
+	if !errors.Is(err, ErrAccountLocked) || !errors.As(err, &lockoutErr) || lockoutErr.RetryAfter != time.Minute {
+		t.Fatalf("Check() on a locked account = %v, want ErrAccountLocked for a minute", err)
+	}
+	if err := lockout.Check(ctx, "alice", "198.51.100.1"); err != nil {
+		t.Errorf("Check() for another account from an IP under its threshold = %v", err)
+	}
+
+	// Two more failures from the same IP against other accounts lock the IP
+	lockout.Failure(ctx, "alice", "198.51.100.1")
+	lockout.Failure(ctx, "bob", "198.51.100.1")
+	if err := lockout.Check(ctx, "carol", "198.51.100.1"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Check() from a locked IP = %v, want ErrTooManyAttempts", err)
+	}
+	if err := lockout.Check(ctx, "carol", "198.51.100.3"); err != nil {
+		t.Errorf("Check() from another IP = %v", err)
+	}
+
+	// A success clears the account, the IP keeps its count
+	lockout.Success(ctx, "nobody")
+	if err := lockout.Check(ctx, "nobody", "198.51.100.2"); err != nil {
+		t.Errorf("Check() after a successful login = %v", err)
+	}
+	if err := lockout.Check(ctx, "nobody", "198.51.100.1"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Check() from the locked IP after someone's success = %v, want ErrTooManyAttempts", err)
+	}
+
+	// Unlock lifts an account lock early
+	for range 3 {
+		lockout.Failure(ctx, "dave", "")
+	}
+	if err := lockout.Unlock(ctx, "dave"); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if err := lockout.Check(ctx, "dave", ""); err != nil {
+		t.Errorf("Check() after Unlock = %v", err)
+	}
+
+	fake.Advance(time.Hour)
+	if err := lockout.Check(ctx, "carol", "198.51.100.1"); err != nil {
+		t.Errorf("Check() once every lock ran out = %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"github.com/abhinash-kml/go-api-server/pkg/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrMfaAlreadyEnabled = errors.New("Two-factor authentication already enabled")
+	ErrMfaNotEnrolled    = errors.New("Two-factor authentication not enrolled")
+	ErrInvalidMfaCode    = errors.New("Invalid two-factor code")
+)
+
+const recoveryCodeCount = 10
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type MfaService interface {
+	EnrollTotp(context.Context) (*model.TotpEnrollResponse, error)
+	ConfirmTotp(context.Context, string) (*model.RecoveryCodesResponse, error)
+	IsEnrolled(context.Context, int) (bool, error)
+	VerifyCode(context.Context, int, string) error
+}
+
+type LocalMfaService struct {
+	repo   repository.MfaRepository
+	users  repository.UserRepository
+	used   policy.JwtDenylist
+	config *config.MfaConfig
+	clock  clock.Clock
+	tracer oteltracer.Tracer
+}
+
+func NewLocalMfaService(repository repository.MfaRepository, users repository.UserRepository, used policy.JwtDenylist, config *config.MfaConfig, clock clock.Clock, tracer oteltracer.Tracer) *LocalMfaService {
+	return &LocalMfaService{
+		repo:   repository,
+		users:  users,
+		used:   used,
+		config: config,
+		clock:  clock,
+		tracer: tracer,
+	}
+}
+
+// EnrollTotp starts (or restarts) enrollment of the caller, it has no effect on login until confirmed
+func (s *LocalMfaService) EnrollTotp(ctx context.Context) (*model.TotpEnrollResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "EnrollTotp.Service")
+	defer span.End()
+
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok || principal.UserID == 0 {
+		span.SetStatus(codes.Error, "no user principal")
+		return nil, ErrForbidden
+	}
+
+	span.SetAttributes(attribute.Int("user.id", principal.UserID))
+
+	existing, err := s.repo.GetTotp(ctx, principal.UserID)
+	if err != nil && !errors.Is(err, repository.ErrNoRecord) {
+		span.RecordError(err)
+		return nil, err
+	}
+	if existing != nil && existing.Confirmed() {
+		span.SetStatus(codes.Error, "totp already confirmed")
+		return nil, ErrMfaAlreadyEnabled
+	}
+
+	user, err := s.users.GetById(ctx, principal.UserID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	secret, err := util.GenerateTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SaveTotp(ctx, model.TotpEnrollment{UserID: user.Id, Secret: secret}); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to save totp enrollment")
+		return nil, err
+	}
+
+	return &model.TotpEnrollResponse{
+		Secret: secret,
+		URI:    util.TotpURI(s.config.Issuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmTotp enables 2FA once the caller proves their app produces valid codes, and hands out fresh recovery codes
+func (s *LocalMfaService) ConfirmTotp(ctx context.Context, code string) (*model.RecoveryCodesResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "ConfirmTotp.Service")
+	defer span.End()
+
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok || principal.UserID == 0 {
+		span.SetStatus(codes.Error, "no user principal")
+		return nil, ErrForbidden
+	}
+
+	span.SetAttributes(attribute.Int("user.id", principal.UserID))
+
+	enrollment, err := s.repo.GetTotp(ctx, principal.UserID)
+	if errors.Is(err, repository.ErrNoRecord) {
+		span.SetStatus(codes.Error, "totp not enrolled")
+		return nil, ErrMfaNotEnrolled
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if enrollment.Confirmed() {
+		span.SetStatus(codes.Error, "totp already confirmed")
+		return nil, ErrMfaAlreadyEnabled
+	}
+
+	if err := s.verifyTotp(ctx, enrollment, code); err != nil {
+		span.SetStatus(codes.Error, "invalid totp code")
+		return nil, err
+	}
+
+	recoveryCodes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(ctx, principal.UserID, hashes); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to save recovery codes")
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	enrollment.ConfirmedAt = &now
+	if err := s.repo.SaveTotp(ctx, *enrollment); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to confirm totp enrollment")
+		return nil, err
+	}
+
+	return &model.RecoveryCodesResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+func (s *LocalMfaService) IsEnrolled(ctx context.Context, userId int) (bool, error) {
+	ctx, span := s.tracer.Start(ctx, "IsEnrolled.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	enrollment, err := s.repo.GetTotp(ctx, userId)
+	if errors.Is(err, repository.ErrNoRecord) {
+		return false, nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		return false, err
+	}
+
+	return enrollment.Confirmed(), nil
+}
+
+// VerifyCode accepts a current TOTP code or, failing the TOTP format, an unused recovery code
+func (s *LocalMfaService) VerifyCode(ctx context.Context, userId int, code string) error {
+	ctx, span := s.tracer.Start(ctx, "VerifyCode.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	enrollment, err := s.repo.GetTotp(ctx, userId)
+	if errors.Is(err, repository.ErrNoRecord) || (err == nil && !enrollment.Confirmed()) {
+		span.SetStatus(codes.Error, "totp not enrolled")
+		return ErrMfaNotEnrolled
+	}
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	code = strings.TrimSpace(code)
+	if isTotpCode(code) {
+		span.SetAttributes(attribute.String("mfa.method", "totp"))
+		return s.verifyTotp(ctx, enrollment, code)
+	}
+
+	span.SetAttributes(attribute.String("mfa.method", "recovery_code"))
+	return s.useRecoveryCode(ctx, userId, code)
+}
+
+// verifyTotp checks the code against the clock and burns its step, so an observed code can't be replayed within the skew window.
+// The burnt steps go on the denylist, its one-time claim works for any key.
+func (s *LocalMfaService) verifyTotp(ctx context.Context, enrollment *model.TotpEnrollment, code string) error {
+	step, ok := util.ValidateTotp(enrollment.Secret, code, s.clock.Now(), s.config.Skew)
+	if !ok {
+		return ErrInvalidMfaCode
+	}
+
+	ttl := time.Duration(2*s.config.Skew+1) * util.TotpPeriod
+	first, err := s.used.Deny(ctx, fmt.Sprintf("totp_used:%d:%d", enrollment.UserID, step), ttl)
+	if err != nil {
+		return err
+	}
+	if !first {
+		return ErrInvalidMfaCode
+	}
+
+	return nil
+}
+
+func (s *LocalMfaService) useRecoveryCode(ctx context.Context, userId int, code string) error {
+	code = normalizeRecoveryCode(code)
+	if code == "" {
+		return ErrInvalidMfaCode
+	}
+
+	recoveryCodes, err := s.repo.GetUnusedRecoveryCodes(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, recoveryCode := range recoveryCodes {
+		if !util.ComparePassword(recoveryCode.CodeHash, code) {
+			continue
+		}
+
+		// Marking used is conditional, a code racing itself only succeeds once
+		used, err := s.repo.UseRecoveryCode(ctx, recoveryCode.Id)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidMfaCode
+		}
+		return nil
+	}
+
+	return ErrInvalidMfaCode
+}
+
+func isTotpCode(code string) bool {
+	if len(code) != util.TotpDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Recovery codes are shown grouped as xxxxx-xxxxx but compared without separators or case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	return strings.ToLower(code)
+}
+
+// newRecoveryCodes returns the codes to show the user once and the bcrypt hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))[:10]
+
+		hash, err := util.HashPassword(code)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		plain = append(plain, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hash)
+	}
+
+	return plain, hashes, nil
+}
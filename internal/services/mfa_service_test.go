@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"github.com/abhinash-kml/go-api-server/pkg/util"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const testTotpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func newTestMfaService(t *testing.T, skew int) (*LocalMfaService, *clock.Fake) {
+	t.Helper()
+
+	tracer := noop.NewTracerProvider().Tracer("test")
+	repo := repository.NewInMemoryMfaRepository(tracer)
+	confirmedAt := time.Unix(0, 0)
+	if err := repo.SaveTotp(context.Background(), model.TotpEnrollment{UserID: 1, Secret: testTotpSecret, ConfirmedAt: &confirmedAt}); err != nil {
+		t.Fatalf("SaveTotp: %v", err)
+	}
+
+	// Mid step, so a second either way stays in it
+	fake := clock.NewFake(time.Unix(1234567890-1234567890%30+15, 0))
+	return NewLocalMfaService(repo, nil, policy.NewInMemoryJwtDenylist(fake), &config.MfaConfig{Skew: skew}, fake, tracer), fake
+}
+
+func totpCodeAt(t *testing.T, at time.Time) string {
+	t.Helper()
+
+	code, err := util.TotpCode(testTotpSecret, util.TotpStep(at))
+	if err != nil {
+		t.Fatalf("TotpCode: %v", err)
+	}
+	return code
+}
+
+func TestVerifyCodeSkewWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		skew    int
+		offset  time.Duration
+		wantErr error
+	}{
+		{name: "current step", skew: 1, offset: 0},
+		{name: "one step behind", skew: 1, offset: -util.TotpPeriod},
+		{name: "one step ahead", skew: 1, offset: util.TotpPeriod},
+		{name: "two steps behind", skew: 1, offset: -2 * util.TotpPeriod, wantErr: ErrInvalidMfaCode},
+		{name: "two steps ahead", skew: 1, offset: 2 * util.TotpPeriod, wantErr: ErrInvalidMfaCode},
+		{name: "one step behind without skew", skew: 0, offset: -util.TotpPeriod, wantErr: ErrInvalidMfaCode},
+		{name: "two steps behind with skew two", skew: 2, offset: -2 * util.TotpPeriod},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mfa, fake := newTestMfaService(t, tt.skew)
+			code := totpCodeAt(t, fake.Now().Add(tt.offset))
+
+			if err := mfa.VerifyCode(context.Background(), 1, code); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyCode() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyCodeRefusesReplay(t *testing.T) {
+	mfa, fake := newTestMfaService(t, 1)
+	ctx := context.Background()
+	code := totpCodeAt(t, fake.Now())
+
+	if err := mfa.VerifyCode(ctx, 1, code); err != nil {
+		t.Fatalf("first VerifyCode() = %v, want nil", err)
+	}
+	if err := mfa.VerifyCode(ctx, 1, code); !errors.Is(err, ErrInvalidMfaCode) {
+		t.Errorf("replayed VerifyCode() = %v, want %v", err, ErrInvalidMfaCode)
+	}
+
+	// Still inside the skew window a step later, the burnt step stays burnt
+	fake.Advance(util.TotpPeriod)
+	if err := mfa.VerifyCode(ctx, 1, code); !errors.Is(err, ErrInvalidMfaCode) {
+		t.Errorf("replayed VerifyCode() a step later = %v, want %v", err, ErrInvalidMfaCode)
+	}
+
+	// The code of the new step is a different step and goes through once
+	next := totpCodeAt(t, fake.Now())
+	if err := mfa.VerifyCode(ctx, 1, next); err != nil {
+		t.Errorf("VerifyCode() of the next step = %v, want nil", err)
+	}
+	if err := mfa.VerifyCode(ctx, 1, next); !errors.Is(err, ErrInvalidMfaCode) {
+		t.Errorf("replayed VerifyCode() of the next step = %v, want %v", err, ErrInvalidMfaCode)
+	}
+}
+
+func TestVerifyCodeNotEnrolled(t *testing.T) {
+	mfa, fake := newTestMfaService(t, 1)
+
+	if err := mfa.VerifyCode(context.Background(), 2, totpCodeAt(t, fake.Now())); !errors.Is(err, ErrMfaNotEnrolled) {
+		t.Errorf("VerifyCode() = %v, want %v", err, ErrMfaNotEnrolled)
+	}
+}
@@ -0,0 +1,409 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
+	"github.com/abhinash-kml/go-api-server/pkg/util"
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	ErrInvalidRedirectURI = errors.New("Invalid redirect URI")
+	ErrMfaRequired        = errors.New("Two-factor code required")
+)
+
+const PkceMethodS256 = "S256"
+
+// AuthorizeError is reported back to the client on its redirect_uri (RFC 6749 section 4.1.2.1).
+// Errors before the client and redirect_uri are known good are never redirected.
+type AuthorizeError struct {
+	Code        string
+	Description string
+}
+
+func (e *AuthorizeError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func (e *AuthorizeError) RedirectURL(redirectURI, state string) string {
+	params := url.Values{"error": {e.Code}, "error_description": {e.Description}}
+	if state != "" {
+		params.Set("state", state)
+	}
+	return withQuery(redirectURI, params)
+}
+
+// OidcService makes the server an OpenID Connect provider, the code exchange itself is a grant of AuthService.AccessToken
+type OidcService interface {
+	ValidateAuthorization(context.Context, model.AuthorizationRequest) (*model.Client, error)
+	// Authorize logs the user in and returns where to send the browser, with the code or the denial
+	Authorize(context.Context, model.AuthorizationConsent) (string, error)
+	UserInfo(context.Context) (*model.UserInfoResponse, error)
+	Discovery() model.OpenIDConfiguration
+}
+
+func (s *LocalAuthService) ValidateAuthorization(ctx context.Context, req model.AuthorizationRequest) (*model.Client, error) {
+	ctx, span := s.tracer.Start(ctx, "ValidateAuthorization.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("client.id", req.ClientID))
+
+	client, err := s.clients.GetById(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoRecord) {
+			span.SetStatus(codes.Error, "unknown client")
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+
+	// Exact match only, prefix or pattern matching is how codes end up on attacker pages
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		span.SetStatus(codes.Error, "redirect uri not registered")
+		return nil, ErrInvalidRedirectURI
+	}
+
+	if req.ResponseType != "code" {
+		return nil, &AuthorizeError{"unsupported_response_type", "Only response_type=code is supported"}
+	}
+
+	// PKCE is required of every client, confidential ones included
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != PkceMethodS256 {
+		return nil, &AuthorizeError{"invalid_request", "code_challenge with code_challenge_method=S256 is required"}
+	}
+
+	for _, scope := range strings.Fields(req.Scope) {
+		if !policy.IsOidcScope(scope) && !policy.HasScopes(client.Scopes, scope) {
+			span.SetStatus(codes.Error, "scope not registered for client")
+			return nil, &AuthorizeError{"invalid_scope", "Scope " + scope + " is not allowed for this client"}
+		}
+	}
+
+	return client, nil
+}
+
+func (s *LocalAuthService) Authorize(ctx context.Context, consent model.AuthorizationConsent) (string, error) {
+	ctx, span := s.tracer.Start(ctx, "Authorize.Service")
+	defer span.End()
+
+	client, err := s.ValidateAuthorization(ctx, consent.AuthorizationRequest)
+	if err != nil {
+		return "", err
+	}
+
+	if !consent.Approved {
+		span.SetStatus(codes.Error, "consent denied")
+		return "", &AuthorizeError{"access_denied", "The user denied the request"}
+	}
+
+	span.SetAttributes(attribute.String("user.username", consent.Username))
+
+	user, err := s.authenticatePassword(ctx, consent.Username, consent.Password, consent.Device.IP)
+	if err != nil {
+		span.SetStatus(codes.Error, "password authentication failed")
+		return "", err
+	}
+
+	enrolled, err := s.mfa.IsEnrolled(ctx, user.Id)
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+	if enrolled {
+		if consent.MfaCode == "" {
+			return "", ErrMfaRequired
+		}
+		if err := s.mfa.VerifyCode(ctx, user.Id, consent.MfaCode); err != nil {
+			if errors.Is(err, ErrInvalidMfaCode) {
+				s.lockout.Failure(ctx, user.Username, consent.Device.IP)
+			}
+			span.SetStatus(codes.Error, "mfa verification failed")
+			return "", err
+		}
+	}
+
+	s.lockout.Success(ctx, user.Username)
+
+	scope, err := s.clientScope(user, client, consent.Scope)
+	if err != nil {
+		span.SetStatus(codes.Error, "scope not allowed for role")
+		return "", &AuthorizeError{"invalid_scope", "The requested scope exceeds what the user may grant"}
+	}
+
+	codeTTL, err := time.ParseDuration(s.config.Oidc.CodeExpiration)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := newAuthorizationCode()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.authCodes.SaveCode(ctx, model.AuthorizationCode{
+		Code:          code,
+		ClientID:      client.Id,
+		RedirectURI:   consent.RedirectURI,
+		UserID:        user.Id,
+		Scope:         scope,
+		Nonce:         consent.Nonce,
+		CodeChallenge: consent.CodeChallenge,
+		AuthTime:      time.Now(),
+	}, codeTTL)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to save authorization code")
+		return "", err
+	}
+
+	span.SetAttributes(attribute.Int("user.id", user.Id))
+
+	params := url.Values{"code": {code}}
+	if consent.State != "" {
+		params.Set("state", consent.State)
+	}
+	return withQuery(consent.RedirectURI, params), nil
+}
+
+// authorizationCode exchanges a code from Authorize for tokens, plus an ID token when openid was granted
+func (s *LocalAuthService) authorizationCode(ctx context.Context, dto model.AccessTokenRequest) (*model.AuthResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthorizationCode.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("client.id", dto.ClientId))
+
+	client, err := s.clients.GetById(ctx, dto.ClientId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoRecord) {
+			span.SetStatus(codes.Error, "unknown client")
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+
+	if !client.IsPublic() && !util.ComparePassword(client.SecretHash, dto.ClientSecret) {
+		span.SetStatus(codes.Error, "client secret mismatch")
+		return nil, ErrInvalidClient
+	}
+
+	code, err := s.authCodes.ConsumeCode(ctx, dto.Code)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoRecord) {
+			span.SetStatus(codes.Error, "unknown or used code")
+			return nil, ErrInvalidGrant
+		}
+		return nil, err
+	}
+
+	if code.ClientID != client.Id || code.RedirectURI != dto.RedirectURI {
+		span.SetStatus(codes.Error, "code issued to another client or redirect uri")
+		return nil, ErrInvalidGrant
+	}
+
+	if !verifyPkce(dto.CodeVerifier, code.CodeChallenge) {
+		span.SetStatus(codes.Error, "pkce verification failed")
+		return nil, ErrInvalidGrant
+	}
+
+	user, err := s.repo.GetById(ctx, code.UserID)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, repository.ErrNoRecord) || errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidGrant
+		}
+		return nil, err
+	}
+
+	// The client's registration may have narrowed since the code was issued
+	scope, err := s.clientScope(user, client, code.Scope)
+	if err != nil {
+		span.SetStatus(codes.Error, "scope no longer allowed for role")
+		return nil, err
+	}
+
+	response, err := s.issueTokens(ctx, user, "", scope, dto.Device)
+	if err != nil {
+		return nil, err
+	}
+
+	if slices.Contains(strings.Fields(scope), policy.ScopeOpenID) {
+		if response.IDToken, err = s.idToken(user, client.Id, code, scope); err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// clientScope is what an authorization grants, the client's registered scopes when none were asked for.
+// It never exceeds what the client is registered for nor what the user's role may grant.
+func (s *LocalAuthService) clientScope(user *model.User, client *model.Client, requested string) (string, error) {
+	if len(strings.Fields(requested)) == 0 {
+		granted := []string{}
+		for _, scope := range client.Scopes {
+			if _, err := s.userScope(user, scope); err == nil {
+				granted = append(granted, scope)
+			}
+		}
+		return strings.Join(granted, " "), nil
+	}
+
+	scope, err := s.userScope(user, requested)
+	if err != nil {
+		return "", err
+	}
+
+	granted := []string{}
+	for _, scope := range strings.Fields(scope) {
+		if policy.IsOidcScope(scope) || policy.HasScopes(client.Scopes, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return strings.Join(granted, " "), nil
+}
+
+// idToken is addressed to the client alone, its claims follow the granted scopes
+func (s *LocalAuthService) idToken(user *model.User, clientId string, code *model.AuthorizationCode, scope string) (string, error) {
+	ttl, err := time.ParseDuration(s.config.Oidc.IDTokenExpiration)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &model.IDTokenClaims{
+		Nonce:           code.Nonce,
+		AuthTime:        jwt.NewNumericDate(code.AuthTime),
+		AuthorizedParty: clientId,
+		ProfileClaims:   profileClaims(user, strings.Fields(scope)),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.config.Oidc.Issuer,
+			Subject:   strconv.Itoa(user.Id),
+			Audience:  jwt.ClaimStrings{clientId},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        newTokenId(),
+		},
+	}
+
+	return util.CreateAsymmetricJwtToken(claims)
+}
+
+func (s *LocalAuthService) UserInfo(ctx context.Context) (*model.UserInfoResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "UserInfo.Service")
+	defer span.End()
+
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok || principal.UserID == 0 {
+		span.SetStatus(codes.Error, "no user principal")
+		return nil, ErrForbidden
+	}
+
+	span.SetAttributes(attribute.Int("user.id", principal.UserID))
+
+	user, err := s.repo.GetById(ctx, principal.UserID)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return &model.UserInfoResponse{
+		Subject:       principal.Subject,
+		ProfileClaims: profileClaims(user, principal.Scopes),
+	}, nil
+}
+
+func (s *LocalAuthService) Discovery() model.OpenIDConfiguration {
+	issuer := strings.TrimSuffix(s.config.Oidc.Issuer, "/")
+
+	return model.OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/authorize",
+		TokenEndpoint:                     issuer + "/access_token",
+		UserinfoEndpoint:                  issuer + "/userinfo",
+		JwksURI:                           issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken, GrantTypeClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{util.JwtSigningAlg()},
+		ScopesSupported:                   policy.OidcScopes,
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "azp", "name", "preferred_username", "email", "email_verified", "address"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{PkceMethodS256},
+	}
+}
+
+func profileClaims(user *model.User, scopes []string) model.ProfileClaims {
+	claims := model.ProfileClaims{}
+
+	if policy.HasScopes(scopes, "profile") {
+		claims.Name = user.Name
+		claims.PreferredUsername = user.Username
+	}
+	if policy.HasScopes(scopes, "email") && user.Email != "" {
+		claims.Email = user.Email
+		claims.EmailVerified = &user.EmailVerified
+	}
+	if policy.HasScopes(scopes, "address") {
+		claims.Address = &model.AddressClaim{
+			Locality: user.City,
+			Region:   user.State,
+			Country:  user.Country,
+		}
+	}
+
+	return claims
+}
+
+// verifyPkce checks the verifier against an S256 challenge (RFC 7636 section 4.6)
+func verifyPkce(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func newAuthorizationCode() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// withQuery adds params to a registered redirect_uri, keeping any query it already has
+func withQuery(base string, params url.Values) string {
+	target, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	target.RawQuery = query.Encode()
+	return target.String()
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// The challenge is BASE64URL(SHA256(verifier)), worked out with openssl dgst -sha256 -binary | basenc --base64url
+const (
+	testCodeVerifier  = "dBjftJeZ4CVP-mJ92IZ1TMRbWUqR4Y4pr9g4uXg7rXQ"
+	testCodeChallenge = "FLw41dVzhpdOx51VdmQGKEiy75tNZQsRSLRLoaw4qus"
+)
+
+func TestVerifyPkce(t *testing.T) {
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{"S256", testCodeVerifier, testCodeChallenge, true},
+		{"plain challenge", testCodeVerifier, testCodeVerifier, false},
+		{"other verifier", strings.Repeat("a", 43), testCodeChallenge, false},
+		{"verifier too short", testCodeVerifier[:42], testCodeChallenge, false},
+		{"verifier too long", strings.Repeat("a", 129), testCodeChallenge, false},
+		{"no verifier", "", testCodeChallenge, false},
+	}
+
+	for _, tt := range tests {
+		if got := verifyPkce(tt.verifier, tt.challenge); got != tt.want {
+			t.Errorf("%s: verifyPkce() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func newTestOidcService() *LocalAuthService {
+	tracer := noop.NewTracerProvider().Tracer("test")
+	return &LocalAuthService{
+		clients: repository.NewConfigClientRepository([]config.ClientConfig{
+			{Id: "dashboard", RedirectURIs: []string{"https://app.example.com/callback"}, Scopes: []string{"posts:read"}},
+		}, tracer),
+		authCodes: repository.NewInMemoryAuthCodeRepository(tracer),
+		tracer:    tracer,
+	}
+}
+
+func TestValidateAuthorizationRequiresS256(t *testing.T) {
+	s := newTestOidcService()
+	request := model.AuthorizationRequest{
+		ResponseType: "code",
+		ClientID:     "dashboard",
+		RedirectURI:  "https://app.example.com/callback",
+		Scope:        "openid posts:read",
+	}
+
+	tests := []struct {
+		name      string
+		challenge string
+		method    string
+		wantErr   bool
+	}{
+		{"S256", testCodeChallenge, PkceMethodS256, false},
+		{"plain", testCodeVerifier, "plain", true},
+		{"no method", testCodeChallenge, "", true},
+		{"no challenge", "", PkceMethodS256, true},
+	}
+
+	for _, tt := range tests {
+		request.CodeChallenge, request.CodeChallengeMethod = tt.challenge, tt.method
+		_, err := s.ValidateAuthorization(context.Background(), request)
+		var authorizeErr *AuthorizeError
+		if tt.wantErr != errors.As(err, &authorizeErr) || (!tt.wantErr && err != nil) {
+			t.Errorf("%s: ValidateAuthorization() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAuthorizationCodeRejectsPkceMismatch(t *testing.T) {
+	s := newTestOidcService()
+	ctx := context.Background()
+
+	err := s.authCodes.SaveCode(ctx, model.AuthorizationCode{
+		Code:          "code",
+		ClientID:      "dashboard",
+		RedirectURI:   "https://app.example.com/callback",
+		UserID:        7,
+		CodeChallenge: testCodeChallenge,
+	}, time.Minute)
+	if err != nil {
+		t.Fatalf("SaveCode() error = %v", err)
+	}
+
+	exchange := model.AccessTokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		ClientId:     "dashboard",
+		Code:         "code",
+		RedirectURI:  "https://app.example.com/callback",
+		CodeVerifier: testCodeChallenge, // The challenge itself, as a plain client would send
+	}
+	if _, err := s.authorizationCode(ctx, exchange); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("authorizationCode() with a mismatched verifier = %v, want ErrInvalidGrant", err)
+	}
+
+	// The failed attempt used the code up, guessing again gets nowhere
+	exchange.CodeVerifier = testCodeVerifier
+	if _, err := s.authorizationCode(ctx, exchange); !errors.Is(err, ErrInvalidGrant) {
+		t.Errorf("authorizationCode() after a failed attempt = %v, want ErrInvalidGrant", err)
+	}
+}
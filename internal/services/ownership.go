@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+)
+
+var (
+	ErrForbidden    = errors.New("Forbidden")
+	ErrNotOwner     = fmt.Errorf("%w: not the author", ErrForbidden)
+	ErrInvalidPatch = errors.New("Invalid patch")
+)
+
+// PatchError names a patch the resource does not accept, it matches ErrInvalidPatch with errors.Is
+type PatchError struct {
+	Field   string
+	Allowed []string
+}
+
+func (e *PatchError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: nothing to patch", ErrInvalidPatch)
+	}
+	return fmt.Sprintf("%s: field %q is not one of %v", ErrInvalidPatch, e.Field, e.Allowed)
+}
+
+func (e *PatchError) Unwrap() error {
+	return ErrInvalidPatch
+}
+
+// checkPatches only lets string values for the allowed fields through, the repositories build their updates from them
+func checkPatches(patches []model.Patch, allowed ...string) error {
+	if len(patches) == 0 {
+		return &PatchError{Allowed: allowed}
+	}
+
+	for _, patch := range patches {
+		if _, ok := patch.Value.(string); !ok || !slices.Contains(allowed, patch.Field) {
+			return &PatchError{Field: patch.Field, Allowed: allowed}
+		}
+	}
+	return nil
+}
+
+// authorizeOwner lets the owner or an admin through. Callers without a principal are denied,
+// non-HTTP callers have to attach one with policy.WithPrincipal.
+func authorizeOwner(ctx context.Context, ownerID int) error {
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok {
+		return ErrForbidden
+	}
+
+	if principal.IsAdmin() || (principal.UserID != 0 && principal.UserID == ownerID) {
+		return nil
+	}
+
+	return ErrForbidden
+}
+
+// authorizeAuthor is authorizeOwner for posts and comments, denials say the caller isn't the author
+func authorizeAuthor(ctx context.Context, authorID int) error {
+	if err := authorizeOwner(ctx, authorID); err != nil {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+// authorFromContext pins new records to the calling user, only admins may create on someone else's behalf.
+// Clients and API keys act for no user, so they can't author anything.
+func authorFromContext(ctx context.Context, requested int) (int, error) {
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok {
+		return 0, ErrForbidden
+	}
+	if principal.IsAdmin() {
+		return requested, nil
+	}
+	if principal.Kind != policy.PrincipalUser || principal.UserID == 0 {
+		return 0, ErrForbidden
+	}
+	return principal.UserID, nil
+}
+
+// authorizeAdmin returns the caller when they are an admin
+func authorizeAdmin(ctx context.Context) (*policy.Principal, error) {
+	principal, ok := policy.PrincipalFromContext(ctx)
+	if !ok || !principal.IsAdmin() {
+		return nil, ErrForbidden
+	}
+	return principal, nil
+}
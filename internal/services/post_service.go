@@ -126,14 +126,21 @@ func (s *LocalPostsService) InsertPost(ctx context.Context, post model.PostCreat
 		attribute.String("post.title", post.Title),
 		attribute.String("post.body", post.Body))
 
+	authorID, err := authorFromContext(ctx, post.AuthorID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "caller may not author a post")
+		return err
+	}
+
 	newpost := model.Post{
 		Id:       s.repo.Count() + 1,
 		Title:    post.Title,
 		Body:     post.Body,
-		AuthorID: post.AuthorID,
+		AuthorID: authorID,
 		Likes:    0,
 	}
-	err := s.repo.InsertPost(ctx, newpost)
+	err = s.repo.InsertPost(ctx, newpost)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to insert new post in repository")
@@ -151,6 +158,18 @@ func (s *LocalPostsService) UpdatePost(ctx context.Context, dto model.PostUpdate
 	span.SetAttributes(attribute.Int("post.id", dto.Id),
 		attribute.Int("post.patch.num", len(dto.Patches)))
 
+	if err := checkPatches(dto.Patches, "title", "body"); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid post patch")
+		return err
+	}
+
+	if err := s.authorize(ctx, dto.Id); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "caller may not modify post")
+		return err
+	}
+
 	err := s.repo.UpdatePost(ctx, dto)
 	if err != nil {
 		span.RecordError(err)
@@ -165,6 +184,14 @@ func (s *LocalPostsService) ReplacePost(ctx context.Context, dto model.PostRepla
 	ctx, span := s.tracer.Start(ctx, "ReplacePost.Service")
 	defer span.End()
 
+	span.SetAttributes(attribute.Int("post.id", dto.Id))
+
+	if err := s.authorize(ctx, dto.Id); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "caller may not modify post")
+		return err
+	}
+
 	err := s.repo.ReplacePost(ctx, dto)
 	if err != nil {
 		span.RecordError(err)
@@ -181,6 +208,12 @@ func (s *LocalPostsService) DeletePost(ctx context.Context, id int) error {
 
 	span.SetAttributes(attribute.Int("post.id", id))
 
+	if err := s.authorize(ctx, id); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "caller may not modify post")
+		return err
+	}
+
 	err := s.repo.DeletePost(ctx, id)
 	if err != nil {
 		span.RecordError(err)
@@ -191,6 +224,19 @@ func (s *LocalPostsService) DeletePost(ctx context.Context, id int) error {
 	return nil
 }
 
+// authorize loads the post fresh from the repository so a stale cache can't grant access
+func (s *LocalPostsService) authorize(ctx context.Context, id int) error {
+	post, err := s.repo.GetById(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoRecord) || errors.Is(err, sql.ErrNoRows) {
+			return repository.ErrNoRecord
+		}
+		return err
+	}
+
+	return authorizeAuthor(ctx, post.AuthorID)
+}
+
 func ConvertPostToPostResponseDTO(post *model.Post) model.PostResponseDTO {
 	return model.PostResponseDTO{
 		Id:       post.Id,
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/abhinash-kml/go-api-server/internal/connections"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestPostOwnership(t *testing.T) {
+	tracer := noop.NewTracerProvider().Tracer("test")
+	s := NewLocalPostsService(repository.NewInMemoryPostsRepository(tracer), &connections.RedisConnection{}, tracer)
+
+	author := policy.WithPrincipal(context.Background(), &policy.Principal{Kind: policy.PrincipalUser, Subject: "7", UserID: 7, Role: policy.RoleUser})
+	other := policy.WithPrincipal(context.Background(), &policy.Principal{Kind: policy.PrincipalUser, Subject: "8", UserID: 8, Role: policy.RoleUser})
+	admin := policy.WithPrincipal(context.Background(), &policy.Principal{Kind: policy.PrincipalUser, Subject: "1", UserID: 1, Role: policy.RoleAdmin})
+	client := policy.WithPrincipal(context.Background(), &policy.Principal{Kind: policy.PrincipalClient, Subject: "reporting"})
+
+	// The requested author is ignored for everyone but admins
+	if err := s.InsertPost(author, model.PostCreateDTO{Title: "t", Body: "b", AuthorID: 8}); err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+	if post, _ := s.repo.GetById(author, 1); post == nil || post.AuthorID != 7 {
+		t.Fatalf("inserted post = %+v, want it authored by the caller", post)
+	}
+	if err := s.InsertPost(client, model.PostCreateDTO{Title: "t", Body: "b"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("InsertPost() by a client = %v, want ErrForbidden", err)
+	}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		patches []model.Patch
+		want    error
+	}{
+		{"author edits the body", author, []model.Patch{{Field: "body", Value: "new"}}, nil},
+		{"admin edits the title", admin, []model.Patch{{Field: "title", Value: "new"}}, nil},
+		{"someone else", other, []model.Patch{{Field: "body", Value: "mine"}}, ErrNotOwner},
+		{"client", client, []model.Patch{{Field: "body", Value: "mine"}}, ErrNotOwner},
+		{"giving the post away", author, []model.Patch{{Field: "author_id", Value: 8}}, ErrInvalidPatch},
+		{"moving it over another row", author, []model.Patch{{Field: "id", Value: "2"}}, ErrInvalidPatch},
+		{"wrong value type", author, []model.Patch{{Field: "title", Value: 1}}, ErrInvalidPatch},
+		{"nothing to patch", author, nil, ErrInvalidPatch},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := s.UpdatePost(test.ctx, model.PostUpdateDTO{Id: 1, Patches: test.patches})
+			if !errors.Is(err, test.want) || (test.want == nil && err != nil) {
+				t.Errorf("UpdatePost() = %v, want %v", err, test.want)
+			}
+		})
+	}
+
+	if post, _ := s.repo.GetById(author, 1); post.AuthorID != 7 || post.Title != "new" || post.Body != "new" {
+		t.Errorf("post after patching = %+v, want the author's and the admin's edits only", post)
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltracer "go.opentelemetry.io/otel/trace"
+)
+
+// SessionDisconnector drops live connections of a revoked session, implemented by realtime.Hub
+type SessionDisconnector interface {
+	DisconnectSession(sid string)
+}
+
+type SessionService interface {
+	GetSessionsOfUser(context.Context, int) ([]model.Session, error)
+	RevokeSession(context.Context, int, string) error
+}
+
+type LocalSessionService struct {
+	repo         repository.SessionRepository
+	tokens       policy.TokenStore
+	denylist     policy.JwtDenylist
+	config       *config.AuthTokenConfig
+	disconnector SessionDisconnector
+	tracer       oteltracer.Tracer
+}
+
+func NewLocalSessionService(repository repository.SessionRepository, tokens policy.TokenStore, denylist policy.JwtDenylist, config *config.AuthTokenConfig, disconnector SessionDisconnector, tracer oteltracer.Tracer) *LocalSessionService {
+	return &LocalSessionService{
+		repo:         repository,
+		tokens:       tokens,
+		denylist:     denylist,
+		config:       config,
+		disconnector: disconnector,
+		tracer:       tracer,
+	}
+}
+
+// GetSessionsOfUser is limited to the user themself or an admin
+func (s *LocalSessionService) GetSessionsOfUser(ctx context.Context, userId int) ([]model.Session, error) {
+	ctx, span := s.tracer.Start(ctx, "GetSessionsOfUser.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId))
+
+	if err := authorizeOwner(ctx, userId); err != nil {
+		span.SetStatus(codes.Error, "caller may not list sessions")
+		return nil, err
+	}
+
+	sessions, err := s.repo.GetSessionsOfUser(ctx, userId)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch sessions from repository")
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession burns every refresh token of the session's family and disconnects its live realtime clients
+func (s *LocalSessionService) RevokeSession(ctx context.Context, userId int, sid string) error {
+	ctx, span := s.tracer.Start(ctx, "RevokeSession.Service")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", userId),
+		attribute.String("session.id", sid))
+
+	if err := authorizeOwner(ctx, userId); err != nil {
+		span.SetStatus(codes.Error, "caller may not revoke sessions")
+		return err
+	}
+
+	session, err := s.repo.GetById(ctx, sid)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to fetch session")
+		return err
+	}
+
+	// Don't reveal that the session exists under another user
+	if session.UserID != userId {
+		span.SetStatus(codes.Error, "session belongs to another user")
+		return repository.ErrNoRecord
+	}
+
+	refreshTokenDuration, err := time.ParseDuration(s.config.RefreshToken.Expiration)
+	if err != nil {
+		return err
+	}
+
+	if err := policy.RevokeTokenFamily(ctx, sid, refreshTokenDuration, s.tokens, s.denylist); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to revoke token family")
+		return err
+	}
+
+	if err := s.repo.DeleteSession(ctx, sid); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to delete session")
+		return err
+	}
+
+	if s.disconnector != nil {
+		s.disconnector.DisconnectSession(sid)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
+	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+var testAuthConfig = &config.AuthTokenConfig{
+	AccessToken:  config.TokenConfig{Secret: "access-secret", Expiration: "15m", Issuer: "api", Audience: "api"},
+	RefreshToken: config.TokenConfig{Secret: "refresh-secret", Expiration: "24h", Issuer: "api", Audience: "api-refresh"},
+}
+
+// newTestAuthService issues and refreshes tokens for user 7 (a user) and 8 (an admin) on in-memory stores
+func newTestAuthService(t *testing.T) *LocalAuthService {
+	t.Helper()
+
+	tracer := noop.NewTracerProvider().Tracer("test")
+	users := repository.NewInMemoryUsersRepository(tracer)
+	for _, user := range []model.User{{Id: 7, Username: "alice", Role: policy.RoleUser}, {Id: 8, Username: "root", Role: policy.RoleAdmin}} {
+		if err := users.InsertUser(context.Background(), user); err != nil {
+			t.Fatalf("InsertUser() error = %v", err)
+		}
+	}
+
+	return &LocalAuthService{
+		repo:     users,
+		sessions: repository.NewInMemorySessionRepository(tracer),
+		tokens:   policy.NewInMemoryTokenStore(clock.System{}),
+		denylist: policy.NewInMemoryJwtDenylist(clock.System{}),
+		rbac:     policy.NewRBAC(&config.RbacConfig{Roles: map[string][]string{policy.RoleUser: {"posts:*"}, policy.RoleAdmin: {"*"}}}),
+		config:   testAuthConfig,
+		tracer:   tracer,
+	}
+}
+
+// recordingDisconnector stands in for the realtime hub
+type recordingDisconnector struct {
+	disconnected []string
+}
+
+func (d *recordingDisconnector) DisconnectSession(sid string) {
+	d.disconnected = append(d.disconnected, sid)
+}
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuthService(t)
+	hub := &recordingDisconnector{}
+	sessions := NewLocalSessionService(auth.sessions, auth.tokens, auth.denylist, testAuthConfig, hub, auth.tracer)
+
+	alice, _ := auth.repo.GetById(ctx, 7)
+	laptop, err := auth.issueTokens(ctx, alice, "", "posts:read", model.DeviceInfo{UserAgent: "laptop", IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("issueTokens() error = %v", err)
+	}
+	if _, err := auth.issueTokens(ctx, alice, "", "posts:read", model.DeviceInfo{UserAgent: "phone", IP: "192.0.2.2"}); err != nil {
+		t.Fatalf("issueTokens() error = %v", err)
+	}
+
+	// A refresh keeps the session and updates where it was last used
+	if _, err := auth.AccessToken(ctx, model.AccessTokenRequest{GrantType: GrantTypeRefreshToken, RefreshToken: laptop.RefreshToken, Device: model.DeviceInfo{UserAgent: "laptop", IP: "192.0.2.9"}}); err != nil {
+		t.Fatalf("refreshing error = %v", err)
+	}
+
+	asAlice := policy.WithPrincipal(ctx, &policy.Principal{Kind: policy.PrincipalUser, Subject: "7", UserID: 7, Role: policy.RoleUser})
+	asAdmin := policy.WithPrincipal(ctx, &policy.Principal{Kind: policy.PrincipalUser, Subject: "8", UserID: 8, Role: policy.RoleAdmin})
+	asMallory := policy.WithPrincipal(ctx, &policy.Principal{Kind: policy.PrincipalUser, Subject: "9", UserID: 9, Role: policy.RoleUser})
+
+	list, err := sessions.GetSessionsOfUser(asAlice, 7)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("GetSessionsOfUser() = %d sessions, %v, want 2", len(list), err)
+	}
+	var laptopSession model.Session
+	for _, session := range list {
+		if session.UserAgent == "laptop" {
+			laptopSession = session
+		}
+	}
+	if laptopSession.IP != "192.0.2.9" || laptopSession.LastUsedAt.Before(laptopSession.CreatedAt) {
+		t.Errorf("refreshed session = %+v, want the refresh's IP and a later last use", laptopSession)
+	}
+
+	if _, err := sessions.GetSessionsOfUser(asMallory, 7); !errors.Is(err, ErrForbidden) || errors.Is(err, ErrNotOwner) {
+		t.Errorf("GetSessionsOfUser() by another user = %v, want ErrForbidden", err)
+	}
+	if list, err := sessions.GetSessionsOfUser(asAdmin, 7); err != nil || len(list) != 2 {
+		t.Errorf("GetSessionsOfUser() by an admin = %d sessions, %v, want 2", len(list), err)
+	}
+
+	// Someone else's session id under your own user reads as missing
+	if err := sessions.RevokeSession(policy.WithPrincipal(ctx, &policy.Principal{Kind: policy.PrincipalUser, Subject: "8", UserID: 8, Role: policy.RoleUser}), 8, laptopSession.Id); !errors.Is(err, repository.ErrNoRecord) {
+		t.Errorf("RevokeSession() of a session under another user = %v, want ErrNoRecord", err)
+	}
+
+	if err := sessions.RevokeSession(asAlice, 7, laptopSession.Id); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+	if len(hub.disconnected) != 1 || hub.disconnected[0] != laptopSession.Id {
+		t.Errorf("disconnected %v, want the revoked session", hub.disconnected)
+	}
+	if list, _ := sessions.GetSessionsOfUser(asAlice, 7); len(list) != 1 || list[0].UserAgent != "phone" {
+		t.Errorf("sessions after revoking = %+v, want only the phone", list)
+	}
+	if !policy.IsTokenFamilyRevoked(ctx, laptopSession.Id, auth.tokens) {
+		t.Error("the revoked session's access tokens would keep working")
+	}
+}
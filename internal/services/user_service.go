@@ -8,7 +8,9 @@ import (
 
 	"github.com/abhinash-kml/go-api-server/internal/connections"
 	model "github.com/abhinash-kml/go-api-server/internal/models"
+	"github.com/abhinash-kml/go-api-server/internal/policy"
 	repository "github.com/abhinash-kml/go-api-server/internal/repositories"
+	"github.com/abhinash-kml/go-api-server/pkg/util"
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -18,7 +20,8 @@ import (
 )
 
 var (
-	ErrOpFailed = errors.New("Operation failed")
+	ErrOpFailed      = errors.New("Operation failed")
+	ErrUsernameTaken = errors.New("Username already taken")
 )
 
 type UserService interface {
@@ -31,16 +34,18 @@ type UserService interface {
 }
 
 type LocalUserService struct {
-	repo   repository.UserRepository
-	cache  *redis.Client
-	tracer oteltracer.Tracer
+	repo     repository.UserRepository
+	accounts AccountService
+	cache    *redis.Client
+	tracer   oteltracer.Tracer
 }
 
-func NewLocalUserService(repository repository.UserRepository, conn *connections.RedisConnection, tracer oteltracer.Tracer) *LocalUserService {
+func NewLocalUserService(repository repository.UserRepository, accounts AccountService, conn *connections.RedisConnection, tracer oteltracer.Tracer) *LocalUserService {
 	return &LocalUserService{
-		repo:   repository,
-		cache:  conn.Client,
-		tracer: tracer,
+		repo:     repository,
+		accounts: accounts,
+		cache:    conn.Client,
+		tracer:   tracer,
 	}
 }
 
@@ -65,7 +70,7 @@ func (s *LocalUserService) GetUsers(ctx context.Context) ([]model.UserResponseDT
 		dtos[index] = ConvertUserToUserReponseDTO(&value)
 	}
 
-	return users, nil
+	return dtos, nil
 }
 
 func (s *LocalUserService) GetById(ctx context.Context, id int) (*model.UserResponseDTO, error) {
@@ -99,22 +104,68 @@ func (s *LocalUserService) InsertUser(ctx context.Context, user model.UserCreate
 	span.SetAttributes(attribute.String("user.name", user.Name),
 		attribute.String("user.city", user.City),
 		attribute.String("user.state", user.State),
-		attribute.String("user.country", user.Country))
+		attribute.String("user.country", user.Country),
+		attribute.String("user.username", user.Username))
+
+	_, err := s.repo.GetByUsername(ctx, user.Username)
+	if err == nil {
+		span.SetStatus(codes.Error, "username already taken")
+		return ErrUsernameTaken
+	}
+	if !errors.Is(err, repository.ErrNoRecord) && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error checking username in repository")
+		return err
+	}
+
+	_, err = s.repo.GetByEmail(ctx, user.Email)
+	if err == nil {
+		span.SetStatus(codes.Error, "email already registered")
+		return ErrEmailTaken
+	}
+	if !errors.Is(err, repository.ErrNoRecord) && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error checking email in repository")
+		return err
+	}
+
+	passwordHash, err := util.HashPassword(user.Password)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error hashing password")
+		return err
+	}
 
 	newuser := model.User{
-		Id:      s.repo.Count() + 1,
-		Name:    user.Name,
-		City:    user.City,
-		State:   user.State,
-		Country: user.Country,
+		Id:           s.repo.Count() + 1,
+		Name:         user.Name,
+		City:         user.City,
+		State:        user.State,
+		Country:      user.Country,
+		Username:     user.Username,
+		Email:        user.Email,
+		Role:         policy.RoleUser,
+		PasswordHash: passwordHash,
 	}
-	err := s.repo.InsertUser(ctx, newuser)
+	err = s.repo.InsertUser(ctx, newuser)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "error inserting user in repository")
 		return err
 	}
 
+	// The id comes from the database, read the row back for the verification link
+	created, err := s.repo.GetByUsername(ctx, user.Username)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	// The account exists either way, a failed mail shouldn't fail the registration
+	if err := s.accounts.SendVerificationEmail(ctx, created); err != nil {
+		zap.L().Error("Failed to send verification email", zap.Int("user", created.Id), zap.Error(err))
+	}
+
 	return nil
 }
 
@@ -172,11 +223,14 @@ func (s *LocalUserService) DeleteUser(ctx context.Context, id int) error {
 
 func ConvertUserToUserReponseDTO(user *model.User) model.UserResponseDTO {
 	return model.UserResponseDTO{
-		Id:      user.Id,
-		Name:    user.Name,
-		City:    user.City,
-		State:   user.State,
-		Country: user.Country,
+		Id:            user.Id,
+		Name:          user.Name,
+		City:          user.City,
+		State:         user.State,
+		Country:       user.Country,
+		Username:      user.Username,
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
 	}
 }
 
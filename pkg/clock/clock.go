@@ -0,0 +1,45 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock lets time-dependent code be driven by a fake in tests and local runs
+type Clock interface {
+	Now() time.Time
+}
+
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// Fake only moves when told to
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
@@ -0,0 +1,94 @@
+package ratelimiter
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+// AIMDLimiter caps in-flight work. Every request finishing under the latency target grows the cap by 1/limit,
+// about one per round of requests, and a slower one cuts it by Backoff.
+type AIMDLimiter struct {
+	LatencyTarget time.Duration
+	Backoff       float64
+	limit         float64
+	min           float64
+	max           float64
+	inflight      int
+	lastBackoff   time.Time
+	clock         clock.Clock
+	mutex         sync.Mutex
+}
+
+func NewAIMDLimiter(initial, min, max int, latencyTarget time.Duration, clock clock.Clock) *AIMDLimiter {
+	return &AIMDLimiter{
+		LatencyTarget: latencyTarget,
+		Backoff:       0.9,
+		limit:         float64(initial),
+		min:           float64(min),
+		max:           float64(max),
+		clock:         clock,
+	}
+}
+
+// Acquire takes a slot, every successful call must be paired with Release
+func (l *AIMDLimiter) Acquire() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.inflight >= int(l.limit) {
+		return false
+	}
+	l.inflight++
+	return true
+}
+
+// Release gives the slot back and adjusts the cap by how long the request took.
+// Requests already running at the last cut don't cut again, so a burst of slow ones backs off once.
+func (l *AIMDLimiter) Release(latency time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.inflight--
+	now := l.clock.Now()
+
+	switch {
+	case latency > l.LatencyTarget:
+		if now.Add(-latency).Before(l.lastBackoff) {
+			return
+		}
+		l.limit = math.Max(l.min, l.limit*l.Backoff)
+		l.lastBackoff = now
+	case float64(l.inflight+1) >= l.limit/2:
+		// Only grow while the cap is actually in use, an idle route shouldn't drift up to max
+		l.limit = math.Min(l.max, l.limit+1/l.limit)
+	}
+}
+
+// Cancel gives the slot back without a latency sample, for requests turned away before doing any real work
+func (l *AIMDLimiter) Cancel() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.inflight--
+}
+
+// Saturated reports whether every slot is taken
+func (l *AIMDLimiter) Saturated() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.inflight >= int(l.limit)
+}
+
+func (l *AIMDLimiter) Limit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return int(l.limit)
+}
+
+func (l *AIMDLimiter) Inflight() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.inflight
+}
@@ -0,0 +1,75 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+func TestAIMDLimiterBacksOffOncePerBurst(t *testing.T) {
+	fake := clock.NewFake(testStart)
+	limiter := NewAIMDLimiter(10, 2, 20, 100*time.Millisecond, fake)
+
+	for range 10 {
+		if !limiter.Acquire() {
+			t.Fatal("Acquire() = false below the cap")
+		}
+	}
+	if limiter.Acquire() {
+		t.Fatal("Acquire() = true at the cap")
+	}
+	if !limiter.Saturated() {
+		t.Error("Saturated() = false with every slot taken")
+	}
+
+	// Ten slow requests that were all in flight together are one signal
+	fake.Advance(500 * time.Millisecond)
+	for range 10 {
+		limiter.Release(500 * time.Millisecond)
+	}
+	if got := limiter.Limit(); got != 9 {
+		t.Fatalf("Limit() = %d after one slow burst, want 9", got)
+	}
+
+	// A slow request that started after the cut cuts again
+	limiter.Acquire()
+	fake.Advance(500 * time.Millisecond)
+	limiter.Release(500 * time.Millisecond)
+	if got := limiter.Limit(); got != 8 {
+		t.Fatalf("Limit() = %d after a later slow request, want 8", got)
+	}
+
+	// Cancelled requests give the slot back without moving the cap
+	for range 8 {
+		limiter.Acquire()
+	}
+	for range 8 {
+		limiter.Cancel()
+	}
+	if got, inflight := limiter.Limit(), limiter.Inflight(); got != 8 || inflight != 0 {
+		t.Fatalf("Limit() = %d, Inflight() = %d after cancelling, want 8 and 0", got, inflight)
+	}
+
+	// Fast requests with the cap in use grow it
+	for range 8 {
+		limiter.Acquire()
+	}
+	for range 8 {
+		limiter.Release(10 * time.Millisecond)
+	}
+	if got := limiter.Limit(); got != 8 {
+		t.Fatalf("Limit() = %d after one round of fast requests, want 8 still growing", got)
+	}
+	for range 3 {
+		for range 8 {
+			limiter.Acquire()
+		}
+		for range 8 {
+			limiter.Release(10 * time.Millisecond)
+		}
+	}
+	if got := limiter.Limit(); got < 9 {
+		t.Errorf("Limit() = %d after several rounds of fast requests, want it to have grown", got)
+	}
+}
@@ -0,0 +1,91 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"go.uber.org/zap"
+)
+
+// FallbackLimiter asks the local limiter whenever the distributed one errors, an outage loosens limits to per-node instead of dropping them.
+// The first error opens the circuit, requests then skip the store until the cooldown is up and a single probe gets through.
+type FallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+	cooldown time.Duration
+	clock    clock.Clock
+
+	mu        sync.Mutex
+	open      bool
+	openUntil time.Time
+	probing   bool
+}
+
+func NewFallbackLimiter(primary, fallback Limiter, cooldown time.Duration, clock clock.Clock) *FallbackLimiter {
+	return &FallbackLimiter{primary: primary, fallback: fallback, cooldown: cooldown, clock: clock}
+}
+
+func (l *FallbackLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	usePrimary, probe := l.route()
+	if !usePrimary {
+		return l.fallback.Allow(ctx, key)
+	}
+
+	decision, err := l.primary.Allow(ctx, key)
+	if err != nil {
+		l.trip(probe, err)
+		return l.fallback.Allow(ctx, key)
+	}
+
+	if probe {
+		l.recover()
+	}
+	return decision, nil
+}
+
+// route says whether to ask the store, probe is set for the one request let through after the cooldown
+func (l *FallbackLimiter) route() (usePrimary, probe bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.open {
+		return true, false
+	}
+	if l.probing || l.clock.Now().Before(l.openUntil) {
+		return false, false
+	}
+
+	l.probing = true
+	return true, true
+}
+
+// trip opens the circuit, requests that were already in flight when it opened don't push the cooldown out
+func (l *FallbackLimiter) trip(probe bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.open && !probe {
+		return
+	}
+
+	wasOpen := l.open
+	l.open = true
+	l.probing = false
+	l.openUntil = l.clock.Now().Add(l.cooldown)
+
+	if !wasOpen {
+		zap.L().Warn("Rate limiter store unavailable, using local limits", zap.Duration("cooldown", l.cooldown), zap.Error(err))
+	}
+}
+
+func (l *FallbackLimiter) recover() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.open = false
+	l.probing = false
+
+	zap.L().Info("Rate limiter store recovered, using distributed limits")
+}
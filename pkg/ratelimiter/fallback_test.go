@@ -0,0 +1,99 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// flakyLimiter stands in for redis, it counts calls and fails while down
+type flakyLimiter struct {
+	down  bool
+	calls int
+}
+
+func (l *flakyLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	l.calls++
+	if l.down {
+		return Decision{}, errors.New("connection refused")
+	}
+	return Decision{Allowed: true, Limit: 100, Remaining: 99}, nil
+}
+
+func TestFallbackLimiterCircuit(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	primary := &flakyLimiter{}
+	local := NewFixedWindowLimiter(time.Hour, 1000, fake)
+	limiter := NewFallbackLimiter(primary, local, 5*time.Second, fake)
+
+	allow := func() Decision {
+		t.Helper()
+		decision, err := limiter.Allow(context.Background(), "k")
+		if err != nil {
+			t.Fatalf("Allow() error = %v, the fallback never errors", err)
+		}
+		return decision
+	}
+
+	// Closed, the store decides
+	if decision := allow(); decision.Limit != 100 || primary.calls != 1 {
+		t.Fatalf("closed: limit %d after %d store calls, want the store's decision", decision.Limit, primary.calls)
+	}
+
+	// The first error opens the circuit and the local limiter answers
+	primary.down = true
+	if decision := allow(); decision.Limit != 1000 {
+		t.Fatalf("tripping request got limit %d, want the local limiter's", decision.Limit)
+	}
+	if primary.calls != 2 {
+		t.Fatalf("store calls = %d, want 2", primary.calls)
+	}
+
+	// Open, the store is left alone until the cooldown is up
+	for range 10 {
+		allow()
+	}
+	fake.Advance(4 * time.Second)
+	allow()
+	if primary.calls != 2 {
+		t.Fatalf("store was called %d times while open, want 2", primary.calls)
+	}
+
+	// A failed probe keeps it open for another cooldown
+	fake.Advance(time.Second)
+	allow()
+	if primary.calls != 3 {
+		t.Fatalf("store calls = %d after the cooldown, want one probe", primary.calls)
+	}
+	allow()
+	if primary.calls != 3 {
+		t.Fatalf("store calls = %d after a failed probe, want the circuit to stay open", primary.calls)
+	}
+
+	// A successful probe closes it again
+	primary.down = false
+	fake.Advance(5 * time.Second)
+	if decision := allow(); decision.Limit != 100 {
+		t.Fatalf("probe got limit %d, want the store's decision", decision.Limit)
+	}
+	allow()
+	if primary.calls != 5 {
+		t.Fatalf("store calls = %d after recovering, want 5", primary.calls)
+	}
+
+	if opened := logs.FilterMessage("Rate limiter store unavailable, using local limits").Len(); opened != 1 {
+		t.Errorf("logged opening %d times, want once", opened)
+	}
+	if recovered := logs.FilterMessage("Rate limiter store recovered, using distributed limits").Len(); recovered != 1 {
+		t.Errorf("logged recovery %d times, want once", recovered)
+	}
+}
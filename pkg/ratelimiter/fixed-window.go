@@ -1,8 +1,11 @@
 package ratelimiter
 
 import (
+	"context"
 	"sync"
 	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
 )
 
 type ClientInfo struct {
@@ -14,44 +17,53 @@ type FixedWindowLimiter struct {
 	WindowDuration time.Duration
 	LimitPerWindow int
 	Table          map[string]*ClientInfo
+	clock          clock.Clock
 	mutex          sync.Mutex
 }
 
-func (f *FixedWindowLimiter) Allow(ip string) bool {
+func NewFixedWindowLimiter(window time.Duration, limit int, clock clock.Clock) *FixedWindowLimiter {
+	return &FixedWindowLimiter{
+		WindowDuration: window,
+		LimitPerWindow: limit,
+		Table:          make(map[string]*ClientInfo),
+		clock:          clock,
+	}
+}
+
+func (f *FixedWindowLimiter) Allow(ctx context.Context, key string) (Decision, error) {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
-	data, exists := f.Table[ip]
-	currentTime := time.Now()
-
-	// If Ip doesnt exist it means its a compleely new request
-	if !exists {
-		f.Table[ip] = &ClientInfo{Count: 1, WindowStartTime: currentTime}
-		return true
-	}
+	data, exists := f.Table[key]
+	currentTime := f.clock.Now()
 
-	// Window time passed, reset the counter
-	if currentTime.Sub(data.WindowStartTime) >= f.WindowDuration {
-		data.Count = 1
-		data.WindowStartTime = time.Now()
-		return true
+	// A new key or a passed window starts counting from zero
+	if !exists || currentTime.Sub(data.WindowStartTime) >= f.WindowDuration {
+		data = &ClientInfo{WindowStartTime: currentTime}
+		f.Table[key] = data
 	}
 
-	// Still within the window, just imcrement the counter
-	if data.Count < f.LimitPerWindow {
-		data.Count++
-		return true
+	resetAt := data.WindowStartTime.Add(f.WindowDuration)
+	if data.Count >= f.LimitPerWindow {
+		return denied(f.LimitPerWindow, currentTime, resetAt, resetAt), nil
 	}
 
-	return false
+	data.Count++
+	return Decision{
+		Allowed:   true,
+		Limit:     f.LimitPerWindow,
+		Remaining: f.LimitPerWindow - data.Count,
+		ResetAt:   resetAt,
+	}, nil
 }
 
 func (f *FixedWindowLimiter) AutoEvict(evictDuration time.Duration) {
 	ticker := time.NewTicker(evictDuration)
 	for range ticker.C {
 		f.mutex.Lock() // This is similar to table level locking - may use row level locking in future
+		now := f.clock.Now()
 		for key, value := range f.Table {
-			if time.Since(value.WindowStartTime) >= evictDuration {
+			if now.Sub(value.WindowStartTime) >= evictDuration {
 				delete(f.Table, key)
 			}
 		}
@@ -0,0 +1,72 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+// GCRALimiter is the generic cell rate algorithm, a token bucket that only stores one timestamp per key.
+// Requests are spaced Period/Limit apart with bursts of up to Limit.
+type GCRALimiter struct {
+	Period time.Duration
+	Limit  int
+	// Table holds the theoretical arrival time of the next request per key
+	Table map[string]time.Time
+	clock clock.Clock
+	mutex sync.Mutex
+}
+
+func NewGCRALimiter(period time.Duration, limit int, clock clock.Clock) *GCRALimiter {
+	return &GCRALimiter{
+		Period: period,
+		Limit:  limit,
+		Table:  make(map[string]time.Time),
+		clock:  clock,
+	}
+}
+
+func (l *GCRALimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now()
+	emission := l.Period / time.Duration(l.Limit)
+
+	tat := l.Table[key]
+	if tat.Before(now) {
+		tat = now
+	}
+
+	// The request is on time if the arrival it pushes out stays within one period of now
+	next := tat.Add(emission)
+	allowAt := next.Add(-l.Period)
+	if now.Before(allowAt) {
+		return denied(l.Limit, now, allowAt, tat), nil
+	}
+
+	l.Table[key] = next
+	return Decision{
+		Allowed:   true,
+		Limit:     l.Limit,
+		Remaining: int((l.Period - next.Sub(now)) / emission),
+		ResetAt:   next,
+	}, nil
+}
+
+func (l *GCRALimiter) AutoEvict(evictDuration time.Duration) {
+	ticker := time.NewTicker(evictDuration)
+	for range ticker.C {
+		l.mutex.Lock()
+		now := l.clock.Now()
+		for key, tat := range l.Table {
+			// A theoretical arrival time in the past is the same as no entry
+			if tat.Before(now) {
+				delete(l.Table, key)
+			}
+		}
+		l.mutex.Unlock()
+	}
+}
@@ -0,0 +1,39 @@
+package ratelimiter
+
+import (
+	"context"
+	"time"
+)
+
+// Decision is the outcome of one Allow call, enough to fill in rate limit response headers
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAt is when the key is back to its full limit
+	ResetAt time.Time
+	// RetryAfter is how long a denied key has to wait, zero when allowed
+	RetryAfter time.Duration
+}
+
+// Limiter decides whether one more request for a key fits, only store-backed limiters return errors
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Decision, error)
+}
+
+// LocalLimiter keeps its table in memory, AutoEvict has to run alongside it to keep idle keys from piling up
+type LocalLimiter interface {
+	Limiter
+	AutoEvict(evictDuration time.Duration)
+}
+
+// denied fills in a rejection that frees up at retryAt
+func denied(limit int, now, retryAt, resetAt time.Time) Decision {
+	return Decision{
+		Allowed:    false,
+		Limit:      limit,
+		Remaining:  0,
+		ResetAt:    resetAt,
+		RetryAfter: max(retryAt.Sub(now), 0),
+	}
+}
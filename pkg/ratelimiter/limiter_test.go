@@ -0,0 +1,140 @@
+package ratelimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+// Whole days from the zero time, so windows that truncate line up with it
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// limiterStep is one call at an offset from testStart, ResetAt is an offset too
+type limiterStep struct {
+	name       string
+	at         time.Duration
+	key        string // Defaults to "k"
+	n          int    // AllowN when set, only the token bucket has it
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+	resetAt    time.Duration
+}
+
+func runLimiterSteps(t *testing.T, limiter Limiter, fake *clock.Fake, steps []limiterStep) {
+	t.Helper()
+
+	for i, step := range steps {
+		fake.Set(testStart.Add(step.at))
+
+		key := step.key
+		if key == "" {
+			key = "k"
+		}
+
+		var decision Decision
+		var err error
+		if step.n > 0 {
+			decision, err = limiter.(*TokenBucketLimiter).AllowN(context.Background(), key, step.n)
+		} else {
+			decision, err = limiter.Allow(context.Background(), key)
+		}
+		if err != nil {
+			t.Fatalf("step %d (%s): Allow() error = %v", i, step.name, err)
+		}
+
+		if decision.Allowed != step.allowed {
+			t.Errorf("step %d (%s): Allowed = %v, want %v", i, step.name, decision.Allowed, step.allowed)
+		}
+		if decision.Remaining != step.remaining {
+			t.Errorf("step %d (%s): Remaining = %d, want %d", i, step.name, decision.Remaining, step.remaining)
+		}
+		if decision.RetryAfter != step.retryAfter {
+			t.Errorf("step %d (%s): RetryAfter = %v, want %v", i, step.name, decision.RetryAfter, step.retryAfter)
+		}
+		if want := testStart.Add(step.resetAt); !decision.ResetAt.Equal(want) {
+			t.Errorf("step %d (%s): ResetAt = +%v, want +%v", i, step.name, decision.ResetAt.Sub(testStart), step.resetAt)
+		}
+	}
+}
+
+func TestFixedWindowLimiter(t *testing.T) {
+	fake := clock.NewFake(testStart)
+	limiter := NewFixedWindowLimiter(10*time.Second, 3, fake)
+
+	runLimiterSteps(t, limiter, fake, []limiterStep{
+		{name: "first", allowed: true, remaining: 2, resetAt: 10 * time.Second},
+		{name: "second", allowed: true, remaining: 1, resetAt: 10 * time.Second},
+		{name: "last", allowed: true, remaining: 0, resetAt: 10 * time.Second},
+		{name: "over", at: 4 * time.Second, retryAfter: 6 * time.Second, resetAt: 10 * time.Second},
+		{name: "just before the window ends", at: 10*time.Second - time.Millisecond, retryAfter: time.Millisecond, resetAt: 10 * time.Second},
+		{name: "window boundary starts over", at: 10 * time.Second, allowed: true, remaining: 2, resetAt: 20 * time.Second},
+		{name: "other key counts alone", at: 10 * time.Second, key: "other", allowed: true, remaining: 2, resetAt: 20 * time.Second},
+	})
+}
+
+func TestSlidingWindowLogLimiter(t *testing.T) {
+	fake := clock.NewFake(testStart)
+	limiter := NewSlidingWindowLogLimiter(10*time.Second, 2, fake)
+
+	runLimiterSteps(t, limiter, fake, []limiterStep{
+		{name: "first", allowed: true, remaining: 1, resetAt: 10 * time.Second},
+		{name: "second", at: 3 * time.Second, allowed: true, remaining: 0, resetAt: 13 * time.Second},
+		{name: "retry when the oldest leaves, reset when the newest does", at: 5 * time.Second, retryAfter: 5 * time.Second, resetAt: 13 * time.Second},
+		{name: "oldest slid out at the boundary", at: 10 * time.Second, allowed: true, remaining: 0, resetAt: 20 * time.Second},
+		{name: "full again", at: 12 * time.Second, retryAfter: time.Second, resetAt: 20 * time.Second},
+		{name: "next one slid out", at: 13 * time.Second, allowed: true, remaining: 0, resetAt: 23 * time.Second},
+	})
+}
+
+func TestSlidingWindowCounterLimiter(t *testing.T) {
+	fake := clock.NewFake(testStart)
+	limiter := NewSlidingWindowCounterLimiter(10*time.Second, 4, fake)
+
+	runLimiterSteps(t, limiter, fake, []limiterStep{
+		{name: "first", allowed: true, remaining: 3, resetAt: 20 * time.Second},
+		{name: "second", allowed: true, remaining: 2, resetAt: 20 * time.Second},
+		{name: "third", allowed: true, remaining: 1, resetAt: 20 * time.Second},
+		{name: "fourth", allowed: true, remaining: 0, resetAt: 20 * time.Second},
+		// The full window has to become the previous one and a quarter of it slide out
+		{name: "current window full", retryAfter: 12500 * time.Millisecond, resetAt: 20 * time.Second},
+		{name: "previous weighted by three quarters", at: 12500 * time.Millisecond, allowed: true, remaining: 0, resetAt: 30 * time.Second},
+		{name: "needs half the previous gone", at: 12500 * time.Millisecond, retryAfter: 2500 * time.Millisecond, resetAt: 30 * time.Second},
+		{name: "half gone", at: 15 * time.Second, allowed: true, remaining: 0, resetAt: 30 * time.Second},
+		{name: "two windows later nothing overlaps", at: 35 * time.Second, allowed: true, remaining: 3, resetAt: 50 * time.Second},
+	})
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	fake := clock.NewFake(testStart)
+	limiter := NewTokenBucketLimiter(3, time.Second, fake)
+
+	runLimiterSteps(t, limiter, fake, []limiterStep{
+		{name: "starts full", allowed: true, remaining: 2, resetAt: time.Second},
+		{name: "second", allowed: true, remaining: 1, resetAt: 2 * time.Second},
+		{name: "empties", allowed: true, remaining: 0, resetAt: 3 * time.Second},
+		{name: "empty", retryAfter: time.Second, resetAt: 3 * time.Second},
+		{name: "half a token", at: 500 * time.Millisecond, retryAfter: 500 * time.Millisecond, resetAt: 3 * time.Second},
+		{name: "one token refilled", at: time.Second, allowed: true, remaining: 0, resetAt: 4 * time.Second},
+		{name: "AllowN takes several", at: 3 * time.Second, n: 2, allowed: true, remaining: 0, resetAt: 6 * time.Second},
+		{name: "AllowN waits for all of them", at: 4 * time.Second, n: 3, retryAfter: 2 * time.Second, resetAt: 6 * time.Second},
+		{name: "AllowN above capacity never fits", at: time.Minute, n: 4, retryAfter: time.Second, resetAt: time.Minute},
+		{name: "refill stops at capacity", at: time.Minute, n: 3, allowed: true, remaining: 0, resetAt: time.Minute + 3*time.Second},
+	})
+}
+
+func TestGCRALimiter(t *testing.T) {
+	fake := clock.NewFake(testStart)
+	limiter := NewGCRALimiter(10*time.Second, 2, fake)
+
+	runLimiterSteps(t, limiter, fake, []limiterStep{
+		{name: "first", allowed: true, remaining: 1, resetAt: 5 * time.Second},
+		{name: "burst", allowed: true, remaining: 0, resetAt: 10 * time.Second},
+		{name: "over the burst", retryAfter: 5 * time.Second, resetAt: 10 * time.Second},
+		{name: "one emission later", at: 5 * time.Second, allowed: true, remaining: 0, resetAt: 15 * time.Second},
+		{name: "too early", at: 7 * time.Second, retryAfter: 3 * time.Second, resetAt: 15 * time.Second},
+		{name: "idle key starts fresh", at: 20 * time.Second, allowed: true, remaining: 1, resetAt: 25 * time.Second},
+	})
+}
@@ -0,0 +1,71 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisLockoutStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisLockoutStore(rdb *redis.Client) *RedisLockoutStore {
+	return &RedisLockoutStore{rdb: rdb}
+}
+
+func lockoutFailuresKey(key string) string {
+	return fmt.Sprintf("lockout:failures:%s", key)
+}
+
+func lockoutLockedKey(key string) string {
+	return fmt.Sprintf("lockout:locked:%s", key)
+}
+
+// The failure is counted and the lock set in one step, so a failed write can't leave a count without its lock.
+// ARGV holds the window, the threshold and the lock of each failure from the threshold on, the last one repeats.
+var lockoutFailScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+local threshold = tonumber(ARGV[2])
+if threshold <= 0 or count < threshold or #ARGV < 3 then
+	return 0
+end
+local locked = tonumber(ARGV[math.min(3 + count - threshold, #ARGV)])
+if locked > 0 then
+	redis.call('SET', KEYS[2], 1, 'PX', locked)
+end
+return locked
+`)
+
+// Fail sticks to commands every Redis version has, the window starts at the first failure
+func (s *RedisLockoutStore) Fail(ctx context.Context, key string, policy LockoutPolicy) (time.Duration, error) {
+	args := []any{policy.Window.Milliseconds(), policy.Threshold}
+	for _, locked := range policy.schedule() {
+		args = append(args, locked.Milliseconds())
+	}
+
+	locked, err := lockoutFailScript.Run(ctx, s.rdb, []string{lockoutFailuresKey(key), lockoutLockedKey(key)}, args...).Int64()
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(locked) * time.Millisecond, nil
+}
+
+func (s *RedisLockoutStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.rdb.PTTL(ctx, lockoutLockedKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// Negative means no key or no expiry
+	return max(ttl, 0), nil
+}
+
+func (s *RedisLockoutStore) Reset(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, lockoutFailuresKey(key), lockoutLockedKey(key)).Err()
+}
@@ -0,0 +1,168 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+	"go.uber.org/zap"
+)
+
+// LockoutPolicy locks a key once it reaches Threshold failures inside Window,
+// each further failure doubles the lock up to Max
+type LockoutPolicy struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+// Duration is how long a key with this many failures stays locked, zero below the threshold
+func (p LockoutPolicy) Duration(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+
+	duration := p.Base
+	for range failures - p.Threshold {
+		duration *= 2
+		if duration >= p.Max {
+			return p.Max
+		}
+	}
+	return min(duration, p.Max)
+}
+
+// schedule lists Duration from the threshold on until it stops growing, what a store without the policy code needs
+func (p LockoutPolicy) schedule() []time.Duration {
+	if p.Threshold <= 0 {
+		return nil
+	}
+
+	var locks []time.Duration
+	for failures := p.Threshold; ; failures++ {
+		locked := p.Duration(failures)
+		locks = append(locks, locked)
+		if locked <= 0 || locked >= p.Max || len(locks) >= 64 {
+			return locks
+		}
+	}
+}
+
+// LockoutStore tracks failed attempts per key
+type LockoutStore interface {
+	// Fail records a failure and returns how long the key is locked for as a result
+	Fail(ctx context.Context, key string, policy LockoutPolicy) (time.Duration, error)
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	Reset(ctx context.Context, key string) error
+}
+
+type lockoutEntry struct {
+	ClientInfo
+	lockedUntil time.Time
+}
+
+// InMemoryLockoutStore counts failures in a fixed window per key, the same way FixedWindowLimiter counts requests
+type InMemoryLockoutStore struct {
+	Table map[string]*lockoutEntry
+	clock clock.Clock
+	mutex sync.Mutex
+}
+
+func NewInMemoryLockoutStore(clock clock.Clock) *InMemoryLockoutStore {
+	return &InMemoryLockoutStore{
+		Table: make(map[string]*lockoutEntry),
+		clock: clock,
+	}
+}
+
+func (s *InMemoryLockoutStore) Fail(ctx context.Context, key string, policy LockoutPolicy) (time.Duration, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.clock.Now()
+	data, exists := s.Table[key]
+	if !exists || now.Sub(data.WindowStartTime) >= policy.Window {
+		data = &lockoutEntry{ClientInfo: ClientInfo{WindowStartTime: now}}
+		s.Table[key] = data
+	}
+
+	data.Count++
+	locked := policy.Duration(data.Count)
+	if locked > 0 {
+		data.lockedUntil = now.Add(locked)
+	}
+
+	return locked, nil
+}
+
+func (s *InMemoryLockoutStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, exists := s.Table[key]
+	if !exists {
+		return 0, nil
+	}
+	return max(data.lockedUntil.Sub(s.clock.Now()), 0), nil
+}
+
+func (s *InMemoryLockoutStore) Reset(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.Table, key)
+	return nil
+}
+
+// AutoEvict drops keys that are neither locked nor inside their failure window
+func (s *InMemoryLockoutStore) AutoEvict(evictDuration time.Duration) {
+	ticker := time.NewTicker(evictDuration)
+	for range ticker.C {
+		s.mutex.Lock()
+		now := s.clock.Now()
+		for key, value := range s.Table {
+			if now.Sub(value.WindowStartTime) >= evictDuration && now.After(value.lockedUntil) {
+				delete(s.Table, key)
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// FallbackLockoutStore uses the fallback whenever the primary errors, so an outage degrades to per-node tracking instead of no tracking
+type FallbackLockoutStore struct {
+	primary  LockoutStore
+	fallback LockoutStore
+}
+
+func NewFallbackLockoutStore(primary, fallback LockoutStore) *FallbackLockoutStore {
+	return &FallbackLockoutStore{primary: primary, fallback: fallback}
+}
+
+func (s *FallbackLockoutStore) Fail(ctx context.Context, key string, policy LockoutPolicy) (time.Duration, error) {
+	locked, err := s.primary.Fail(ctx, key, policy)
+	if err != nil {
+		zap.L().Warn("Lockout store unavailable, using fallback", zap.Error(err))
+		return s.fallback.Fail(ctx, key, policy)
+	}
+	return locked, nil
+}
+
+// LockedFor honours a lock from either store, a key locked during an outage stays locked after recovery
+func (s *FallbackLockoutStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	fallbackLocked, _ := s.fallback.LockedFor(ctx, key)
+
+	locked, err := s.primary.LockedFor(ctx, key)
+	if err != nil {
+		zap.L().Warn("Lockout store unavailable, using fallback", zap.Error(err))
+		return fallbackLocked, nil
+	}
+	return max(locked, fallbackLocked), nil
+}
+
+func (s *FallbackLockoutStore) Reset(ctx context.Context, key string) error {
+	s.fallback.Reset(ctx, key)
+	return s.primary.Reset(ctx, key)
+}
@@ -0,0 +1,87 @@
+package ratelimiter
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+var testLockoutPolicy = LockoutPolicy{Threshold: 3, Base: time.Minute, Max: 10 * time.Minute, Window: 15 * time.Minute}
+
+func TestLockoutPolicyDuration(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{6, 8 * time.Minute},
+		{7, 10 * time.Minute},
+		{1000, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := testLockoutPolicy.Duration(tt.failures); got != tt.want {
+			t.Errorf("Duration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	if got := (LockoutPolicy{}).Duration(100); got != 0 {
+		t.Errorf("disabled policy Duration() = %v, want 0", got)
+	}
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute}
+	if got := testLockoutPolicy.schedule(); !slices.Equal(got, want) {
+		t.Errorf("schedule() = %v, want %v", got, want)
+	}
+}
+
+func TestInMemoryLockoutStore(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(testStart)
+	store := NewInMemoryLockoutStore(fake)
+
+	steps := []struct {
+		name       string
+		at         time.Duration
+		fail       bool
+		wantLocked time.Duration
+	}{
+		{name: "first failure", fail: true},
+		{name: "second failure", at: time.Minute, fail: true},
+		{name: "threshold locks", at: 2 * time.Minute, fail: true, wantLocked: time.Minute},
+		{name: "lock counts down", at: 2*time.Minute + 20*time.Second, wantLocked: 40 * time.Second},
+		{name: "lock ran out", at: 3 * time.Minute},
+		{name: "next failure doubles", at: 4 * time.Minute, fail: true, wantLocked: 2 * time.Minute},
+		{name: "a new window starts over", at: 16 * time.Minute, fail: true},
+	}
+
+	for i, step := range steps {
+		fake.Set(testStart.Add(step.at))
+
+		var locked time.Duration
+		var err error
+		if step.fail {
+			locked, err = store.Fail(ctx, "account:alice", testLockoutPolicy)
+		} else {
+			locked, err = store.LockedFor(ctx, "account:alice")
+		}
+		if err != nil || locked != step.wantLocked {
+			t.Errorf("step %d (%s): locked for %v, %v, want %v", i, step.name, locked, err, step.wantLocked)
+		}
+	}
+
+	for range 3 {
+		store.Fail(ctx, "account:bob", testLockoutPolicy)
+	}
+	store.Reset(ctx, "account:bob")
+	if locked, _ := store.LockedFor(ctx, "account:bob"); locked != 0 {
+		t.Errorf("LockedFor() after Reset = %v, want 0", locked)
+	}
+}
@@ -0,0 +1,129 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Every window has to be seeded before anything is counted, -1 asks the caller to seed them
+var quotaConsumeScript = redis.NewScript(`
+local allowed = 1
+for i, key in ipairs(KEYS) do
+	if redis.call('EXISTS', key) == 0 then
+		return {-1}
+	end
+	local limit = tonumber(ARGV[i])
+	if limit > 0 and tonumber(redis.call('HGET', key, 'used')) >= limit then
+		allowed = 0
+	end
+end
+
+local field = 'rejected'
+if allowed == 1 then
+	field = 'used'
+end
+
+local result = {allowed}
+for i, key in ipairs(KEYS) do
+	redis.call('HINCRBY', key, field, 1)
+	local counter = redis.call('HMGET', key, 'used', 'rejected')
+	table.insert(result, tonumber(counter[1]))
+	table.insert(result, tonumber(counter[2]))
+end
+return result
+`)
+
+var quotaCountersScript = redis.NewScript(`
+local result = {}
+for i, key in ipairs(KEYS) do
+	local counter = redis.call('HMGET', key, 'used', 'rejected')
+	if not counter[1] then
+		return {-1}
+	end
+	table.insert(result, tonumber(counter[1]))
+	table.insert(result, tonumber(counter[2]))
+end
+return result
+`)
+
+// ARGV holds used, rejected and the expiry in unix milliseconds for each window
+var quotaSeedScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	if redis.call('HSETNX', key, 'used', ARGV[i * 3 - 2]) == 1 then
+		redis.call('HSET', key, 'rejected', ARGV[i * 3 - 1])
+		redis.call('PEXPIREAT', key, ARGV[i * 3])
+	end
+end
+return 1
+`)
+
+// quotaRetention keeps a window's counters past its end, long enough for the last flush to read them
+const quotaRetention = time.Hour
+
+// RedisQuotaStore is the QuotaCache shared by every node, each window is a hash that expires after the window ends
+type RedisQuotaStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisQuotaStore(rdb *redis.Client) *RedisQuotaStore {
+	return &RedisQuotaStore{rdb: rdb}
+}
+
+// The hash tag keeps every window of a key in one cluster slot, the scripts touch them together
+func redisQuotaKeys(key string, windows []QuotaWindow) []string {
+	keys := make([]string, len(windows))
+	for i, window := range windows {
+		keys[i] = fmt.Sprintf("quota:{%s}:%s:%d", key, window.Period, window.Start.Unix())
+	}
+	return keys
+}
+
+func quotaCounters(result []int64) ([]QuotaCounter, error) {
+	if len(result) > 0 && result[0] == -1 {
+		return nil, ErrQuotaNotSeeded
+	}
+
+	counters := make([]QuotaCounter, len(result)/2)
+	for i := range counters {
+		counters[i] = QuotaCounter{Used: int(result[i*2]), Rejected: int(result[i*2+1])}
+	}
+	return counters, nil
+}
+
+func (s *RedisQuotaStore) Consume(ctx context.Context, key string, windows []QuotaWindow) ([]QuotaCounter, bool, error) {
+	limits := make([]any, len(windows))
+	for i, window := range windows {
+		limits[i] = window.Limit
+	}
+
+	result, err := quotaConsumeScript.Run(ctx, s.rdb, redisQuotaKeys(key, windows), limits...).Int64Slice()
+	if err != nil {
+		return nil, false, err
+	}
+	if result[0] == -1 {
+		return nil, false, ErrQuotaNotSeeded
+	}
+
+	counters, err := quotaCounters(result[1:])
+	return counters, result[0] == 1, err
+}
+
+func (s *RedisQuotaStore) Counters(ctx context.Context, key string, windows []QuotaWindow) ([]QuotaCounter, error) {
+	result, err := quotaCountersScript.Run(ctx, s.rdb, redisQuotaKeys(key, windows)).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return quotaCounters(result)
+}
+
+func (s *RedisQuotaStore) Seed(ctx context.Context, key string, windows []QuotaWindow, counters []QuotaCounter) error {
+	args := make([]any, 0, len(windows)*3)
+	for i, window := range windows {
+		args = append(args, counters[i].Used, counters[i].Rejected, window.End.Add(quotaRetention).UnixMilli())
+	}
+
+	return quotaSeedScript.Run(ctx, s.rdb, redisQuotaKeys(key, windows), args...).Err()
+}
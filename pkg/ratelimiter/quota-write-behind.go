@@ -0,0 +1,114 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WriteBehindQuotaStore counts in a QuotaCache and saves what it counted to a QuotaPersister on every flush.
+// The persister is only read the first time the cache sees a window, after a restart or a new period.
+type WriteBehindQuotaStore struct {
+	cache     QuotaCache
+	persister QuotaPersister
+	dirty     map[string]QuotaSnapshot
+	mutex     sync.Mutex
+}
+
+func NewWriteBehindQuotaStore(cache QuotaCache, persister QuotaPersister) *WriteBehindQuotaStore {
+	return &WriteBehindQuotaStore{
+		cache:     cache,
+		persister: persister,
+		dirty:     make(map[string]QuotaSnapshot),
+	}
+}
+
+func (s *WriteBehindQuotaStore) Consume(ctx context.Context, key string, windows []QuotaWindow) ([]QuotaCounter, bool, error) {
+	counters, allowed, err := s.cache.Consume(ctx, key, windows)
+	if errors.Is(err, ErrQuotaNotSeeded) {
+		if err := s.seed(ctx, key, windows); err != nil {
+			return nil, false, err
+		}
+		counters, allowed, err = s.cache.Consume(ctx, key, windows)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, window := range windows {
+		s.markDirty(QuotaSnapshot{Key: key, Window: window, QuotaCounter: counters[i]})
+	}
+	return counters, allowed, nil
+}
+
+func (s *WriteBehindQuotaStore) Counters(ctx context.Context, key string, windows []QuotaWindow) ([]QuotaCounter, error) {
+	counters, err := s.cache.Counters(ctx, key, windows)
+	if errors.Is(err, ErrQuotaNotSeeded) {
+		if err := s.seed(ctx, key, windows); err != nil {
+			return nil, err
+		}
+		counters, err = s.cache.Counters(ctx, key, windows)
+	}
+	return counters, err
+}
+
+// seed loads the persisted counters, the cache keeps whatever another request or node seeded first
+func (s *WriteBehindQuotaStore) seed(ctx context.Context, key string, windows []QuotaWindow) error {
+	counters, err := s.persister.Counters(ctx, key, windows)
+	if err != nil {
+		return err
+	}
+	return s.cache.Seed(ctx, key, windows, counters)
+}
+
+// markDirty keeps the highest counters seen, concurrent requests can report back out of order
+func (s *WriteBehindQuotaStore) markDirty(snapshot QuotaSnapshot) {
+	entryKey := quotaEntryKey(snapshot.Key, snapshot.Window)
+	if current, exists := s.dirty[entryKey]; exists {
+		snapshot.Used = max(snapshot.Used, current.Used)
+		snapshot.Rejected = max(snapshot.Rejected, current.Rejected)
+	}
+	s.dirty[entryKey] = snapshot
+}
+
+// Flush saves everything counted since the last flush, on failure it is kept for the next one
+func (s *WriteBehindQuotaStore) Flush(ctx context.Context) error {
+	s.mutex.Lock()
+	dirty := s.dirty
+	s.dirty = make(map[string]QuotaSnapshot)
+	s.mutex.Unlock()
+
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	snapshots := make([]QuotaSnapshot, 0, len(dirty))
+	for _, snapshot := range dirty {
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if err := s.persister.Save(ctx, snapshots); err != nil {
+		s.mutex.Lock()
+		for _, snapshot := range snapshots {
+			s.markDirty(snapshot)
+		}
+		s.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *WriteBehindQuotaStore) AutoFlush(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		if err := s.Flush(context.Background()); err != nil {
+			zap.L().Warn("Flushing quota counters failed, retrying on the next flush", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+// QuotaPeriod is a calendar window in UTC, unlike the limiters its counters reset on the boundary rather than rolling
+type QuotaPeriod string
+
+const (
+	QuotaDaily   QuotaPeriod = "daily"
+	QuotaMonthly QuotaPeriod = "monthly"
+)
+
+// Bounds returns the start of the period t falls in and the start of the next one
+func (p QuotaPeriod) Bounds(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	if p == QuotaMonthly {
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// Quota allows Limit requests per Period, zero is unlimited but still counted
+type Quota struct {
+	Period QuotaPeriod
+	Limit  int
+}
+
+// QuotaWindow is a quota pinned to the period it is currently counting
+type QuotaWindow struct {
+	Quota
+	Start time.Time
+	End   time.Time
+}
+
+// QuotaCounter is what a window has counted so far, rejected requests don't use up the quota
+type QuotaCounter struct {
+	Used     int
+	Rejected int
+}
+
+// QuotaStore keeps counters per key and window, they have to survive restarts to mean anything over a month
+type QuotaStore interface {
+	// Consume counts one request against every window if all of them have room left, and a rejection in each otherwise.
+	// Counters come back in the order of windows.
+	Consume(ctx context.Context, key string, windows []QuotaWindow) ([]QuotaCounter, bool, error)
+	Counters(ctx context.Context, key string, windows []QuotaWindow) ([]QuotaCounter, error)
+}
+
+// ErrQuotaNotSeeded is returned by a QuotaCache for a window it isn't counting yet, nothing was counted
+var ErrQuotaNotSeeded = errors.New("quota window not seeded")
+
+// QuotaCache counts fast enough to be asked on every request, WriteBehindQuotaStore seeds it with the persisted counters
+type QuotaCache interface {
+	QuotaStore
+	// Seed sets the counters of windows the cache doesn't have yet and leaves the others alone
+	Seed(ctx context.Context, key string, windows []QuotaWindow, counters []QuotaCounter) error
+}
+
+// QuotaSnapshot is a window's counters as the cache last reported them
+type QuotaSnapshot struct {
+	Key    string
+	Window QuotaWindow
+	QuotaCounter
+}
+
+// QuotaPersister is where counters outlive the cache, it is read once per window and written in batches
+type QuotaPersister interface {
+	Counters(ctx context.Context, key string, windows []QuotaWindow) ([]QuotaCounter, error)
+	Save(ctx context.Context, snapshots []QuotaSnapshot) error
+}
+
+// QuotaUsage reports one quota of a key for its current period
+type QuotaUsage struct {
+	Period    QuotaPeriod
+	Limit     int
+	Used      int
+	Rejected  int
+	Remaining int
+	Start     time.Time
+	ResetAt   time.Time
+}
+
+// QuotaLimiter enforces calendar quotas per key, the quotas are passed per call since every key can have its own
+type QuotaLimiter struct {
+	store QuotaStore
+	clock clock.Clock
+}
+
+func NewQuotaLimiter(store QuotaStore, clock clock.Clock) *QuotaLimiter {
+	return &QuotaLimiter{store: store, clock: clock}
+}
+
+func quotaWindows(now time.Time, quotas []Quota) []QuotaWindow {
+	windows := make([]QuotaWindow, len(quotas))
+	for i, quota := range quotas {
+		start, end := quota.Period.Bounds(now)
+		windows[i] = QuotaWindow{Quota: quota, Start: start, End: end}
+	}
+	return windows
+}
+
+// Allow counts one request for key against every quota, the decision describes the tightest one.
+// A key without any limited quota is always allowed and gets a zero Limit.
+func (l *QuotaLimiter) Allow(ctx context.Context, key string, quotas ...Quota) (Decision, error) {
+	now := l.clock.Now()
+	windows := quotaWindows(now, quotas)
+
+	counters, allowed, err := l.store.Consume(ctx, key, windows)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	if !allowed {
+		// Every exhausted window has to roll over before the next request fits
+		var decision Decision
+		for i, window := range windows {
+			if window.Limit > 0 && counters[i].Used >= window.Limit && !window.End.Before(decision.ResetAt) {
+				decision = denied(window.Limit, now, window.End, window.End)
+			}
+		}
+		return decision, nil
+	}
+
+	decision := Decision{Allowed: true}
+	for i, window := range windows {
+		if window.Limit <= 0 {
+			continue
+		}
+		remaining := max(window.Limit-counters[i].Used, 0)
+		if decision.Limit == 0 || remaining < decision.Remaining {
+			decision.Limit = window.Limit
+			decision.Remaining = remaining
+			decision.ResetAt = window.End
+		}
+	}
+	return decision, nil
+}
+
+// Usage reports the current period of every quota without counting anything
+func (l *QuotaLimiter) Usage(ctx context.Context, key string, quotas ...Quota) ([]QuotaUsage, error) {
+	windows := quotaWindows(l.clock.Now(), quotas)
+
+	counters, err := l.store.Counters(ctx, key, windows)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]QuotaUsage, len(windows))
+	for i, window := range windows {
+		usage[i] = QuotaUsage{
+			Period:   window.Period,
+			Limit:    window.Limit,
+			Used:     counters[i].Used,
+			Rejected: counters[i].Rejected,
+			Start:    window.Start,
+			ResetAt:  window.End,
+		}
+		if window.Limit > 0 {
+			usage[i].Remaining = max(window.Limit-counters[i].Used, 0)
+		}
+	}
+	return usage, nil
+}
+
+type quotaEntry struct {
+	QuotaCounter
+	end time.Time
+}
+
+// InMemoryQuotaStore is the QuotaCache of a single node, every node would count a key on its own
+type InMemoryQuotaStore struct {
+	Table map[string]*quotaEntry
+	clock clock.Clock
+	mutex sync.Mutex
+}
+
+func NewInMemoryQuotaStore(clock clock.Clock) *InMemoryQuotaStore {
+	return &InMemoryQuotaStore{
+		Table: make(map[string]*quotaEntry),
+		clock: clock,
+	}
+}
+
+func quotaEntryKey(key string, window QuotaWindow) string {
+	return fmt.Sprintf("%s:%s:%d", key, window.Period, window.Start.Unix())
+}
+
+func (s *InMemoryQuotaStore) Consume(ctx context.Context, key string, windows []QuotaWindow) ([]QuotaCounter, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries := make([]*quotaEntry, len(windows))
+	allowed := true
+	for i, window := range windows {
+		entry, exists := s.Table[quotaEntryKey(key, window)]
+		if !exists {
+			return nil, false, ErrQuotaNotSeeded
+		}
+		entries[i] = entry
+
+		if window.Limit > 0 && entry.Used >= window.Limit {
+			allowed = false
+		}
+	}
+
+	counters := make([]QuotaCounter, len(entries))
+	for i, entry := range entries {
+		if allowed {
+			entry.Used++
+		} else {
+			entry.Rejected++
+		}
+		counters[i] = entry.QuotaCounter
+	}
+
+	return counters, allowed, nil
+}
+
+func (s *InMemoryQuotaStore) Counters(ctx context.Context, key string, windows []QuotaWindow) ([]QuotaCounter, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	counters := make([]QuotaCounter, len(windows))
+	for i, window := range windows {
+		entry, exists := s.Table[quotaEntryKey(key, window)]
+		if !exists {
+			return nil, ErrQuotaNotSeeded
+		}
+		counters[i] = entry.QuotaCounter
+	}
+	return counters, nil
+}
+
+func (s *InMemoryQuotaStore) Seed(ctx context.Context, key string, windows []QuotaWindow, counters []QuotaCounter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, window := range windows {
+		entryKey := quotaEntryKey(key, window)
+		if _, exists := s.Table[entryKey]; !exists {
+			s.Table[entryKey] = &quotaEntry{QuotaCounter: counters[i], end: window.End}
+		}
+	}
+	return nil
+}
+
+// AutoEvict drops the counters of periods that have ended
+func (s *InMemoryQuotaStore) AutoEvict(evictDuration time.Duration) {
+	ticker := time.NewTicker(evictDuration)
+	for range ticker.C {
+		s.mutex.Lock()
+		now := s.clock.Now()
+		for key, value := range s.Table {
+			if !now.Before(value.end) {
+				delete(s.Table, key)
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
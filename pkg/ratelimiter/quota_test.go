@@ -0,0 +1,109 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+// memoryPersister stands in for quota_usage, Save overwrites like the GREATEST upsert does for growing counters
+type memoryPersister struct {
+	rows   map[string]QuotaCounter
+	reads  int
+	saves  int
+	failed bool
+}
+
+func (p *memoryPersister) Counters(ctx context.Context, key string, windows []QuotaWindow) ([]QuotaCounter, error) {
+	p.reads++
+	counters := make([]QuotaCounter, len(windows))
+	for i, window := range windows {
+		counters[i] = p.rows[quotaEntryKey(key, window)]
+	}
+	return counters, nil
+}
+
+func (p *memoryPersister) Save(ctx context.Context, snapshots []QuotaSnapshot) error {
+	if p.failed {
+		return errors.New("database unavailable")
+	}
+	p.saves++
+	for _, snapshot := range snapshots {
+		p.rows[quotaEntryKey(snapshot.Key, snapshot.Window)] = snapshot.QuotaCounter
+	}
+	return nil
+}
+
+func TestQuotaLimiterWriteBehind(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(testStart.Add(12 * time.Hour))
+	quotas := []Quota{{Period: QuotaDaily, Limit: 5}, {Period: QuotaMonthly, Limit: 100}}
+	windows := quotaWindows(fake.Now(), quotas)
+
+	// Three requests were counted and saved before a restart
+	persister := &memoryPersister{rows: map[string]QuotaCounter{
+		quotaEntryKey("k", windows[0]): {Used: 3},
+		quotaEntryKey("k", windows[1]): {Used: 40},
+	}}
+	store := NewWriteBehindQuotaStore(NewInMemoryQuotaStore(fake), persister)
+	limiter := NewQuotaLimiter(store, fake)
+
+	decision, err := limiter.Allow(ctx, "k", quotas...)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if !decision.Allowed || decision.Remaining != 1 || decision.Limit != 5 {
+		t.Fatalf("first request after a restart = %+v, want allowed with 1 left of the daily 5", decision)
+	}
+
+	if decision, _ = limiter.Allow(ctx, "k", quotas...); !decision.Allowed || decision.Remaining != 0 {
+		t.Fatalf("last request = %+v, want allowed with none left", decision)
+	}
+
+	decision, _ = limiter.Allow(ctx, "k", quotas...)
+	if decision.Allowed {
+		t.Fatal("request over the daily quota was allowed")
+	}
+	if want := testStart.Add(24 * time.Hour); !decision.ResetAt.Equal(want) || decision.RetryAfter != 12*time.Hour {
+		t.Errorf("denied ResetAt = %v, RetryAfter = %v, want midnight UTC 12h away", decision.ResetAt, decision.RetryAfter)
+	}
+
+	if persister.reads != 1 || persister.saves != 0 {
+		t.Fatalf("persister read %d and saved %d times before a flush, want 1 seed read and no saves", persister.reads, persister.saves)
+	}
+
+	// A failed flush keeps the counters for the next one
+	persister.failed = true
+	if err := store.Flush(ctx); err == nil {
+		t.Fatal("Flush() succeeded against a failing persister")
+	}
+	persister.failed = false
+	if err := store.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if got := persister.rows[quotaEntryKey("k", windows[0])]; got != (QuotaCounter{Used: 5, Rejected: 1}) {
+		t.Errorf("saved daily counter = %+v, want 5 used and 1 rejected", got)
+	}
+	if got := persister.rows[quotaEntryKey("k", windows[1])]; got != (QuotaCounter{Used: 42, Rejected: 1}) {
+		t.Errorf("saved monthly counter = %+v, want 42 used and 1 rejected", got)
+	}
+
+	// Nothing new was counted, so there is nothing to write
+	if err := store.Flush(ctx); err != nil || persister.saves != 1 {
+		t.Errorf("empty Flush() = %v after %d saves, want no error and no second save", err, persister.saves)
+	}
+
+	// The next day is seeded on its own, the month carries on
+	fake.Advance(12 * time.Hour)
+	usage, err := limiter.Usage(ctx, "k", quotas...)
+	if err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	if usage[0].Used != 0 || usage[0].Remaining != 5 || usage[1].Used != 42 {
+		t.Errorf("usage on the next day = %+v, want a fresh day and the month's 42", usage)
+	}
+}
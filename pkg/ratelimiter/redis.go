@@ -0,0 +1,117 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The counter expires with its window, so the first request of a window also starts its clock
+var fixedWindowScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+local ttl = redis.call('PTTL', KEYS[1])
+if count > tonumber(ARGV[2]) then
+	return {0, count, ttl}
+end
+return {1, count, ttl}
+`)
+
+// Tokens are kept fractional and time comes from the Redis server, so every node refills the same bucket at the same rate
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(now - ts, 0) / interval)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity * interval))
+
+local retry = 0
+if allowed == 0 then
+	retry = math.ceil((1 - tokens) * interval)
+end
+return {allowed, math.floor(tokens), retry, math.ceil((capacity - tokens) * interval)}
+`)
+
+// RedisFixedWindowLimiter shares one counter per key across every node
+type RedisFixedWindowLimiter struct {
+	rdb            *redis.Client
+	WindowDuration time.Duration
+	LimitPerWindow int
+}
+
+func NewRedisFixedWindowLimiter(rdb *redis.Client, window time.Duration, limit int) *RedisFixedWindowLimiter {
+	return &RedisFixedWindowLimiter{rdb: rdb, WindowDuration: window, LimitPerWindow: limit}
+}
+
+func (l *RedisFixedWindowLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	result, err := fixedWindowScript.Run(ctx, l.rdb, []string{fmt.Sprintf("ratelimit:fw:%s", key)},
+		l.WindowDuration.Milliseconds(), l.LimitPerWindow).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+
+	now := time.Now()
+	resetAt := now.Add(time.Duration(result[2]) * time.Millisecond)
+	if result[0] == 0 {
+		return denied(l.LimitPerWindow, now, resetAt, resetAt), nil
+	}
+	return Decision{
+		Allowed:   true,
+		Limit:     l.LimitPerWindow,
+		Remaining: l.LimitPerWindow - int(result[1]),
+		ResetAt:   resetAt,
+	}, nil
+}
+
+// RedisTokenBucketLimiter shares one bucket per key across every node, RefillRate is the time to add one token
+type RedisTokenBucketLimiter struct {
+	rdb        *redis.Client
+	Capacity   int
+	RefillRate time.Duration
+}
+
+func NewRedisTokenBucketLimiter(rdb *redis.Client, capacity int, refillRate time.Duration) *RedisTokenBucketLimiter {
+	return &RedisTokenBucketLimiter{rdb: rdb, Capacity: capacity, RefillRate: refillRate}
+}
+
+func (l *RedisTokenBucketLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	result, err := tokenBucketScript.Run(ctx, l.rdb, []string{fmt.Sprintf("ratelimit:tb:%s", key)},
+		l.Capacity, max(l.RefillRate.Milliseconds(), 1)).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+
+	now := time.Now()
+	resetAt := now.Add(time.Duration(result[3]) * time.Millisecond)
+	if result[0] == 0 {
+		return denied(l.Capacity, now, now.Add(time.Duration(result[2])*time.Millisecond), resetAt), nil
+	}
+	return Decision{
+		Allowed:   true,
+		Limit:     l.Capacity,
+		Remaining: int(result[1]),
+		ResetAt:   resetAt,
+	}, nil
+}
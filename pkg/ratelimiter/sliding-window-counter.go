@@ -0,0 +1,105 @@
+package ratelimiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+type slidingWindowCounter struct {
+	windowStart time.Time
+	current     int
+	previous    int
+}
+
+// SlidingWindowCounterLimiter weights the previous fixed window by how much of it still overlaps the sliding one,
+// constant memory per key at the cost of assuming the previous window's requests were evenly spread
+type SlidingWindowCounterLimiter struct {
+	WindowDuration time.Duration
+	LimitPerWindow int
+	Table          map[string]*slidingWindowCounter
+	clock          clock.Clock
+	mutex          sync.Mutex
+}
+
+func NewSlidingWindowCounterLimiter(window time.Duration, limit int, clock clock.Clock) *SlidingWindowCounterLimiter {
+	return &SlidingWindowCounterLimiter{
+		WindowDuration: window,
+		LimitPerWindow: limit,
+		Table:          make(map[string]*slidingWindowCounter),
+		clock:          clock,
+	}
+}
+
+func (l *SlidingWindowCounterLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now()
+	windowStart := now.Truncate(l.WindowDuration)
+
+	data, exists := l.Table[key]
+	switch {
+	case !exists:
+		data = &slidingWindowCounter{windowStart: windowStart}
+		l.Table[key] = data
+	case windowStart.Sub(data.windowStart) == l.WindowDuration:
+		data.previous, data.current = data.current, 0
+		data.windowStart = windowStart
+	case windowStart.After(data.windowStart):
+		// More than one window went by, nothing overlaps anymore
+		data.previous, data.current = 0, 0
+		data.windowStart = windowStart
+	}
+
+	elapsed := now.Sub(windowStart)
+	weight := 1 - float64(elapsed)/float64(l.WindowDuration)
+	estimate := float64(data.previous)*weight + float64(data.current)
+	resetAt := windowStart.Add(l.WindowDuration * 2) // Both windows have slid out by then
+
+	if estimate+1 > float64(l.LimitPerWindow) {
+		return denied(l.LimitPerWindow, now, l.retryAt(data, windowStart), resetAt), nil
+	}
+
+	data.current++
+	return Decision{
+		Allowed:   true,
+		Limit:     l.LimitPerWindow,
+		Remaining: max(int(math.Floor(float64(l.LimitPerWindow)-estimate-1)), 0),
+		ResetAt:   resetAt,
+	}, nil
+}
+
+// retryAt is when enough of the previous window has slid out for one more request,
+// solving previous * (1 - t/window) + current + 1 <= limit for t
+func (l *SlidingWindowCounterLimiter) retryAt(data *slidingWindowCounter, windowStart time.Time) time.Time {
+	previous, current := data.previous, data.current
+
+	// The current window is full by itself, it only starts sliding out once it becomes the previous one
+	if current >= l.LimitPerWindow {
+		windowStart = windowStart.Add(l.WindowDuration)
+		previous, current = current, 0
+	}
+
+	room := float64(l.LimitPerWindow - 1 - current)
+	fraction := max(1-room/float64(previous), 0)
+	return windowStart.Add(time.Duration(math.Ceil(fraction * float64(l.WindowDuration))))
+}
+
+func (l *SlidingWindowCounterLimiter) AutoEvict(evictDuration time.Duration) {
+	ticker := time.NewTicker(evictDuration)
+	for range ticker.C {
+		l.mutex.Lock()
+		now := l.clock.Now()
+		for key, value := range l.Table {
+			// Past two windows neither count matters
+			if now.Sub(value.windowStart) >= max(evictDuration, l.WindowDuration*2) {
+				delete(l.Table, key)
+			}
+		}
+		l.mutex.Unlock()
+	}
+}
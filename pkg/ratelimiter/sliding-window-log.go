@@ -0,0 +1,77 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
+)
+
+// SlidingWindowLogLimiter keeps the time of every allowed request inside the window, exact but O(limit) memory per key
+type SlidingWindowLogLimiter struct {
+	WindowDuration time.Duration
+	LimitPerWindow int
+	Table          map[string][]time.Time
+	clock          clock.Clock
+	mutex          sync.Mutex
+}
+
+func NewSlidingWindowLogLimiter(window time.Duration, limit int, clock clock.Clock) *SlidingWindowLogLimiter {
+	return &SlidingWindowLogLimiter{
+		WindowDuration: window,
+		LimitPerWindow: limit,
+		Table:          make(map[string][]time.Time),
+		clock:          clock,
+	}
+}
+
+func (l *SlidingWindowLogLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now()
+	log := l.trim(l.Table[key], now)
+
+	if len(log) >= l.LimitPerWindow {
+		l.Table[key] = log
+		// The oldest entry leaving the window frees a slot, the newest leaving it frees them all
+		return denied(l.LimitPerWindow, now, log[0].Add(l.WindowDuration), log[len(log)-1].Add(l.WindowDuration)), nil
+	}
+
+	log = append(log, now)
+	l.Table[key] = log
+
+	return Decision{
+		Allowed:   true,
+		Limit:     l.LimitPerWindow,
+		Remaining: l.LimitPerWindow - len(log),
+		ResetAt:   now.Add(l.WindowDuration),
+	}, nil
+}
+
+// trim drops entries that have slid out of the window, the log is in arrival order
+func (l *SlidingWindowLogLimiter) trim(log []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-l.WindowDuration)
+	i := 0
+	for i < len(log) && !log[i].After(cutoff) {
+		i++
+	}
+	return log[i:]
+}
+
+func (l *SlidingWindowLogLimiter) AutoEvict(evictDuration time.Duration) {
+	ticker := time.NewTicker(evictDuration)
+	for range ticker.C {
+		l.mutex.Lock()
+		now := l.clock.Now()
+		for key, log := range l.Table {
+			if log = l.trim(log, now); len(log) == 0 {
+				delete(l.Table, key)
+			} else {
+				l.Table[key] = log
+			}
+		}
+		l.mutex.Unlock()
+	}
+}
@@ -1,39 +1,87 @@
 package ratelimiter
 
 import (
+	"context"
+	"math"
 	"sync"
 	"time"
+
+	"github.com/abhinash-kml/go-api-server/pkg/clock"
 )
 
 type TokenBucketClientInfo struct {
-	tokens      int
+	tokens      float64 // Fractional so refill isn't lost between calls closer together than RefillRate
 	lastChecked time.Time
 }
 
+// TokenBucketLimiter starts every key with a full bucket and adds one token per RefillRate
 type TokenBucketLimiter struct {
-	Table         map[string]*TokenBucketClientInfo
-	Capacity      int
-	InitialTokens int
-	RefillRate    time.Duration
-	mutex         sync.Mutex
+	Table      map[string]*TokenBucketClientInfo
+	Capacity   int
+	RefillRate time.Duration
+	clock      clock.Clock
+	mutex      sync.Mutex
+}
+
+func NewTokenBucketLimiter(capacity int, refillRate time.Duration, clock clock.Clock) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		Table:      make(map[string]*TokenBucketClientInfo),
+		Capacity:   capacity,
+		RefillRate: refillRate,
+		clock:      clock,
+	}
+}
+
+func (f *TokenBucketLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	return f.AllowN(ctx, key, 1)
 }
 
-func (f *TokenBucketLimiter) Allow(ip string) bool {
-	data, exists := f.Table[ip]
+// AllowN takes n tokens at once, for limits on a size rather than a count. More than Capacity is never allowed.
+func (f *TokenBucketLimiter) AllowN(ctx context.Context, key string, n int) (Decision, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := f.clock.Now()
+	data, exists := f.Table[key]
 	if !exists {
-		f.Table[ip] = &TokenBucketClientInfo{tokens: f.InitialTokens, lastChecked: time.Now()}
-		return true
+		data = &TokenBucketClientInfo{tokens: float64(f.Capacity), lastChecked: now}
+		f.Table[key] = data
 	}
 
-	elapsedTime := time.Since(data.lastChecked)
-	tokensToAdd := int(elapsedTime / f.RefillRate)
-	data.tokens = min(f.Capacity, data.tokens+tokensToAdd)
-	data.lastChecked = time.Now()
+	elapsed := max(now.Sub(data.lastChecked), 0)
+	data.tokens = min(float64(f.Capacity), data.tokens+float64(elapsed)/float64(f.RefillRate))
+	data.lastChecked = now
 
-	if data.tokens > 0 {
-		data.tokens--
-		return true
+	if data.tokens < float64(n) {
+		retryAt := now.Add(f.refillTime(float64(n) - data.tokens))
+		return denied(f.Capacity, now, retryAt, now.Add(f.refillTime(float64(f.Capacity)-data.tokens))), nil
 	}
 
-	return false
+	data.tokens -= float64(n)
+	return Decision{
+		Allowed:   true,
+		Limit:     f.Capacity,
+		Remaining: int(math.Floor(data.tokens)),
+		ResetAt:   now.Add(f.refillTime(float64(f.Capacity) - data.tokens)),
+	}, nil
+}
+
+// refillTime is how long it takes to add this many tokens
+func (f *TokenBucketLimiter) refillTime(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens * float64(f.RefillRate)))
+}
+
+// AutoEvict drops idle buckets, by then they have refilled and a new one starts out the same
+func (f *TokenBucketLimiter) AutoEvict(evictDuration time.Duration) {
+	ticker := time.NewTicker(evictDuration)
+	for range ticker.C {
+		f.mutex.Lock()
+		now := f.clock.Now()
+		for key, value := range f.Table {
+			if now.Sub(value.lastChecked) >= evictDuration {
+				delete(f.Table, key)
+			}
+		}
+		f.mutex.Unlock()
+	}
 }
@@ -0,0 +1,165 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPContextKey struct{}
+
+// WithClientIP stores the resolved client address for ClientIP
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPContextKey{}, ip)
+}
+
+// ClientIP is the address resolved by ClientIPResolver when it ran, otherwise the direct peer, without the port
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey{}).(string); ok && ip != "" {
+		return ip
+	}
+	return peerIP(r)
+}
+
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// The forwarding header the trusted proxies write, the other one is never read
+const (
+	ForwardedHeader     = "forwarded"
+	XForwardedForHeader = "x-forwarded-for"
+)
+
+// ClientIPResolver only believes forwarding headers set by trusted proxies
+type ClientIPResolver struct {
+	trusted   []netip.Prefix
+	forwarded bool
+}
+
+// NewClientIPResolver takes CIDRs or single addresses, with none every request is its direct peer.
+// header names the one our proxies set, a client can send the other and it would pass through untouched.
+func NewClientIPResolver(proxies []string, header string) (*ClientIPResolver, error) {
+	resolver := &ClientIPResolver{trusted: make([]netip.Prefix, 0, len(proxies))}
+	switch strings.ToLower(header) {
+	case ForwardedHeader:
+		resolver.forwarded = true
+	case XForwardedForHeader, "":
+	default:
+		return nil, fmt.Errorf("invalid forwarded header %q, want %s or %s", header, ForwardedHeader, XForwardedForHeader)
+	}
+
+	for _, proxy := range proxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		resolver.trusted = append(resolver.trusted, prefix.Masked())
+	}
+	return resolver, nil
+}
+
+// Resolve walks the forwarding chain from the nearest hop outwards and stops at the first address it doesn't trust.
+// Anything further left was written by the client itself and can't be believed.
+func (c *ClientIPResolver) Resolve(r *http.Request) string {
+	client := peerIP(r)
+	if c == nil || !c.isTrusted(client) {
+		return client
+	}
+
+	hops := c.forwardedHops(r)
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHop(hops[i])
+		if !ok {
+			// unknown or obfuscated, the trusted proxy that added it is as far as we can see
+			break
+		}
+		client = addr.String()
+		if !c.isTrusted(client) {
+			break
+		}
+	}
+
+	return client
+}
+
+func (c *ClientIPResolver) isTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range c.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHops lists the for= nodes of Forwarded (RFC 7239) or the X-Forwarded-For entries, client first
+func (c *ClientIPResolver) forwardedHops(r *http.Request) []string {
+	var hops []string
+	if c.forwarded {
+		for _, value := range r.Header.Values("Forwarded") {
+			for _, element := range splitQuoted(value, ',') {
+				hop := ""
+				for _, pair := range splitQuoted(element, ';') {
+					key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if ok && strings.EqualFold(key, "for") {
+						hop = value
+					}
+				}
+				hops = append(hops, hop)
+			}
+		}
+		return hops
+	}
+
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for hop := range strings.SplitSeq(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// splitQuoted splits on sep outside of double quotes
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseHop accepts a bare address, host:port, or the quoted "[v6]:port" form of Forwarded
+func parseHop(hop string) (netip.Addr, bool) {
+	hop = strings.Trim(strings.TrimSpace(hop), `"`)
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	hop = strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]")
+	if addr, err := netip.ParseAddr(hop); err == nil {
+		return addr.Unmap(), true
+	}
+	return netip.Addr{}, false
+}
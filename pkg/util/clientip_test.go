@@ -0,0 +1,195 @@
+package util
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPResolverResolve(t *testing.T) {
+	proxies := []string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"}
+
+	tests := []struct {
+		name    string
+		header  string
+		peer    string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name: "untrusted peer ignores headers",
+			peer: "203.0.113.7:4000",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"6.6.6.6"},
+			},
+			want: "203.0.113.7",
+		},
+		{
+			name: "trusted peer without header",
+			peer: "10.0.0.1:4000",
+			want: "10.0.0.1",
+		},
+		{
+			name: "single trusted hop",
+			peer: "10.0.0.1:4000",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"198.51.100.4"},
+			},
+			want: "198.51.100.4",
+		},
+		{
+			name: "walks trusted hops to the first untrusted one",
+			peer: "10.0.0.1:4000",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"198.51.100.4, 10.1.2.3", "192.0.2.1"},
+			},
+			want: "198.51.100.4",
+		},
+		{
+			name: "client prepended a spoofed address",
+			peer: "10.0.0.1:4000",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"6.6.6.6, 198.51.100.4"},
+			},
+			want: "198.51.100.4",
+		},
+		{
+			name: "spoofed trusted address left of the client is not reached",
+			peer: "10.0.0.1:4000",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"10.9.9.9, 198.51.100.4"},
+			},
+			want: "198.51.100.4",
+		},
+		{
+			name: "every hop trusted ends at the leftmost",
+			peer: "10.0.0.1:4000",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"},
+			},
+			want: "10.0.0.3",
+		},
+		{
+			name: "garbage hop stops at the proxy that added it",
+			peer: "10.0.0.1:4000",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"198.51.100.4, not-an-ip, 10.0.0.2"},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "forwarded header is ignored when proxies set x-forwarded-for",
+			peer: "10.0.0.1:4000",
+			headers: map[string][]string{
+				"Forwarded":       {"for=6.6.6.6"},
+				"X-Forwarded-For": {"198.51.100.4"},
+			},
+			want: "198.51.100.4",
+		},
+		{
+			name:   "x-forwarded-for is ignored when proxies set forwarded",
+			header: ForwardedHeader,
+			peer:   "10.0.0.1:4000",
+			headers: map[string][]string{
+				"Forwarded":       {"for=198.51.100.4"},
+				"X-Forwarded-For": {"6.6.6.6"},
+			},
+			want: "198.51.100.4",
+		},
+		{
+			name:   "forwarded with ports, quotes and v6",
+			header: ForwardedHeader,
+			peer:   "[2001:db8::1]:4000",
+			headers: map[string][]string{
+				"Forwarded": {`for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.5:80`},
+			},
+			want: "2001:db8:cafe::17",
+		},
+		{
+			name:   "forwarded spoofed element left of the client",
+			header: ForwardedHeader,
+			peer:   "10.0.0.1:4000",
+			headers: map[string][]string{
+				"Forwarded": {"for=6.6.6.6", "for=198.51.100.4;by=10.0.0.1"},
+			},
+			want: "198.51.100.4",
+		},
+		{
+			name:   "forwarded obfuscated node stops the walk",
+			header: ForwardedHeader,
+			peer:   "10.0.0.1:4000",
+			headers: map[string][]string{
+				"Forwarded": {"for=198.51.100.4, for=_hidden, for=10.0.0.2"},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name:   "forwarded element without for",
+			header: ForwardedHeader,
+			peer:   "10.0.0.1:4000",
+			headers: map[string][]string{
+				"Forwarded": {"proto=https"},
+			},
+			want: "10.0.0.1",
+		},
+		{
+			name: "v4 mapped v6 peer matches a v4 range",
+			peer: "[::ffff:10.0.0.1]:4000",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"198.51.100.4"},
+			},
+			want: "198.51.100.4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := NewClientIPResolver(proxies, tt.header)
+			if err != nil {
+				t.Fatalf("NewClientIPResolver: %v", err)
+			}
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.peer
+			for name, values := range tt.headers {
+				for _, value := range values {
+					r.Header.Add(name, value)
+				}
+			}
+
+			if got := resolver.Resolve(r); got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewClientIPResolverRejectsBadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		header  string
+	}{
+		{name: "bad proxy", proxies: []string{"10.0.0.0/33"}},
+		{name: "bad header", header: "x-real-ip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClientIPResolver(tt.proxies, tt.header); err == nil {
+				t.Error("NewClientIPResolver() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestNilClientIPResolverTrustsNoOne(t *testing.T) {
+	var resolver *ClientIPResolver
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:4000"
+	r.Header.Set("X-Forwarded-For", "6.6.6.6")
+
+	if got := resolver.Resolve(r); got != "10.0.0.1" {
+		t.Errorf("Resolve() = %q, want the peer", got)
+	}
+}
@@ -0,0 +1,261 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JwtKey is one entry of the signing keyset, verify-only keys have no PrivateKey
+type JwtKey struct {
+	Kid        string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+	RetiredAt  time.Time
+}
+
+type JwtKeyset struct {
+	active  *JwtKey
+	keys    map[string]*JwtKey
+	overlap time.Duration
+}
+
+// JSON Web Key Set as served on /.well-known/jwks.json (RFC 7517)
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+var currentKeyset atomic.Pointer[JwtKeyset]
+
+// SetJwtKeyset replaces the keyset used by CreateJwtToken and VerifyJwtToken, nil restores HS512
+func SetJwtKeyset(keyset *JwtKeyset) {
+	currentKeyset.Store(keyset)
+}
+
+func GetJwtKeyset() *JwtKeyset {
+	return currentKeyset.Load()
+}
+
+// LoadJwtKeyset reads the PEM files named in config, it returns nil when no keys are configured
+func LoadJwtKeyset(cfg *config.SigningConfig) (*JwtKeyset, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, nil
+	}
+
+	overlap, err := time.ParseDuration(cfg.RotationOverlap)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rotation_overlap: %w", err)
+	}
+
+	keyset := &JwtKeyset{
+		keys:    make(map[string]*JwtKey, len(cfg.Keys)),
+		overlap: overlap,
+	}
+
+	for _, keyConfig := range cfg.Keys {
+		key, err := loadJwtKey(keyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", keyConfig.Kid, err)
+		}
+
+		if _, exists := keyset.keys[key.Kid]; exists {
+			return nil, fmt.Errorf("duplicate signing key %q", key.Kid)
+		}
+		keyset.keys[key.Kid] = key
+	}
+
+	active, ok := keyset.keys[cfg.ActiveKid]
+	if !ok {
+		return nil, fmt.Errorf("active_kid %q is not in the keyset", cfg.ActiveKid)
+	}
+	if active.PrivateKey == nil {
+		return nil, fmt.Errorf("active signing key %q has no private key", active.Kid)
+	}
+	if !active.RetiredAt.IsZero() {
+		return nil, fmt.Errorf("active signing key %q is retired", active.Kid)
+	}
+	keyset.active = active
+
+	return keyset, nil
+}
+
+func loadJwtKey(cfg config.SigningKeyConfig) (*JwtKey, error) {
+	if cfg.Kid == "" {
+		return nil, fmt.Errorf("kid is required")
+	}
+
+	key := &JwtKey{Kid: cfg.Kid}
+
+	switch cfg.Algorithm {
+	case jwt.SigningMethodRS256.Alg():
+		key.Method = jwt.SigningMethodRS256
+	case jwt.SigningMethodES256.Alg():
+		key.Method = jwt.SigningMethodES256
+	case jwt.SigningMethodEdDSA.Alg():
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	if cfg.RetiredAt != "" {
+		retiredAt, err := time.Parse(time.RFC3339, cfg.RetiredAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retired_at: %w", err)
+		}
+		key.RetiredAt = retiredAt
+	}
+
+	switch {
+	case cfg.PrivateKeyFile != "":
+		data, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.PrivateKey, err = parsePrivateKey(key.Method, data); err != nil {
+			return nil, err
+		}
+		key.PublicKey = key.PrivateKey.Public()
+	case cfg.PublicKeyFile != "":
+		data, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.PublicKey, err = parsePublicKey(key.Method, data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("private_key_file or public_key_file is required")
+	}
+
+	return key, nil
+}
+
+func parsePrivateKey(method jwt.SigningMethod, data []byte) (crypto.Signer, error) {
+	switch method {
+	case jwt.SigningMethodRS256:
+		return jwt.ParseRSAPrivateKeyFromPEM(data)
+	case jwt.SigningMethodES256:
+		key, err := jwt.ParseECPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 requires a P-256 key")
+		}
+		return key, nil
+	default:
+		key, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		return key.(crypto.Signer), nil
+	}
+}
+
+func parsePublicKey(method jwt.SigningMethod, data []byte) (crypto.PublicKey, error) {
+	switch method {
+	case jwt.SigningMethodRS256:
+		return jwt.ParseRSAPublicKeyFromPEM(data)
+	case jwt.SigningMethodES256:
+		key, err := jwt.ParseECPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 requires a P-256 key")
+		}
+		return key, nil
+	default:
+		return jwt.ParseEdPublicKeyFromPEM(data)
+	}
+}
+
+func (k *JwtKeyset) Active() *JwtKey {
+	return k.active
+}
+
+// Lookup returns the key for a kid as long as it is still inside its verification window
+func (k *JwtKeyset) Lookup(kid string) (*JwtKey, bool) {
+	key, ok := k.keys[kid]
+	if !ok || !k.verifies(key, time.Now()) {
+		return nil, false
+	}
+	return key, true
+}
+
+func (k *JwtKeyset) verifies(key *JwtKey, now time.Time) bool {
+	return key.RetiredAt.IsZero() || now.Before(key.RetiredAt.Add(k.overlap))
+}
+
+// JWKS lists the public half of every key that can still verify tokens
+func (k *JwtKeyset) JWKS() JSONWebKeySet {
+	now := time.Now()
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+
+	for _, key := range k.keys {
+		if !k.verifies(key, now) {
+			continue
+		}
+
+		jwk := JSONWebKey{
+			Kid: key.Kid,
+			Use: "sig",
+			Alg: key.Method.Alg(),
+		}
+
+		switch public := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			point, err := public.ECDH()
+			if err != nil {
+				continue
+			}
+			raw := point.Bytes()[1:] // Uncompressed point, 0x04 || X || Y
+			jwk.Kty = "EC"
+			jwk.Crv = "P-256"
+			jwk.X = base64.RawURLEncoding.EncodeToString(raw[:len(raw)/2])
+			jwk.Y = base64.RawURLEncoding.EncodeToString(raw[len(raw)/2:])
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	slices.SortFunc(set.Keys, func(a, b JSONWebKey) int { return strings.Compare(a.Kid, b.Kid) })
+
+	return set
+}
@@ -0,0 +1,138 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abhinash-kml/go-api-server/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testTokenConfig = config.TokenConfig{Issuer: "api", Audience: "api"}
+
+func writePem(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+	return path
+}
+
+// testKeys writes a fresh EdDSA signing key and the public half of a retired ES256 key
+func testKeys(t *testing.T) (newKey, oldPublic string, oldKey *ecdsa.PrivateKey) {
+	t.Helper()
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	newKey = writePem(t, "new.pem", "PRIVATE KEY", der)
+
+	oldKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ = x509.MarshalPKIXPublicKey(oldKey.Public())
+	oldPublic = writePem(t, "old.pub.pem", "PUBLIC KEY", der)
+
+	return newKey, oldPublic, oldKey
+}
+
+func signWith(t *testing.T, method jwt.SigningMethod, kid string, key crypto.Signer) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, NewRegisteredClaims(&testTokenConfig, "7", "jti", time.Minute))
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return signed
+}
+
+func TestJwtKeyRotationOverlap(t *testing.T) {
+	newKey, oldPublic, oldKey := testKeys(t)
+
+	tests := []struct {
+		name       string
+		retiredAgo time.Duration
+		wantKeys   int // JWKS entries, the active key is always one
+		wantVerify bool
+	}{
+		{name: "retired key verifies inside the overlap", retiredAgo: time.Hour, wantKeys: 2, wantVerify: true},
+		{name: "retired key is dropped after the overlap", retiredAgo: 3 * time.Hour, wantKeys: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyset, err := LoadJwtKeyset(&config.SigningConfig{
+				ActiveKid:       "new",
+				RotationOverlap: "2h",
+				Keys: []config.SigningKeyConfig{
+					{Kid: "new", Algorithm: "EdDSA", PrivateKeyFile: newKey},
+					{Kid: "old", Algorithm: "ES256", PublicKeyFile: oldPublic, RetiredAt: time.Now().Add(-tt.retiredAgo).Format(time.RFC3339)},
+				},
+			})
+			if err != nil {
+				t.Fatalf("LoadJwtKeyset() error = %v", err)
+			}
+			SetJwtKeyset(keyset)
+			defer SetJwtKeyset(nil)
+
+			if got := len(keyset.JWKS().Keys); got != tt.wantKeys {
+				t.Errorf("JWKS() has %d keys, want %d", got, tt.wantKeys)
+			}
+
+			// New tokens always carry the active kid
+			signed, err := CreateJwtToken("unused", NewRegisteredClaims(&testTokenConfig, "7", "jti", time.Minute))
+			if err != nil {
+				t.Fatalf("CreateJwtToken() error = %v", err)
+			}
+			token, err := VerifyJwtToken(&testTokenConfig, signed, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatalf("VerifyJwtToken() for the active key error = %v", err)
+			}
+			if token.Header["kid"] != "new" || token.Method.Alg() != "EdDSA" {
+				t.Errorf("token from the active key has kid %v and alg %v, want new and EdDSA", token.Header["kid"], token.Method.Alg())
+			}
+
+			old := signWith(t, jwt.SigningMethodES256, "old", oldKey)
+			if _, err := VerifyJwtToken(&testTokenConfig, old, &jwt.RegisteredClaims{}); (err == nil) != tt.wantVerify {
+				t.Errorf("VerifyJwtToken() for the retired key = %v, want verified %v", err, tt.wantVerify)
+			}
+
+			// The old key under the new kid, alg has to match what the kid was registered with
+			if _, err := VerifyJwtToken(&testTokenConfig, signWith(t, jwt.SigningMethodES256, "new", oldKey), &jwt.RegisteredClaims{}); err == nil {
+				t.Error("a token whose alg doesn't match its kid verified")
+			}
+		})
+	}
+}
+
+func TestLoadJwtKeysetRejects(t *testing.T) {
+	newKey, oldPublic, _ := testKeys(t)
+	retired := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name string
+		cfg  config.SigningConfig
+	}{
+		{"unknown active kid", config.SigningConfig{ActiveKid: "missing", RotationOverlap: "1h", Keys: []config.SigningKeyConfig{{Kid: "new", Algorithm: "EdDSA", PrivateKeyFile: newKey}}}},
+		{"verify-only active key", config.SigningConfig{ActiveKid: "old", RotationOverlap: "1h", Keys: []config.SigningKeyConfig{{Kid: "old", Algorithm: "ES256", PublicKeyFile: oldPublic}}}},
+		{"retired active key", config.SigningConfig{ActiveKid: "new", RotationOverlap: "1h", Keys: []config.SigningKeyConfig{{Kid: "new", Algorithm: "EdDSA", PrivateKeyFile: newKey, RetiredAt: retired}}}},
+		{"duplicate kid", config.SigningConfig{ActiveKid: "new", RotationOverlap: "1h", Keys: []config.SigningKeyConfig{{Kid: "new", Algorithm: "EdDSA", PrivateKeyFile: newKey}, {Kid: "new", Algorithm: "EdDSA", PrivateKeyFile: newKey}}}},
+		{"wrong algorithm for the key", config.SigningConfig{ActiveKid: "new", RotationOverlap: "1h", Keys: []config.SigningKeyConfig{{Kid: "new", Algorithm: "RS256", PrivateKeyFile: newKey}}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := LoadJwtKeyset(&tt.cfg); err == nil {
+			t.Errorf("%s: LoadJwtKeyset() succeeded", tt.name)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,26 +10,72 @@ import (
 	"go.uber.org/zap"
 )
 
-func CreateJwtToken(secret, issuer, subject string, audience []string, id string, expiry time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.RegisteredClaims{
-		Issuer:    issuer,
+// NewRegisteredClaims fills the registered claims from token config, custom claims are left for the caller
+func NewRegisteredClaims(cfg *config.TokenConfig, subject, id string, expiry time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    cfg.Issuer,
 		Subject:   subject,
-		Audience:  jwt.ClaimStrings(audience),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
-		NotBefore: jwt.NewNumericDate(time.Now()),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Audience:  jwt.ClaimStrings{cfg.Audience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
 		ID:        id,
-	})
+	}
+}
+
+var ErrNoJwtKeyset = errors.New("no asymmetric signing keys are loaded")
+
+// CreateJwtToken signs with the active key of the keyset, the secret is only used when no keyset is loaded
+func CreateJwtToken(secret string, claims jwt.Claims) (string, error) {
+	if GetJwtKeyset() == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+		return token.SignedString([]byte(secret))
+	}
+	return CreateAsymmetricJwtToken(claims)
+}
+
+// CreateAsymmetricJwtToken is for tokens third parties verify against the JWKS, it never falls back to the shared secret
+func CreateAsymmetricJwtToken(claims jwt.Claims) (string, error) {
+	keyset := GetJwtKeyset()
+	if keyset == nil {
+		return "", ErrNoJwtKeyset
+	}
+
+	active := keyset.Active()
+	token := jwt.NewWithClaims(active.Method, claims)
+	token.Header["kid"] = active.Kid
 
-	signedToken, err := token.SignedString([]byte(secret))
-	return signedToken, err
+	return token.SignedString(active.PrivateKey)
 }
 
-func VerifyJwtToken(cfg *config.TokenConfig, tokenString string) (*jwt.Token, *jwt.RegisteredClaims, error) {
-	tempClaims := &jwt.RegisteredClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, tempClaims, func(t *jwt.Token) (any, error) {
-		return []byte(cfg.Secret), nil
+// VerifyJwtToken parses into claims, which the caller passes as a pointer to its own claims type
+func VerifyJwtToken(cfg *config.TokenConfig, tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	keyset := GetJwtKeyset()
+	validMethods := []string{jwt.SigningMethodHS512.Alg()}
+	if keyset != nil {
+		validMethods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		if keyset == nil {
+			return []byte(cfg.Secret), nil
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		key, ok := keyset.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown or retired kid %q", kid)
+		}
+
+		// The alg header is attacker controlled, it has to match what the key was registered with
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("algorithm %s does not match key %q", t.Method.Alg(), kid)
+		}
+
+		return key.PublicKey, nil
 	},
+		jwt.WithValidMethods(validMethods),
 		jwt.WithIssuer(cfg.Issuer),
 		jwt.WithExpirationRequired(),
 		jwt.WithNotBeforeRequired(),
@@ -36,17 +83,20 @@ func VerifyJwtToken(cfg *config.TokenConfig, tokenString string) (*jwt.Token, *j
 
 	if err != nil {
 		zap.L().Info("JWT Parse Error", zap.Error(err))
-		return nil, nil, err
+		return nil, err
 	}
 
 	if !token.Valid {
-		return nil, nil, fmt.Errorf("Token invalid")
+		return nil, fmt.Errorf("Token invalid")
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
-	if !ok {
-		return nil, nil, fmt.Errorf("Token type assertion failed")
-	}
+	return token, nil
+}
 
-	return token, claims, nil
+// JwtSigningAlg is the alg new tokens are signed with
+func JwtSigningAlg() string {
+	if keyset := GetJwtKeyset(); keyset != nil {
+		return keyset.Active().Method.Alg()
+	}
+	return jwt.SigningMethodHS512.Alg()
 }
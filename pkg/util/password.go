@@ -0,0 +1,30 @@
+package util
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt refuses passwords longer than this many bytes, a multibyte character counts for more than one
+const MaxPasswordBytes = 72
+
+var ErrPasswordTooLong = bcrypt.ErrPasswordTooLong
+
+// PasswordFits reports whether bcrypt can hash the password
+func PasswordFits(password string) bool {
+	return len(password) <= MaxPasswordBytes
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// ComparePassword reports whether password matches the stored bcrypt hash
+func ComparePassword(hash, password string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
+}
@@ -0,0 +1,83 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+// The SHA1 seed of RFC 6238 appendix B, "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCodeRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8 digit codes, ours are their last 6
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(time.Unix(tt.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			got, err := TotpCode(rfc6238Secret, TotpStep(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatalf("TotpCode: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("TotpCode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotpCodeAcceptsLowercaseSecret(t *testing.T) {
+	got, err := TotpCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", TotpStep(time.Unix(59, 0)))
+	if err != nil || got != "287082" {
+		t.Errorf("TotpCode() = %s, %v, want 287082", got, err)
+	}
+}
+
+func TestValidateTotp(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := TotpStep(now)
+
+	codeAt := func(offset int64) string {
+		code, err := TotpCode(rfc6238Secret, step+offset)
+		if err != nil {
+			t.Fatalf("TotpCode: %v", err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		wantStep int64
+		wantOk   bool
+	}{
+		{name: "current step", code: codeAt(0), skew: 1, wantStep: step, wantOk: true},
+		{name: "previous step within skew", code: codeAt(-1), skew: 1, wantStep: step - 1, wantOk: true},
+		{name: "next step within skew", code: codeAt(1), skew: 1, wantStep: step + 1, wantOk: true},
+		{name: "two steps back outside skew", code: codeAt(-2), skew: 1},
+		{name: "two steps ahead outside skew", code: codeAt(2), skew: 1},
+		{name: "two steps back with wider skew", code: codeAt(-2), skew: 2, wantStep: step - 2, wantOk: true},
+		{name: "no skew rejects the previous step", code: codeAt(-1), skew: 0},
+		{name: "wrong length", code: "12345", skew: 1},
+		{name: "wrong code", code: "000000", skew: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, gotOk := ValidateTotp(rfc6238Secret, tt.code, now, tt.skew)
+			if gotOk != tt.wantOk || gotStep != tt.wantStep {
+				t.Errorf("ValidateTotp() = %d, %v, want %d, %v", gotStep, gotOk, tt.wantStep, tt.wantOk)
+			}
+		})
+	}
+}